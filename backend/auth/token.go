@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"competition-app/config"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Token purposes. A token is only accepted for the purpose it was issued for.
+const (
+	PurposeSignup  = "signup"
+	PurposeLogin   = "login"
+	PurposeSession = "session"
+)
+
+// Claims are the signed contents of a token
+type Claims struct {
+	Purpose   string          `json:"pur"`
+	Nonce     string          `json:"jti"`
+	ExpiresAt int64           `json:"exp"`
+	Data      json.RawMessage `json:"dat"`
+}
+
+// Expiry returns when the token stops being valid
+func (c Claims) Expiry() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+var (
+	signingKey []byte
+	publicURL  string
+	lifetimes  map[string]time.Duration
+)
+
+// Init sets up token signing from the config. Without a configured signing key
+// a random one is used, so tokens do not survive a restart.
+func Init(cfg *config.Config) error {
+	if cfg.SigningKey != "" {
+		signingKey = []byte(cfg.SigningKey)
+	} else {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return err
+		}
+		log.Printf("Warning: SIGNING_KEY is not set, issued links and sessions are lost on restart")
+	}
+
+	publicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+	lifetimes = map[string]time.Duration{
+		PurposeSignup:  cfg.SignupTokenTTL,
+		PurposeLogin:   cfg.MagicLinkTTL,
+		PurposeSession: cfg.SessionTTL,
+	}
+
+	return nil
+}
+
+// Issue creates a signed token carrying data for the given purpose. Every token
+// gets a random nonce so one-time tokens can be marked as used.
+func Issue(purpose string, data interface{}) (string, Claims, error) {
+	var claims Claims
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return "", claims, err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", claims, err
+	}
+
+	claims = Claims{
+		Purpose:   purpose,
+		Nonce:     hex.EncodeToString(nonce),
+		ExpiresAt: time.Now().Add(lifetimes[purpose]).Unix(),
+		Data:      payload,
+	}
+
+	body, err := json.Marshal(claims)
+	if err != nil {
+		return "", claims, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(body)
+	return encoded + "." + sign(encoded), claims, nil
+}
+
+// Verify checks a token's signature, purpose and expiry and decodes its data into out
+func Verify(token, purpose string, out interface{}) (Claims, error) {
+	var claims Claims
+
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(encoded))) {
+		return claims, errors.New("invalid token")
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims, errors.New("invalid token")
+	}
+	if err := json.Unmarshal(body, &claims); err != nil || claims.Purpose != purpose {
+		return claims, errors.New("invalid token")
+	}
+
+	if time.Now().After(claims.Expiry()) {
+		return claims, errors.New("token expired")
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(claims.Data, out); err != nil {
+			return claims, errors.New("invalid token")
+		}
+	}
+
+	return claims, nil
+}
+
+// Link builds the public link that hands a token to the frontend page at path
+func Link(path, token string) string {
+	return publicURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// sign computes the signature of an encoded token body
+func sign(encoded string) string {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,117 @@
+package calendar
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentType is the MIME type of iCalendar feeds
+const ContentType = "text/calendar; charset=utf-8"
+
+// Event statuses
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// maxLineLength is the longest content line allowed before folding, in octets
+const maxLineLength = 75
+
+// Event is an all-day calendar event
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	Location     string
+	Date         time.Time
+	Sequence     int
+	Status       string
+	LastModified time.Time
+}
+
+// Write writes a calendar with the given events in iCalendar (RFC 5545) format
+func Write(w io.Writer, name string, events []Event) error {
+	cw := &contentWriter{w: w}
+
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:-//Competition Platform//Competitions//EN")
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	cw.line("X-WR-CALNAME:" + escapeText(name))
+
+	stamp := formatTimestamp(time.Now())
+	for _, e := range events {
+		cw.line("BEGIN:VEVENT")
+		cw.line("UID:" + e.UID)
+		cw.line("DTSTAMP:" + stamp)
+		cw.line("DTSTART;VALUE=DATE:" + e.Date.Format("20060102"))
+		cw.line("DTEND;VALUE=DATE:" + e.Date.AddDate(0, 0, 1).Format("20060102"))
+		cw.line("SUMMARY:" + escapeText(e.Summary))
+		if e.Description != "" {
+			cw.line("DESCRIPTION:" + escapeText(e.Description))
+		}
+		if e.Location != "" {
+			cw.line("LOCATION:" + escapeText(e.Location))
+		}
+		cw.line("SEQUENCE:" + strconv.Itoa(e.Sequence))
+		cw.line("STATUS:" + e.Status)
+		if !e.LastModified.IsZero() {
+			cw.line("LAST-MODIFIED:" + formatTimestamp(e.LastModified))
+		}
+		cw.line("TRANSP:TRANSPARENT")
+		cw.line("END:VEVENT")
+	}
+
+	cw.line("END:VCALENDAR")
+	return cw.err
+}
+
+// formatTimestamp formats a time as a UTC date-time value
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escapeText escapes a TEXT value
+func escapeText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// contentWriter writes content lines, folding long ones and remembering the
+// first error so callers can check it once
+type contentWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (cw *contentWriter) line(s string) {
+	if cw.err != nil {
+		return
+	}
+
+	var b strings.Builder
+	length := 0
+	for _, r := range s {
+		size := len(string(r))
+		// Fold without splitting a multi-byte character; continuation lines
+		// start with a space that counts towards their length
+		if length+size > maxLineLength {
+			b.WriteString("\r\n ")
+			length = 1
+		}
+		b.WriteRune(r)
+		length += size
+	}
+	b.WriteString("\r\n")
+
+	_, cw.err = io.WriteString(cw.w, b.String())
+}
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all configuration for the application
@@ -21,6 +24,52 @@ type Config struct {
 	// Redis settings
 	RedisHost string
 	RedisPort int
+
+	// Idempotency settings
+	IdempotencyTTL time.Duration
+
+	// Concurrency settings
+	RequireIfMatch bool
+
+	// Admin settings
+	AdminToken string
+
+	// Retention settings
+	SoftDeleteRetention time.Duration
+	DeliveryRetention   time.Duration
+
+	// Webhook settings
+	WebhookPollInterval time.Duration
+	WebhookTimeout      time.Duration
+	WebhookMaxAttempts  int
+	WebhookBaseBackoff  time.Duration
+
+	// SMTP settings (notifications are only sent when SMTPHost is set)
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	SMTPTimeout  time.Duration
+
+	// Notification settings
+	NotificationPollInterval time.Duration
+	NotificationMaxAttempts  int
+	NotificationBaseBackoff  time.Duration
+
+	// Self-service settings
+	PublicURL      string
+	SigningKey     string
+	SignupTokenTTL time.Duration
+	MagicLinkTTL   time.Duration
+	SessionTTL     time.Duration
+
+	// Participant profile settings: the federations participants can hold a
+	// license with, mapped to the regular expression of their license numbers
+	LicenseFormats map[string]string
+
+	// Rating settings: "elo" or "glicko2"
+	RatingSystem string
 }
 
 // LoadConfig loads the configuration from environment variables and secrets
@@ -32,6 +81,28 @@ func LoadConfig() (*Config, error) {
 		DBPort:     5432,
 		RedisHost:  "redis",
 		RedisPort:  6379,
+
+		IdempotencyTTL:      24 * time.Hour,
+		SoftDeleteRetention: 30 * 24 * time.Hour,
+		DeliveryRetention:   30 * 24 * time.Hour,
+		WebhookPollInterval: 5 * time.Second,
+		WebhookTimeout:      10 * time.Second,
+		WebhookMaxAttempts:  8,
+		WebhookBaseBackoff:  30 * time.Second,
+
+		SMTPPort:                 587,
+		SMTPFrom:                 "Competition Platform <no-reply@localhost>",
+		SMTPTimeout:              30 * time.Second,
+		NotificationPollInterval: 10 * time.Second,
+		NotificationMaxAttempts:  6,
+		NotificationBaseBackoff:  time.Minute,
+
+		PublicURL:      "http://localhost:7788",
+		SignupTokenTTL: 24 * time.Hour,
+		MagicLinkTTL:   15 * time.Minute,
+		SessionTTL:     30 * 24 * time.Hour,
+
+		RatingSystem: "elo",
 	}
 
 	// Server settings
@@ -80,6 +151,91 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	// Idempotency settings
+	readDurationEnv("IDEMPOTENCY_TTL", &cfg.IdempotencyTTL)
+
+	// Concurrency settings
+	if require := os.Getenv("REQUIRE_IF_MATCH"); require != "" {
+		if b, err := strconv.ParseBool(require); err == nil {
+			cfg.RequireIfMatch = b
+		}
+	}
+
+	// Admin settings (admin routes stay disabled without a token)
+	if adminToken, err := readFileOrEnv("/run/secrets/admin_token", "ADMIN_TOKEN", ""); err == nil {
+		cfg.AdminToken = strings.TrimSpace(adminToken)
+	}
+
+	// Retention settings
+	readDurationEnv("SOFT_DELETE_RETENTION", &cfg.SoftDeleteRetention)
+	readDurationEnv("DELIVERY_RETENTION", &cfg.DeliveryRetention)
+
+	// Webhook settings
+	readDurationEnv("WEBHOOK_POLL_INTERVAL", &cfg.WebhookPollInterval)
+	readDurationEnv("WEBHOOK_TIMEOUT", &cfg.WebhookTimeout)
+	readDurationEnv("WEBHOOK_BASE_BACKOFF", &cfg.WebhookBaseBackoff)
+
+	if attempts := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); attempts != "" {
+		if n, err := strconv.Atoi(attempts); err == nil && n > 0 {
+			cfg.WebhookMaxAttempts = n
+		}
+	}
+
+	// SMTP settings
+	cfg.SMTPHost = os.Getenv("SMTP_HOST")
+
+	if port := os.Getenv("SMTP_PORT"); port != "" {
+		if p, err := strconv.Atoi(port); err == nil {
+			cfg.SMTPPort = p
+		}
+	}
+
+	cfg.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	if smtpPassword, err := readFileOrEnv("/run/secrets/smtp_password", "SMTP_PASSWORD", ""); err == nil {
+		cfg.SMTPPassword = strings.TrimSpace(smtpPassword)
+	}
+
+	if from := os.Getenv("SMTP_FROM"); from != "" {
+		cfg.SMTPFrom = from
+	}
+
+	readDurationEnv("SMTP_TIMEOUT", &cfg.SMTPTimeout)
+
+	// Notification settings
+	readDurationEnv("NOTIFICATION_POLL_INTERVAL", &cfg.NotificationPollInterval)
+	readDurationEnv("NOTIFICATION_BASE_BACKOFF", &cfg.NotificationBaseBackoff)
+
+	if attempts := os.Getenv("NOTIFICATION_MAX_ATTEMPTS"); attempts != "" {
+		if n, err := strconv.Atoi(attempts); err == nil && n > 0 {
+			cfg.NotificationMaxAttempts = n
+		}
+	}
+
+	// Self-service settings
+	if publicURL := os.Getenv("PUBLIC_URL"); publicURL != "" {
+		cfg.PublicURL = publicURL
+	}
+
+	if signingKey, err := readFileOrEnv("/run/secrets/signing_key", "SIGNING_KEY", ""); err == nil {
+		cfg.SigningKey = strings.TrimSpace(signingKey)
+	}
+
+	readDurationEnv("SIGNUP_TOKEN_TTL", &cfg.SignupTokenTTL)
+	readDurationEnv("MAGIC_LINK_TTL", &cfg.MagicLinkTTL)
+	readDurationEnv("SESSION_TTL", &cfg.SessionTTL)
+
+	// Participant profile settings, e.g. {"DLV": "^[0-9]{6}$"}
+	if formats := os.Getenv("LICENSE_FORMATS"); formats != "" {
+		if err := json.Unmarshal([]byte(formats), &cfg.LicenseFormats); err != nil {
+			return nil, fmt.Errorf("invalid LICENSE_FORMATS: %w", err)
+		}
+	}
+
+	// Rating settings
+	if system := os.Getenv("RATING_SYSTEM"); system != "" {
+		cfg.RatingSystem = strings.ToLower(system)
+	}
+
 	return cfg, nil
 }
 
@@ -115,3 +271,12 @@ func readFileOrEnv(filePath, envVar, fallback string) (string, error) {
 	// Return fallback value
 	return fallback, nil
 }
+
+// readDurationEnv overrides target with a positive Go duration from the environment
+func readDurationEnv(envVar string, target *time.Duration) {
+	if value := os.Getenv(envVar); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			*target = d
+		}
+	}
+}
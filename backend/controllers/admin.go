@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTrash handles requests to list all soft-deleted records
+func GetTrash(c *gin.Context) {
+	competitions, err := models.GetDeletedCompetitions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deleted competitions", "details": err.Error()})
+		return
+	}
+
+	participants, err := models.GetDeletedParticipants()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deleted participants", "details": err.Error()})
+		return
+	}
+
+	registrations, err := models.GetDeletedRegistrations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deleted registrations", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"competitions":  competitions,
+		"participants":  participants,
+		"registrations": registrations,
+	})
+}
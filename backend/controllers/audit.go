@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"competition-app/middleware"
+	"competition-app/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// changeMeta collects the actor and request ID recorded with every change
+func changeMeta(c *gin.Context) models.ChangeMeta {
+	return models.ChangeMeta{
+		Actor:     c.GetString(middleware.ActorKey),
+		RequestID: c.GetString(middleware.RequestIDKey),
+	}
+}
+
+// GetAuditLog handles requests to list audit entries
+func GetAuditLog(c *gin.Context) {
+	filter := models.AuditFilter{
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		Actor:      c.Query("actor"),
+		Limit:      100,
+	}
+
+	var err error
+	if from := c.Query("from"); from != "" {
+		if filter.From, err = parseTimeParam(from); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from time", "details": err.Error()})
+			return
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if filter.To, err = parseTimeParam(to); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to time", "details": err.Error()})
+			return
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 || filter.Limit > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit (must be between 1 and 1000)"})
+			return
+		}
+	}
+	if offset := c.Query("offset"); offset != "" {
+		filter.Offset, err = strconv.Atoi(offset)
+		if err != nil || filter.Offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+			return
+		}
+	}
+
+	entries, err := models.GetAuditEntries(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// parseTimeParam parses a query parameter given as RFC 3339 or YYYY-MM-DD
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
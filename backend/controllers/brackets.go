@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"competition-app/validation"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBracket handles requests for the bracket tree of a competition
+func GetBracket(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	bracket, err := models.GetBracket(competitionID)
+	if err != nil {
+		if err.Error() == "bracket not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve bracket", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, bracket)
+}
+
+// GenerateBracket handles requests to draw a knockout bracket from the
+// participants registered for a competition
+func GenerateBracket(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	var data struct {
+		Format string `json:"format"`
+		Seeds  []int  `json:"seeds"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	if data.Format == "" {
+		data.Format = models.BracketSingleElimination
+	}
+
+	validationObj := validation.Bracket{Format: data.Format, Seeds: data.Seeds}
+	if err := validation.ValidateBracket(&validationObj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bracket, err := models.GenerateBracket(changeMeta(c), competitionID, data.Format, data.Seeds)
+	if err != nil {
+		switch err.Error() {
+		case "competition does not exist":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "bracket already exists":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case "a bracket needs at least two participants", "seeded participant is not registered for this competition", "participant seeded more than once":
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate bracket", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, bracket)
+}
+
+// DeleteBracket handles requests to discard the bracket of a competition
+func DeleteBracket(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	if err := models.DeleteBracket(changeMeta(c), competitionID); err != nil {
+		if err.Error() == "bracket not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bracket", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bracket deleted successfully"})
+}
+
+// RecordMatchResult handles requests to enter or correct the result of a
+// bracket match
+func RecordMatchResult(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	matchID, err := strconv.Atoi(c.Param("match_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid match ID"})
+		return
+	}
+
+	var data struct {
+		Score1 *float64 `json:"score1" binding:"required"`
+		Score2 *float64 `json:"score2" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	match, err := models.RecordMatchResult(changeMeta(c), competitionID, matchID, *data.Score1, *data.Score2)
+	if err != nil {
+		switch err.Error() {
+		case "match not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "match is not ready to be played", "later matches have already been played":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case "a knockout match cannot end in a draw":
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record match result", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, match)
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"bytes"
+	"competition-app/calendar"
+	"competition-app/models"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// calendarUIDDomain makes event UIDs globally unique as RFC 5545 requires
+const calendarUIDDomain = "competition-platform"
+
+// competitionEvent converts a competition to a calendar event
+func competitionEvent(uid string, competition models.Competition, sequence int, status string) calendar.Event {
+	return calendar.Event{
+		UID:          uid + "@" + calendarUIDDomain,
+		Summary:      competition.Name,
+		Description:  competition.Description,
+		Location:     competition.Location,
+		Date:         competition.Date,
+		Sequence:     sequence,
+		Status:       status,
+		LastModified: competition.UpdatedAt,
+	}
+}
+
+// writeCalendar renders a calendar and sends it as the response
+func writeCalendar(c *gin.Context, name string, events []calendar.Event) {
+	var buf bytes.Buffer
+	if err := calendar.Write(&buf, name, events); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render calendar", "details": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, calendar.ContentType, buf.Bytes())
+}
+
+// GetCompetitionsCalendar handles requests for the iCalendar feed of all
+// competitions. Deleted competitions stay in the feed as cancelled events until
+// they are purged, so subscribed calendars learn about the cancellation.
+func GetCompetitionsCalendar(c *gin.Context) {
+	competitions, err := models.GetAllCompetitions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve competitions", "details": err.Error()})
+		return
+	}
+
+	cancelled, err := models.GetDeletedCompetitions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deleted competitions", "details": err.Error()})
+		return
+	}
+
+	events := make([]calendar.Event, 0, len(competitions)+len(cancelled))
+	for _, competition := range competitions {
+		uid := "competition-" + strconv.Itoa(competition.ID)
+		events = append(events, competitionEvent(uid, competition, competition.Version, calendar.StatusConfirmed))
+	}
+	for _, competition := range cancelled {
+		uid := "competition-" + strconv.Itoa(competition.ID)
+		events = append(events, competitionEvent(uid, competition, competition.Version, calendar.StatusCancelled))
+	}
+
+	writeCalendar(c, "Competitions", events)
+}
+
+// IssueCalendarToken handles requests to create the secret token of a
+// participant's calendar feed. Issuing a new token revokes the previous one.
+func IssueCalendarToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate calendar token", "details": err.Error()})
+		return
+	}
+	token := hex.EncodeToString(buf)
+
+	if err := models.SetParticipantCalendarToken(id, token); err != nil {
+		if err.Error() == "participant not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue calendar token", "details": err.Error()})
+		}
+		return
+	}
+
+	// The token is only ever shown here, so the response must not be stored
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, gin.H{
+		"token": token,
+		"url":   "/api/calendars/" + token + ".ics",
+	})
+}
+
+// GetParticipantCalendar handles requests for a participant's iCalendar feed,
+// identified by its secret token. Competitions the participant was removed
+// from or that were deleted are included as cancelled events.
+func GetParticipantCalendar(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	participant, err := models.GetParticipantByCalendarToken(token)
+	if err != nil {
+		if err.Error() == "participant not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve calendar", "details": err.Error()})
+		}
+		return
+	}
+
+	entries, err := models.GetParticipantCalendarEntries(participant.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve competitions", "details": err.Error()})
+		return
+	}
+
+	// Events are per participant, so a withdrawal does not clash with the
+	// public feed where the competition is still going ahead
+	uidSuffix := "-participant-" + strconv.Itoa(participant.ID)
+
+	events := make([]calendar.Event, 0, len(entries))
+	for _, entry := range entries {
+		status := calendar.StatusConfirmed
+		if entry.Cancelled {
+			status = calendar.StatusCancelled
+		}
+		uid := "competition-" + strconv.Itoa(entry.Competition.ID) + uidSuffix
+		events = append(events, competitionEvent(uid, entry.Competition, entry.Sequence, status))
+	}
+
+	writeCalendar(c, participant.Name+" - Competitions", events)
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"competition-app/validation"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindCategory reads and validates a category from the request body
+func bindCategory(c *gin.Context) (models.Category, bool) {
+	var category models.Category
+	if err := c.ShouldBindJSON(&category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return category, false
+	}
+
+	validationObj := validation.Category{
+		Name:        category.Name,
+		Gender:      category.Gender,
+		MinAge:      category.MinAge,
+		MaxAge:      category.MaxAge,
+		SkillLevels: category.SkillLevels,
+	}
+
+	if err := validation.ValidateCategory(&validationObj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return category, false
+	}
+
+	return category, true
+}
+
+// GetCategories handles requests to list a competition's categories with
+// their participant counts
+func GetCategories(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	if !models.CompetitionExists(competitionID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "competition not found"})
+		return
+	}
+
+	overview, err := models.GetCategories(competitionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve categories", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, overview)
+}
+
+// GetCategoryParticipants handles requests for the roster of a category
+func GetCategoryParticipants(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	categoryID, err := strconv.Atoi(c.Param("category_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	participants, err := models.GetCategoryParticipants(competitionID, categoryID)
+	if err != nil {
+		if err.Error() == "category not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve participants", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, participants)
+}
+
+// CreateCategory handles requests to add a category to a competition
+func CreateCategory(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	category, ok := bindCategory(c)
+	if !ok {
+		return
+	}
+	category.CompetitionID = competitionID
+
+	if err := models.CreateCategory(changeMeta(c), &category); err != nil {
+		switch err.Error() {
+		case "competition does not exist":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "category name already used":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory handles requests to change a category's name and rules
+func UpdateCategory(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	categoryID, err := strconv.Atoi(c.Param("category_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	category, ok := bindCategory(c)
+	if !ok {
+		return
+	}
+	category.ID = categoryID
+	category.CompetitionID = competitionID
+
+	if err := models.UpdateCategory(changeMeta(c), &category); err != nil {
+		switch err.Error() {
+		case "category not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "category name already used":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory handles requests to remove a category from a competition
+func DeleteCategory(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	categoryID, err := strconv.Atoi(c.Param("category_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	if err := models.DeleteCategory(changeMeta(c), competitionID, categoryID); err != nil {
+		if err.Error() == "category not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
@@ -20,6 +20,9 @@ func GetCompetitions(c *gin.Context) {
 	if err == nil {
 		var competitions []models.Competition
 		if err := json.Unmarshal([]byte(cachedData), &competitions); err == nil {
+			if notModified(c, contentETag([]byte(cachedData))) {
+				return
+			}
 			c.JSON(http.StatusOK, competitions)
 			return
 		}
@@ -35,6 +38,9 @@ func GetCompetitions(c *gin.Context) {
 	// Cache the result
 	if competitionsJson, err := json.Marshal(competitions); err == nil {
 		models.SetCache(cacheKey, string(competitionsJson), 5*time.Minute)
+		if notModified(c, contentETag(competitionsJson)) {
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, competitions)
@@ -55,6 +61,9 @@ func GetCompetition(c *gin.Context) {
 	if err == nil && cachedData != "" {
 		var competition models.Competition
 		if err := json.Unmarshal([]byte(cachedData), &competition); err == nil {
+			if notModified(c, versionETag(competition.Version)) {
+				return
+			}
 			c.JSON(http.StatusOK, competition)
 			return
 		}
@@ -72,6 +81,10 @@ func GetCompetition(c *gin.Context) {
 		models.SetCache(cacheKey, string(competitionJson), 5*time.Minute)
 	}
 
+	if notModified(c, versionETag(competition.Version)) {
+		return
+	}
+
 	c.JSON(http.StatusOK, competition)
 }
 
@@ -85,12 +98,20 @@ func CreateCompetition(c *gin.Context) {
 		return
 	}
 
+	if competition.Discipline == "" {
+		competition.Discipline = models.DefaultDiscipline
+	}
+
 	// Convert to validation type for validation
 	validationObj := validation.Competition{
 		Name:        competition.Name,
 		Description: competition.Description,
 		Date:        competition.Date,
 		Location:    competition.Location,
+		MinTeamSize: competition.MinTeamSize,
+		MaxTeamSize: competition.MaxTeamSize,
+		Discipline:  competition.Discipline,
+		Series:      competition.Series,
 	}
 
 	// Validate the competition data
@@ -100,7 +121,7 @@ func CreateCompetition(c *gin.Context) {
 	}
 
 	// Create the competition
-	if err := models.CreateCompetition(&competition); err != nil {
+	if err := models.CreateCompetition(changeMeta(c), &competition); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create competition", "details": err.Error()})
 		return
 	}
@@ -113,6 +134,7 @@ func CreateCompetition(c *gin.Context) {
 		models.SetCache("competitions:"+strconv.Itoa(competition.ID), string(competitionJson), 5*time.Minute)
 	}
 
+	c.Header("ETag", versionETag(competition.Version))
 	c.JSON(http.StatusCreated, competition)
 }
 
@@ -124,6 +146,12 @@ func UpdateCompetition(c *gin.Context) {
 		return
 	}
 
+	version, err := expectedVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var competition models.Competition
 
 	// Bind the request body to the competition struct
@@ -135,6 +163,10 @@ func UpdateCompetition(c *gin.Context) {
 	// Set the ID from the URL parameter
 	competition.ID = id
 
+	if competition.Discipline == "" {
+		competition.Discipline = models.DefaultDiscipline
+	}
+
 	// Convert to validation type for validation
 	validationObj := validation.Competition{
 		ID:          competition.ID,
@@ -142,6 +174,10 @@ func UpdateCompetition(c *gin.Context) {
 		Description: competition.Description,
 		Date:        competition.Date,
 		Location:    competition.Location,
+		MinTeamSize: competition.MinTeamSize,
+		MaxTeamSize: competition.MaxTeamSize,
+		Discipline:  competition.Discipline,
+		Series:      competition.Series,
 	}
 
 	// Validate the competition data
@@ -151,9 +187,11 @@ func UpdateCompetition(c *gin.Context) {
 	}
 
 	// Update the competition
-	if err := models.UpdateCompetition(&competition); err != nil {
+	if err := models.UpdateCompetition(changeMeta(c), &competition, version); err != nil {
 		if err.Error() == "competition not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else if err.Error() == "competition has been modified" {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update competition", "details": err.Error()})
 		}
@@ -164,6 +202,7 @@ func UpdateCompetition(c *gin.Context) {
 	models.DeleteCache("competitions:all")
 	models.DeleteCache("competitions:" + strconv.Itoa(id))
 
+	c.Header("ETag", versionETag(competition.Version))
 	c.JSON(http.StatusOK, competition)
 }
 
@@ -176,7 +215,7 @@ func DeleteCompetition(c *gin.Context) {
 	}
 
 	// Delete the competition
-	if err := models.DeleteCompetition(id); err != nil {
+	if err := models.DeleteCompetition(changeMeta(c), id); err != nil {
 		if err.Error() == "competition not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		} else {
@@ -192,3 +231,143 @@ func DeleteCompetition(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Competition deleted successfully"})
 }
+
+// PatchCompetition handles JSON merge patch requests to partially update a competition
+func PatchCompetition(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	version, err := expectedVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	patch, status, err := readMergePatch(c, "name", "description", "date", "location", "min_team_size", "max_team_size", "discipline", "series")
+	if err != nil {
+		c.JSON(status, gin.H{"error": "Invalid merge patch", "details": err.Error()})
+		return
+	}
+
+	current, err := models.GetCompetition(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Apply the patch to the editable fields of the stored competition
+	document := map[string]interface{}{
+		"name":          current.Name,
+		"description":   current.Description,
+		"date":          current.Date.Format("2006-01-02"),
+		"location":      current.Location,
+		"min_team_size": current.MinTeamSize,
+		"max_team_size": current.MaxTeamSize,
+		"discipline":    current.Discipline,
+		"series":        current.Series,
+	}
+
+	var merged models.Competition
+	if err := mergeInto(document, patch, &merged); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge patch", "details": err.Error()})
+		return
+	}
+
+	if merged.Discipline == "" {
+		merged.Discipline = models.DefaultDiscipline
+	}
+
+	// Validate only the merged result
+	validationObj := validation.Competition{
+		ID:          id,
+		Name:        merged.Name,
+		Description: merged.Description,
+		Date:        merged.Date,
+		Location:    merged.Location,
+		MinTeamSize: merged.MinTeamSize,
+		MaxTeamSize: merged.MaxTeamSize,
+		Discipline:  merged.Discipline,
+		Series:      merged.Series,
+	}
+
+	if err := validation.ValidateCompetition(&validationObj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Collect the columns that actually changed
+	changes := map[string]interface{}{}
+	if merged.Name != current.Name {
+		changes["name"] = merged.Name
+	}
+	if merged.Description != current.Description {
+		changes["description"] = merged.Description
+	}
+	if !merged.Date.Equal(current.Date) {
+		changes["date"] = merged.Date
+	}
+	if merged.Location != current.Location {
+		changes["location"] = merged.Location
+	}
+	if !sameInt(merged.MinTeamSize, current.MinTeamSize) {
+		changes["min_team_size"] = merged.MinTeamSize
+	}
+	if !sameInt(merged.MaxTeamSize, current.MaxTeamSize) {
+		changes["max_team_size"] = merged.MaxTeamSize
+	}
+	if merged.Discipline != current.Discipline {
+		changes["discipline"] = merged.Discipline
+	}
+	if merged.Series != current.Series {
+		changes["series"] = merged.Series
+	}
+
+	competition, err := models.PatchCompetition(changeMeta(c), id, changes, version)
+	if err != nil {
+		if err.Error() == "competition not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else if err.Error() == "competition has been modified" {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update competition", "details": err.Error()})
+		}
+		return
+	}
+
+	// Invalidate cache
+	models.DeleteCache("competitions:all")
+	models.DeleteCache("competitions:" + strconv.Itoa(id))
+
+	c.Header("ETag", versionETag(competition.Version))
+	c.JSON(http.StatusOK, competition)
+}
+
+// RestoreCompetition handles requests to restore a deleted competition
+func RestoreCompetition(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	competition, err := models.RestoreCompetition(changeMeta(c), id)
+	if err != nil {
+		if err.Error() == "deleted competition not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore competition", "details": err.Error()})
+		}
+		return
+	}
+
+	// Invalidate cache
+	models.DeleteCache("competitions:all")
+	models.DeleteCache("competitions:" + strconv.Itoa(id))
+	models.DeleteCache("participants:competition:" + strconv.Itoa(id))
+
+	c.Header("ETag", versionETag(competition.Version))
+	c.JSON(http.StatusOK, competition)
+}
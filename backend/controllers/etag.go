@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// versionETag builds the strong ETag for a versioned entity
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// contentETag builds a weak ETag from a serialized response body
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified sets the ETag header and reports whether the client's
+// If-None-Match header already matches it, in which case a 304 has been sent
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// expectedVersion parses the If-Match header into the entity version the client
+// expects. It returns 0 when the header is absent or "*", meaning any version.
+func expectedVersion(c *gin.Context) (int, error) {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return 0, nil
+	}
+
+	if strings.HasPrefix(ifMatch, "W/") || strings.Contains(ifMatch, ",") {
+		return 0, errors.New("If-Match must contain a single strong ETag")
+	}
+
+	version, err := strconv.Atoi(strings.Trim(ifMatch, `"`))
+	if err != nil || version <= 0 {
+		return 0, errors.New("invalid ETag in If-Match header")
+	}
+
+	return version, nil
+}
@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"competition-app/validation"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindEvent reads and validates an event from the request body
+func bindEvent(c *gin.Context) (models.Event, bool) {
+	var event models.Event
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return event, false
+	}
+
+	if event.ScoringType == "" {
+		event.ScoringType = "points"
+	}
+
+	validationObj := validation.Event{
+		Name:        event.Name,
+		StartsAt:    event.StartsAt,
+		EndsAt:      event.EndsAt,
+		Capacity:    event.Capacity,
+		ScoringType: event.ScoringType,
+	}
+
+	if err := validation.ValidateEvent(&validationObj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return event, false
+	}
+
+	return event, true
+}
+
+// eventParams parses the competition and event IDs from the URL
+func eventParams(c *gin.Context) (int, int, bool) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return 0, 0, false
+	}
+
+	eventID, err := strconv.Atoi(c.Param("event_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return 0, 0, false
+	}
+
+	return competitionID, eventID, true
+}
+
+// invalidateCompetitionCache drops the cached copies of a competition after
+// its events changed
+func invalidateCompetitionCache(competitionID int) {
+	models.DeleteCache("competitions:all")
+	models.DeleteCache("competitions:" + strconv.Itoa(competitionID))
+}
+
+// GetEvents handles requests to list the events of a competition
+func GetEvents(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	if !models.CompetitionExists(competitionID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "competition not found"})
+		return
+	}
+
+	events, err := models.GetEvents(competitionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// CreateEvent handles requests to add an event to a competition
+func CreateEvent(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	event, ok := bindEvent(c)
+	if !ok {
+		return
+	}
+	event.CompetitionID = competitionID
+
+	if err := models.CreateEvent(changeMeta(c), &event); err != nil {
+		switch err.Error() {
+		case "competition does not exist":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "event name already used":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event", "details": err.Error()})
+		}
+		return
+	}
+
+	invalidateCompetitionCache(competitionID)
+
+	c.JSON(http.StatusCreated, event)
+}
+
+// UpdateEvent handles requests to change an event
+func UpdateEvent(c *gin.Context) {
+	competitionID, eventID, ok := eventParams(c)
+	if !ok {
+		return
+	}
+
+	event, ok := bindEvent(c)
+	if !ok {
+		return
+	}
+	event.ID = eventID
+	event.CompetitionID = competitionID
+
+	if err := models.UpdateEvent(changeMeta(c), &event); err != nil {
+		switch err.Error() {
+		case "event not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "event name already used", "capacity is below the number of entries":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event", "details": err.Error()})
+		}
+		return
+	}
+
+	invalidateCompetitionCache(competitionID)
+
+	c.JSON(http.StatusOK, event)
+}
+
+// DeleteEvent handles requests to remove an event from a competition
+func DeleteEvent(c *gin.Context) {
+	competitionID, eventID, ok := eventParams(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteEvent(changeMeta(c), competitionID, eventID); err != nil {
+		if err.Error() == "event not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event", "details": err.Error()})
+		}
+		return
+	}
+
+	invalidateCompetitionCache(competitionID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event deleted successfully"})
+}
+
+// GetEventParticipants handles requests for the entry list of an event
+func GetEventParticipants(c *gin.Context) {
+	competitionID, eventID, ok := eventParams(c)
+	if !ok {
+		return
+	}
+
+	participants, err := models.GetEventParticipants(competitionID, eventID)
+	if err != nil {
+		if err.Error() == "event not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve participants", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, participants)
+}
+
+// AddParticipantToEvent handles requests to enter a participant in an event
+func AddParticipantToEvent(c *gin.Context) {
+	competitionID, eventID, ok := eventParams(c)
+	if !ok {
+		return
+	}
+
+	var data struct {
+		ParticipantID int      `json:"participant_id" binding:"required"`
+		SkillLevel    string   `json:"skill_level"`
+		EntryTime     *float64 `json:"entry_time"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	if err := validation.ValidateRaceTime(data.EntryTime); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, registered, err := models.AddParticipantToEvent(changeMeta(c), competitionID, eventID, data.ParticipantID, data.SkillLevel, data.EntryTime)
+	if err != nil {
+		switch err.Error() {
+		case "event not found", "participant does not exist":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "participant already registered for this event", "event is full":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case "participant matches no category":
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add participant to event", "details": err.Error()})
+		}
+		return
+	}
+
+	// Entering an event may have registered the participant for the competition
+	if registered {
+		models.DeleteCache("participants:all")
+		models.DeleteCache("participants:competition:" + strconv.Itoa(competitionID))
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// UpdateEventEntry handles requests to change the entry time of a
+// participant's event entry
+func UpdateEventEntry(c *gin.Context) {
+	competitionID, eventID, ok := eventParams(c)
+	if !ok {
+		return
+	}
+
+	participantID, err := strconv.Atoi(c.Param("participant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	var data struct {
+		EntryTime *float64 `json:"entry_time"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	if err := validation.ValidateRaceTime(data.EntryTime); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := models.SetEntryTime(changeMeta(c), competitionID, eventID, participantID, data.EntryTime)
+	if err != nil {
+		if err.Error() == "participant not registered for this event" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event entry", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// RemoveParticipantFromEvent handles requests to withdraw a participant from an event
+func RemoveParticipantFromEvent(c *gin.Context) {
+	competitionID, eventID, ok := eventParams(c)
+	if !ok {
+		return
+	}
+
+	participantID, err := strconv.Atoi(c.Param("participant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	if err := models.RemoveParticipantFromEvent(changeMeta(c), competitionID, eventID, participantID); err != nil {
+		if err.Error() == "participant not registered for this event" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove participant from event", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Participant removed from event successfully"})
+}
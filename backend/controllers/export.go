@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"competition-app/export"
+	"competition-app/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFormat reads and checks the format query parameter
+func exportFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", export.FormatCSV)
+	if !export.IsFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format (must be csv, xlsx or jsonl)"})
+		return "", false
+	}
+	return format, true
+}
+
+// startExport sends the download headers and creates the writer for the response
+func startExport(c *gin.Context, format, filename string, multiTable bool) (export.Writer, error) {
+	w, err := export.NewWriter(format, c.Writer, multiTable)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+"."+format+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	return w, nil
+}
+
+// ExportCompetition handles requests to download the roster and results of a
+// competition as CSV, XLSX or JSON Lines
+func ExportCompetition(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	if !models.CompetitionExists(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "competition not found"})
+		return
+	}
+
+	w, err := startExport(c, format, "competition-"+strconv.Itoa(id)+"-roster", false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export", "details": err.Error()})
+		return
+	}
+
+	// Headers are already sent, so failures from here on can only cut the download short
+	err = w.BeginTable("roster", models.RosterColumns)
+	if err == nil {
+		err = models.StreamCompetitionRoster(id, w.WriteRow)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		log.Printf("Warning: export of competition %d failed: %v", id, err)
+		c.Abort()
+	}
+}
+
+// ExportDatabase handles admin requests to download the whole database. A
+// single table can be chosen with the table parameter; CSV exports require it.
+func ExportDatabase(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	tables := models.ExportTables
+	if name := c.Query("table"); name != "" {
+		table, err := models.GetExportTable(name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown table: " + name})
+			return
+		}
+		tables = []models.ExportTable{table}
+	} else if !export.SupportsTables(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The table parameter is required for csv exports"})
+		return
+	}
+
+	filename := "database"
+	if len(tables) == 1 {
+		filename = tables[0].Name
+	}
+
+	w, err := startExport(c, format, filename, len(tables) > 1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export", "details": err.Error()})
+		return
+	}
+
+	for _, table := range tables {
+		err = w.BeginTable(table.Name, table.Columns)
+		if err == nil {
+			err = models.StreamExportTable(table, w.WriteRow)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		log.Printf("Warning: database export failed: %v", err)
+		c.Abort()
+	}
+}
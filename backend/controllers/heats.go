@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"competition-app/export"
+	"competition-app/models"
+	"competition-app/validation"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// startListColumns are the columns of a printable start list
+var startListColumns = []string{"heat", "lane", "participant_id", "name", "club", "nationality", "seed_time"}
+
+// heatParams parses the competition and event IDs and the round from the URL
+func heatParams(c *gin.Context) (int, int, string, bool) {
+	competitionID, eventID, ok := eventParams(c)
+	if !ok {
+		return 0, 0, "", false
+	}
+
+	round := c.Param("round")
+	if err := validation.ValidateHeatRound(round); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, 0, "", false
+	}
+
+	return competitionID, eventID, round, true
+}
+
+// respondHeatError maps the errors of heat changes to responses
+func respondHeatError(c *gin.Context, err error, message string) {
+	switch err.Error() {
+	case "event not found", "heats not found", "participant not in this round":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case "heats already exist", "finals have already been seeded", "lane is already taken", "heat has already been swum",
+		"tied times at the last qualifying place need a swim-off", "no preliminary times recorded":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case "heats are only for timed events", "the event has no entries", "lane does not exist", "heat does not exist":
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
+
+// GetHeats handles requests for the heats and lanes of a round of a timed event
+func GetHeats(c *gin.Context) {
+	competitionID, eventID, round, ok := heatParams(c)
+	if !ok {
+		return
+	}
+
+	heats, err := models.GetHeats(competitionID, eventID, round)
+	if err != nil {
+		respondHeatError(c, err, "Failed to retrieve heats")
+		return
+	}
+
+	c.JSON(http.StatusOK, heats)
+}
+
+// GenerateHeats handles requests to seed a round of a timed event into heats
+// and lanes
+func GenerateHeats(c *gin.Context) {
+	competitionID, eventID, round, ok := heatParams(c)
+	if !ok {
+		return
+	}
+
+	var data struct {
+		Lanes int  `json:"lanes"`
+		Top   *int `json:"top"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	if data.Lanes == 0 {
+		data.Lanes = 8
+	}
+
+	validationObj := validation.Heats{Round: round, Lanes: data.Lanes, Top: data.Top}
+	if err := validation.ValidateHeats(&validationObj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// By default a final is a single heat
+	top := data.Lanes
+	if data.Top != nil {
+		top = *data.Top
+	}
+
+	heats, err := models.GenerateHeats(changeMeta(c), competitionID, eventID, round, data.Lanes, top)
+	if err != nil {
+		respondHeatError(c, err, "Failed to generate heats")
+		return
+	}
+
+	c.JSON(http.StatusCreated, heats)
+}
+
+// DeleteHeats handles requests to discard the heats of a round
+func DeleteHeats(c *gin.Context) {
+	competitionID, eventID, round, ok := heatParams(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteHeats(changeMeta(c), competitionID, eventID, round); err != nil {
+		respondHeatError(c, err, "Failed to delete heats")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Heats deleted successfully"})
+}
+
+// MoveHeatLane handles requests to move a participant to another heat and
+// lane by hand
+func MoveHeatLane(c *gin.Context) {
+	competitionID, eventID, round, ok := heatParams(c)
+	if !ok {
+		return
+	}
+
+	participantID, err := strconv.Atoi(c.Param("participant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	var data struct {
+		Heat int  `json:"heat" binding:"required"`
+		Lane int  `json:"lane" binding:"required"`
+		Swap bool `json:"swap"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	heats, err := models.MoveHeatLane(changeMeta(c), competitionID, eventID, round, participantID, data.Heat, data.Lane, data.Swap)
+	if err != nil {
+		respondHeatError(c, err, "Failed to move participant")
+		return
+	}
+
+	c.JSON(http.StatusOK, heats)
+}
+
+// RecordHeatTime handles requests to enter or correct the time a participant
+// swam in a round. A null time clears it.
+func RecordHeatTime(c *gin.Context) {
+	competitionID, eventID, round, ok := heatParams(c)
+	if !ok {
+		return
+	}
+
+	participantID, err := strconv.Atoi(c.Param("participant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	var data struct {
+		Time *float64 `json:"time"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	if err := validation.ValidateRaceTime(data.Time); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lane, err := models.RecordHeatTime(changeMeta(c), competitionID, eventID, round, participantID, data.Time)
+	if err != nil {
+		respondHeatError(c, err, "Failed to record time")
+		return
+	}
+
+	c.JSON(http.StatusOK, lane)
+}
+
+// ExportStartList handles requests to download the start list of a round,
+// with every lane of every heat, as CSV, XLSX or JSON Lines. XLSX and JSON
+// Lines exports get one table per heat.
+func ExportStartList(c *gin.Context) {
+	competitionID, eventID, round, ok := heatParams(c)
+	if !ok {
+		return
+	}
+
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	heats, err := models.GetHeats(competitionID, eventID, round)
+	if err != nil {
+		respondHeatError(c, err, "Failed to retrieve heats")
+		return
+	}
+
+	perHeat := export.SupportsTables(format)
+	filename := "event-" + strconv.Itoa(eventID) + "-" + round + "-start-list"
+	w, err := startExport(c, format, filename, perHeat)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export", "details": err.Error()})
+		return
+	}
+
+	// Headers are already sent, so failures from here on can only cut the download short
+	if !perHeat {
+		err = w.BeginTable("start-list", startListColumns)
+	}
+	for _, heat := range heats.Heats {
+		if err != nil {
+			break
+		}
+		if perHeat {
+			if err = w.BeginTable("heat-"+strconv.Itoa(heat.Heat), startListColumns); err != nil {
+				break
+			}
+		}
+
+		occupied := map[int]models.HeatLane{}
+		for _, lane := range heat.Lanes {
+			occupied[lane.Lane] = lane
+		}
+		for lane := 1; lane <= heats.Lanes && err == nil; lane++ {
+			l, ok := occupied[lane]
+			if !ok {
+				err = w.WriteRow([]interface{}{int64(heat.Heat), int64(lane), nil, "", "", "", ""})
+				continue
+			}
+			err = w.WriteRow([]interface{}{int64(heat.Heat), int64(lane), int64(l.ParticipantID), l.Name, l.Club, l.Nationality, models.FormatRaceTime(l.SeedTime)})
+		}
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		log.Printf("Warning: start list export of event %d failed: %v", eventID, err)
+		c.Abort()
+	}
+}
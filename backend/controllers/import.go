@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"competition-app/validation"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportSize = 5 << 20
+	maxImportRows = 5000
+)
+
+// ImportParticipants handles bulk imports of participants from a CSV file with
+// the columns name, email and optionally locale, the profile fields
+// (date_of_birth, gender, club, nationality, federation, license_number) and
+// competition_ids (separated by ";").
+// With dry_run=true the import is only checked and a per-row report returned;
+// otherwise every row is imported or, if any row fails, none is.
+func ImportParticipants(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run value"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	// The CSV is accepted as an uploaded file or as the raw request body
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A CSV file is required in the file field", "details": err.Error()})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file", "details": err.Error()})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	rows, err := parseImportCSV(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV", "details": err.Error()})
+		return
+	}
+
+	committed, err := models.ImportParticipants(changeMeta(c), rows, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import participants", "details": err.Error()})
+		return
+	}
+
+	invalid := 0
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			invalid++
+		}
+	}
+
+	report := gin.H{
+		"dry_run":   dryRun,
+		"committed": committed,
+		"total":     len(rows),
+		"valid":     len(rows) - invalid,
+		"invalid":   invalid,
+		"rows":      rows,
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	if !committed {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	// Invalidate cache
+	models.DeleteCache("participants:all")
+	invalidated := map[int]bool{}
+	for _, row := range rows {
+		for _, competitionID := range row.CompetitionIDs {
+			if !invalidated[competitionID] {
+				models.DeleteCache("participants:competition:" + strconv.Itoa(competitionID))
+				invalidated[competitionID] = true
+			}
+		}
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// parseImportCSV reads the import rows from CSV, validating each one. Problems
+// with a single row are recorded on the row; only an unreadable file is an error.
+func parseImportCSV(r io.Reader) ([]models.ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("the header must contain a name column")
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, errors.New("the header must contain an email column")
+	}
+
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []models.ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		// Skip blank lines such as a trailing empty row from spreadsheet exports
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		if len(rows) == maxImportRows {
+			return nil, errors.New("too many rows (maximum " + strconv.Itoa(maxImportRows) + ")")
+		}
+
+		row := models.ImportRow{
+			Line:           line,
+			Name:           field(record, "name"),
+			Email:          field(record, "email"),
+			Locale:         field(record, "locale"),
+			Gender:         field(record, "gender"),
+			Club:           field(record, "club"),
+			Nationality:    field(record, "nationality"),
+			Federation:     field(record, "federation"),
+			LicenseNumber:  field(record, "license_number"),
+			CompetitionIDs: []int{},
+		}
+
+		if value := field(record, "date_of_birth"); value != "" {
+			dateOfBirth, err := validation.ParseDate(value)
+			if err != nil {
+				row.Errors = append(row.Errors, "invalid date of birth (expected YYYY-MM-DD): "+value)
+			} else {
+				row.DateOfBirth = &dateOfBirth
+			}
+		}
+
+		err = validateParticipant(models.Participant{
+			Name:          row.Name,
+			Email:         row.Email,
+			Locale:        row.Locale,
+			DateOfBirth:   row.DateOfBirth,
+			Gender:        row.Gender,
+			Club:          row.Club,
+			Nationality:   row.Nationality,
+			Federation:    row.Federation,
+			LicenseNumber: row.LicenseNumber,
+		})
+		if err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+
+		seen := map[int]bool{}
+		for _, value := range strings.Split(field(record, "competition_ids"), ";") {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			competitionID, err := strconv.Atoi(value)
+			if err != nil || competitionID <= 0 {
+				row.Errors = append(row.Errors, "invalid competition ID: "+value)
+				continue
+			}
+			if !seen[competitionID] {
+				row.CompetitionIDs = append(row.CompetitionIDs, competitionID)
+				seen[competitionID] = true
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("the file contains no participants")
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"competition-app/middleware"
+	"competition-app/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMe handles requests from a signed-in participant for their own profile
+func GetMe(c *gin.Context) {
+	participant, err := models.GetParticipant(c.GetInt(middleware.ParticipantIDKey))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, participant)
+}
+
+// GetMyCompetitions handles requests from a signed-in participant for the
+// competitions they are registered for
+func GetMyCompetitions(c *gin.Context) {
+	competitions, err := models.GetParticipantCompetitions(c.GetInt(middleware.ParticipantIDKey))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve competitions", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, competitions)
+}
+
+// RegisterMe handles requests from a signed-in participant to register for a competition
+func RegisterMe(c *gin.Context) {
+	participantID := c.GetInt(middleware.ParticipantIDKey)
+
+	var data struct {
+		CompetitionID int    `json:"competition_id" binding:"required"`
+		SkillLevel    string `json:"skill_level"`
+	}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	if err := models.AddParticipantToCompetition(changeMeta(c), participantID, data.CompetitionID, time.Now(), data.SkillLevel); err != nil {
+		switch err.Error() {
+		case "competition does not exist", "participant does not exist":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "participant already registered for this competition":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case "participant matches no category":
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register for competition", "details": err.Error()})
+		}
+		return
+	}
+
+	// Invalidate cache
+	models.DeleteCache("participants:all")
+	models.DeleteCache("participants:competition:" + strconv.Itoa(data.CompetitionID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Registered for competition successfully"})
+}
+
+// UnregisterMe handles requests from a signed-in participant to withdraw from a competition
+func UnregisterMe(c *gin.Context) {
+	participantID := c.GetInt(middleware.ParticipantIDKey)
+
+	competitionID, err := strconv.Atoi(c.Param("competition_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	if err := models.RemoveParticipantFromCompetition(changeMeta(c), participantID, competitionID); err != nil {
+		if err.Error() == "participant not found in competition" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to withdraw from competition", "details": err.Error()})
+		}
+		return
+	}
+
+	// Invalidate cache
+	models.DeleteCache("participants:all")
+	models.DeleteCache("participants:competition:" + strconv.Itoa(competitionID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Withdrawn from competition successfully"})
+}
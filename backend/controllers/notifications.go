@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotifications handles requests to list queued and sent email notifications
+func GetNotifications(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != models.NotificationPending && status != models.NotificationSent && status != models.NotificationDead {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification status"})
+		return
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit (must be between 1 and 1000)"})
+			return
+		}
+	}
+
+	notifications, err := models.GetNotifications(status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+// RetryNotification handles requests to requeue a dead-lettered notification
+func RetryNotification(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	if err := models.RetryNotification(id); err != nil {
+		if err.Error() == "dead notification not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry notification", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification queued for retry"})
+}
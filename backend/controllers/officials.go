@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOfficials handles requests to list all officials
+func GetOfficials(c *gin.Context) {
+	officials, err := models.GetAllOfficials()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve officials", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, officials)
+}
+
+// CreateOfficial handles requests to create an official. The access token is
+// generated here and only returned in this response.
+func CreateOfficial(c *gin.Context) {
+	var data struct {
+		Name          string `json:"name" binding:"required"`
+		CompetitionID *int   `json:"competition_id"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	if len(data.Name) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is too long (maximum 255 characters)"})
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate official token", "details": err.Error()})
+		return
+	}
+
+	official := models.Official{
+		Name:          data.Name,
+		CompetitionID: data.CompetitionID,
+		Token:         hex.EncodeToString(buf),
+	}
+
+	if err := models.CreateOfficial(&official); err != nil {
+		if err.Error() == "competition does not exist" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create official", "details": err.Error()})
+		}
+		return
+	}
+
+	// The token is only ever shown here, so the response must not be stored
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, official)
+}
+
+// DeleteOfficial handles requests to delete an official
+func DeleteOfficial(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid official ID"})
+		return
+	}
+
+	if err := models.DeleteOfficial(id); err != nil {
+		if err.Error() == "official not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete official", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Official deleted successfully"})
+}
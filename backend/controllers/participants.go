@@ -16,6 +16,12 @@ func GetParticipants(c *gin.Context) {
 	// Check if we're filtering by competition ID
 	competitionIDStr := c.Query("competition_id")
 
+	// Listings filtered by profile fields are not cached
+	if c.Query("club") != "" || c.Query("nationality") != "" {
+		getFilteredParticipants(c, competitionIDStr)
+		return
+	}
+
 	// Try to get data from cache first
 	var cacheKey string
 	var err error
@@ -31,6 +37,9 @@ func GetParticipants(c *gin.Context) {
 		var participants []models.Participant
 		err = json.Unmarshal([]byte(cachedData), &participants)
 		if err == nil {
+			if notModified(c, contentETag([]byte(cachedData))) {
+				return
+			}
 			c.JSON(http.StatusOK, participants)
 			return
 		}
@@ -62,11 +71,58 @@ func GetParticipants(c *gin.Context) {
 	participantsJson, err = json.Marshal(participants)
 	if err == nil {
 		models.SetCache(cacheKey, string(participantsJson), 5*time.Minute)
+		if notModified(c, contentETag(participantsJson)) {
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, participants)
 }
 
+// getFilteredParticipants responds with the participants matching the club and
+// nationality query parameters
+func getFilteredParticipants(c *gin.Context, competitionIDStr string) {
+	filter := models.ParticipantFilter{
+		Club:        c.Query("club"),
+		Nationality: c.Query("nationality"),
+	}
+
+	if competitionIDStr != "" {
+		competitionID, err := strconv.Atoi(competitionIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+			return
+		}
+		filter.CompetitionID = competitionID
+	}
+
+	participants, err := models.FindParticipants(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve participants", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, participants)
+}
+
+// validateParticipant runs the validation rules on a participant's fields
+func validateParticipant(p models.Participant) error {
+	validationObj := validation.Participant{
+		ID:            p.ID,
+		Name:          p.Name,
+		Email:         p.Email,
+		Locale:        p.Locale,
+		DateOfBirth:   p.DateOfBirth,
+		Gender:        p.Gender,
+		Club:          p.Club,
+		Nationality:   p.Nationality,
+		Federation:    p.Federation,
+		LicenseNumber: p.LicenseNumber,
+	}
+
+	return validation.ValidateParticipant(&validationObj)
+}
+
 // GetParticipant handles requests to get a specific participant
 func GetParticipant(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -82,6 +138,9 @@ func GetParticipant(c *gin.Context) {
 		var participant models.Participant
 		err = json.Unmarshal([]byte(cachedData), &participant)
 		if err == nil {
+			if notModified(c, versionETag(participant.Version)) {
+				return
+			}
 			c.JSON(http.StatusOK, participant)
 			return
 		}
@@ -98,6 +157,10 @@ func GetParticipant(c *gin.Context) {
 		models.SetCache(cacheKey, string(participantJson), 5*time.Minute)
 	}
 
+	if notModified(c, versionETag(participant.Version)) {
+		return
+	}
+
 	c.JSON(http.StatusOK, participant)
 }
 
@@ -127,25 +190,24 @@ func CreateParticipant(c *gin.Context) {
 		return
 	}
 
-	// Convert to validation type for validation
-	validationObj := validation.Participant{
-		Name:  participant.Name,
-		Email: participant.Email,
-	}
-
-	if err := validation.ValidateParticipant(&validationObj); err != nil {
+	if err := validateParticipant(participant); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := models.CreateParticipant(&participant); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create participant", "details": err.Error()})
+	if err := models.CreateParticipant(changeMeta(c), &participant); err != nil {
+		if err.Error() == "license number already registered" {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create participant", "details": err.Error()})
+		}
 		return
 	}
 
 	// Invalidate cache
 	models.DeleteCache("participants:all")
 
+	c.Header("ETag", versionETag(participant.Version))
 	c.JSON(http.StatusCreated, participant)
 }
 
@@ -160,6 +222,7 @@ func AddParticipantToCompetition(c *gin.Context) {
 	var data struct {
 		CompetitionID    int    `json:"competition_id" binding:"required"`
 		RegistrationDate string `json:"registration_date" binding:"required"`
+		SkillLevel       string `json:"skill_level"`
 	}
 
 	if err := c.ShouldBindJSON(&data); err != nil {
@@ -173,8 +236,12 @@ func AddParticipantToCompetition(c *gin.Context) {
 		return
 	}
 
-	if err := models.AddParticipantToCompetition(participantID, data.CompetitionID, registrationDate); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add participant to competition", "details": err.Error()})
+	if err := models.AddParticipantToCompetition(changeMeta(c), participantID, data.CompetitionID, registrationDate, data.SkillLevel); err != nil {
+		if err.Error() == "participant matches no category" {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add participant to competition", "details": err.Error()})
+		}
 		return
 	}
 
@@ -193,6 +260,12 @@ func UpdateParticipant(c *gin.Context) {
 		return
 	}
 
+	version, err := expectedVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var participant models.Participant
 	if err := c.ShouldBindJSON(&participant); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
@@ -200,20 +273,18 @@ func UpdateParticipant(c *gin.Context) {
 	}
 	participant.ID = id
 
-	validationObj := validation.Participant{
-		ID:    participant.ID,
-		Name:  participant.Name,
-		Email: participant.Email,
-	}
-
-	if err := validation.ValidateParticipant(&validationObj); err != nil {
+	if err := validateParticipant(participant); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := models.UpdateParticipant(&participant); err != nil {
+	if err := models.UpdateParticipant(changeMeta(c), &participant, version); err != nil {
 		if err.Error() == "participant not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else if err.Error() == "participant has been modified" {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		} else if err.Error() == "license number already registered" {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update participant", "details": err.Error()})
 		}
@@ -224,6 +295,7 @@ func UpdateParticipant(c *gin.Context) {
 	models.DeleteCache("participants:all")
 	models.DeleteCache("participants:" + strconv.Itoa(id))
 
+	c.Header("ETag", versionETag(participant.Version))
 	c.JSON(http.StatusOK, participant)
 }
 
@@ -241,7 +313,7 @@ func RemoveParticipantFromCompetition(c *gin.Context) {
 		return
 	}
 
-	if err := models.RemoveParticipantFromCompetition(participantID, competitionID); err != nil {
+	if err := models.RemoveParticipantFromCompetition(changeMeta(c), participantID, competitionID); err != nil {
 		if err.Error() == "participant not found in competition" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		} else {
@@ -265,7 +337,7 @@ func DeleteParticipant(c *gin.Context) {
 		return
 	}
 
-	if err := models.DeleteParticipant(id); err != nil {
+	if err := models.DeleteParticipant(changeMeta(c), id); err != nil {
 		if err.Error() == "participant not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		} else {
@@ -280,3 +352,240 @@ func DeleteParticipant(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Participant deleted successfully"})
 }
+
+// PatchParticipant handles JSON merge patch requests to partially update a participant
+func PatchParticipant(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	version, err := expectedVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	patch, status, err := readMergePatch(c, "name", "email", "locale", "date_of_birth", "gender", "club", "nationality", "federation", "license_number")
+	if err != nil {
+		c.JSON(status, gin.H{"error": "Invalid merge patch", "details": err.Error()})
+		return
+	}
+
+	current, err := models.GetParticipant(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Apply the patch to the editable fields of the stored participant
+	document := map[string]interface{}{
+		"name":           current.Name,
+		"email":          current.Email,
+		"locale":         current.Locale,
+		"gender":         current.Gender,
+		"club":           current.Club,
+		"nationality":    current.Nationality,
+		"federation":     current.Federation,
+		"license_number": current.LicenseNumber,
+	}
+	if current.DateOfBirth != nil {
+		document["date_of_birth"] = current.DateOfBirth.Format("2006-01-02")
+	}
+
+	var merged models.Participant
+	if err := mergeInto(document, patch, &merged); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge patch", "details": err.Error()})
+		return
+	}
+
+	// Validate only the merged result
+	merged.ID = id
+	if err := validateParticipant(merged); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Collect the columns that actually changed
+	changes := map[string]interface{}{}
+	if merged.Name != current.Name {
+		changes["name"] = merged.Name
+	}
+	if merged.Email != current.Email {
+		changes["email"] = merged.Email
+	}
+	if merged.Locale == "" {
+		merged.Locale = models.DefaultLocale
+	}
+	if merged.Locale != current.Locale {
+		changes["locale"] = merged.Locale
+	}
+	if !sameDate(merged.DateOfBirth, current.DateOfBirth) {
+		changes["date_of_birth"] = merged.DateOfBirth
+	}
+	for column, values := range map[string][2]string{
+		"gender":         {merged.Gender, current.Gender},
+		"club":           {merged.Club, current.Club},
+		"nationality":    {merged.Nationality, current.Nationality},
+		"federation":     {merged.Federation, current.Federation},
+		"license_number": {merged.LicenseNumber, current.LicenseNumber},
+	} {
+		if values[0] != values[1] {
+			changes[column] = values[0]
+		}
+	}
+
+	participant, err := models.PatchParticipant(changeMeta(c), id, changes, version)
+	if err != nil {
+		if err.Error() == "participant not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else if err.Error() == "participant has been modified" {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		} else if err.Error() == "license number already registered" {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update participant", "details": err.Error()})
+		}
+		return
+	}
+
+	// Invalidate cache
+	models.DeleteCache("participants:all")
+	models.DeleteCache("participants:" + strconv.Itoa(id))
+
+	c.Header("ETag", versionETag(participant.Version))
+	c.JSON(http.StatusOK, participant)
+}
+
+// RestoreParticipant handles requests to restore a deleted participant
+func RestoreParticipant(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	participant, err := models.RestoreParticipant(changeMeta(c), id)
+	if err != nil {
+		if err.Error() == "deleted participant not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else if err.Error() == "email already registered" || err.Error() == "license number already registered" {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore participant", "details": err.Error()})
+		}
+		return
+	}
+
+	// Invalidate cache
+	models.DeleteCache("participants:all")
+	models.DeleteCache("participants:" + strconv.Itoa(id))
+
+	c.Header("ETag", versionETag(participant.Version))
+	c.JSON(http.StatusOK, participant)
+}
+
+// RestoreParticipantToCompetition handles requests to restore a removed competition registration
+func RestoreParticipantToCompetition(c *gin.Context) {
+	participantID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	competitionID, err := strconv.Atoi(c.Param("competition_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	if err := models.RestoreParticipantToCompetition(changeMeta(c), participantID, competitionID); err != nil {
+		if err.Error() == "removed registration not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore registration", "details": err.Error()})
+		}
+		return
+	}
+
+	// Invalidate cache
+	models.DeleteCache("participants:all")
+	models.DeleteCache("participants:competition:" + strconv.Itoa(competitionID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Registration restored successfully"})
+}
+
+// FindDuplicateParticipants handles requests for participants that are likely
+// registered more than once
+func FindDuplicateParticipants(c *gin.Context) {
+	minScore := models.DefaultDuplicateScore
+	if value := c.Query("min_score"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_score must be a number between 0 and 1"})
+			return
+		}
+		minScore = parsed
+	}
+
+	candidates, err := models.FindDuplicateParticipants(minScore)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find duplicates", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, candidates)
+}
+
+// MergeParticipant handles requests to merge a duplicate participant into the
+// participant in the URL
+func MergeParticipant(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	var data struct {
+		DuplicateID int `json:"duplicate_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	result, err := models.MergeParticipants(changeMeta(c), id, data.DuplicateID)
+	if err != nil {
+		switch err.Error() {
+		case "cannot merge a participant into itself":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case "participant not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "both participants have results in the same competition", "both participants have matches in the same competition":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge participants", "details": err.Error()})
+		}
+		return
+	}
+
+	// Invalidate cache
+	models.DeleteCache("participants:all")
+	models.DeleteCache("participants:" + strconv.Itoa(id))
+	models.DeleteCache("participants:" + strconv.Itoa(data.DuplicateID))
+	for _, competitionID := range result.Competitions {
+		models.DeleteCache("participants:competition:" + strconv.Itoa(competitionID))
+	}
+
+	c.Header("ETag", versionETag(result.Participant.Version))
+	c.JSON(http.StatusOK, result)
+}
+
+// sameDate reports whether two optional dates are the same day
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readMergePatch reads an RFC 7396 JSON merge patch from the request body and
+// rejects members that are not in the list of patchable fields
+func readMergePatch(c *gin.Context, fields ...string) (map[string]interface{}, int, error) {
+	if contentType := c.GetHeader("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+			return nil, http.StatusUnsupportedMediaType, errors.New("content type must be application/merge-patch+json")
+		}
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return nil, http.StatusBadRequest, errors.New("merge patch must be a JSON object")
+	}
+
+	allowed := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		allowed[field] = true
+	}
+	for field := range patch {
+		if !allowed[field] {
+			return nil, http.StatusBadRequest, errors.New("field cannot be patched: " + field)
+		}
+	}
+
+	return patch, http.StatusOK, nil
+}
+
+// applyMergePatch applies a merge patch to a target document following RFC 7396
+func applyMergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = applyMergePatch(targetObject[key], value)
+		}
+	}
+
+	return targetObject
+}
+
+// mergeInto applies a merge patch to a document and decodes the result into out
+func mergeInto(document map[string]interface{}, patch map[string]interface{}, out interface{}) error {
+	merged, err := json.Marshal(applyMergePatch(document, patch))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(merged, out)
+}
+
+// sameInt reports whether two optional integers are equal
+func sameInt(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetParticipantRatings handles requests for a participant's ratings and
+// rating history, optionally for a single discipline
+func GetParticipantRatings(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	ratings, err := models.GetParticipantRatings(id, c.Query("discipline"))
+	if err != nil {
+		if err.Error() == "participant not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ratings", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ratings)
+}
+
+// RecomputeRatings handles admin requests to rebuild the ratings of one
+// discipline, or of all of them, from the recorded match results
+func RecomputeRatings(c *gin.Context) {
+	disciplines, err := models.RecomputeRatings(c.Query("discipline"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recompute ratings", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ratings recomputed successfully", "disciplines": disciplines})
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventQuery returns the event query parameter, or nil if it was not given.
+// An empty event selects competition scores.
+func eventQuery(c *gin.Context) *string {
+	event, ok := c.GetQuery("event")
+	if !ok {
+		return nil
+	}
+	return &event
+}
+
+// GetPersonalBests handles requests for a participant's personal bests,
+// optionally for a single discipline
+func GetPersonalBests(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	bests, err := models.GetPersonalBests(id, c.Query("discipline"))
+	if err != nil {
+		if err.Error() == "participant not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve personal bests", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, bests)
+}
+
+// GetProgression handles requests for every mark of a participant, oldest
+// first, optionally for a single discipline and event
+func GetProgression(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		return
+	}
+
+	progression, err := models.GetProgression(id, c.Query("discipline"), eventQuery(c))
+	if err != nil {
+		if err.Error() == "participant not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve progression", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, progression)
+}
+
+// GetRecords handles requests for venue and series records, filtered by
+// scope, venue or series name, discipline and event
+func GetRecords(c *gin.Context) {
+	scope := c.Query("scope")
+	if scope != "" && scope != models.RecordVenue && scope != models.RecordSeries {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope (expected venue or series)"})
+		return
+	}
+
+	records, err := models.GetRecords(scope, c.Query("name"), c.Query("discipline"), eventQuery(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve records", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, records)
+}
+
+// GetCompetitionRecords handles requests for the records standing at a
+// competition's venue and in its series
+func GetCompetitionRecords(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	records, err := models.GetCompetitionRecords(id)
+	if err != nil {
+		if err.Error() == "competition not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve records", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, records)
+}
+
+// RecomputeRecords handles admin requests to rebuild the personal bests and
+// records of one discipline, or of all of them, from the recorded marks
+func RecomputeRecords(c *gin.Context) {
+	disciplines, err := models.RecomputeRecords(c.Query("discipline"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recompute records", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Records recomputed successfully", "disciplines": disciplines})
+}
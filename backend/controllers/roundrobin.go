@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"competition-app/validation"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// roundRobinSettings is the request body for the settings of a round-robin
+// league. Omitted points default to 3 for a win, 1 for a draw and 0 for a
+// loss; omitted tie-breakers to head-to-head, then score difference.
+type roundRobinSettings struct {
+	Legs        int      `json:"legs"`
+	PointsWin   *float64 `json:"points_win"`
+	PointsDraw  *float64 `json:"points_draw"`
+	PointsLoss  *float64 `json:"points_loss"`
+	TieBreakers []string `json:"tie_breakers"`
+}
+
+// bindRoundRobin reads and validates round-robin settings from the request
+// body. It writes the error response and returns false if they are invalid.
+func bindRoundRobin(c *gin.Context, competitionID int) (models.RoundRobin, bool) {
+	var data roundRobinSettings
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return models.RoundRobin{}, false
+	}
+
+	settings := models.RoundRobin{
+		CompetitionID: competitionID,
+		Legs:          data.Legs,
+		PointsWin:     3,
+		PointsDraw:    1,
+		PointsLoss:    0,
+		TieBreakers:   data.TieBreakers,
+	}
+	if settings.Legs == 0 {
+		settings.Legs = 1
+	}
+	if data.PointsWin != nil {
+		settings.PointsWin = *data.PointsWin
+	}
+	if data.PointsDraw != nil {
+		settings.PointsDraw = *data.PointsDraw
+	}
+	if data.PointsLoss != nil {
+		settings.PointsLoss = *data.PointsLoss
+	}
+	if settings.TieBreakers == nil {
+		settings.TieBreakers = []string{models.TieBreakHeadToHead, models.TieBreakScoreDifference}
+	}
+
+	validationObj := validation.RoundRobin{
+		Legs:        settings.Legs,
+		PointsWin:   settings.PointsWin,
+		PointsDraw:  settings.PointsDraw,
+		PointsLoss:  settings.PointsLoss,
+		TieBreakers: settings.TieBreakers,
+	}
+	if err := validation.ValidateRoundRobin(&validationObj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.RoundRobin{}, false
+	}
+
+	return settings, true
+}
+
+// GetRoundRobin handles requests for the schedule of a competition's
+// round-robin league
+func GetRoundRobin(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	schedule, err := models.GetRoundRobin(competitionID)
+	if err != nil {
+		if err.Error() == "round robin not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve round robin", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// GenerateRoundRobin handles requests to schedule a round-robin league among
+// the participants registered for a competition
+func GenerateRoundRobin(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	settings, ok := bindRoundRobin(c, competitionID)
+	if !ok {
+		return
+	}
+
+	schedule, err := models.GenerateRoundRobin(changeMeta(c), settings)
+	if err != nil {
+		switch err.Error() {
+		case "competition does not exist":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "round robin already exists":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case "a round robin needs at least two participants":
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate round robin", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// UpdateRoundRobin handles requests to change the points and tie-breakers of
+// a round-robin league. The number of legs cannot be changed once scheduled.
+func UpdateRoundRobin(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	settings, ok := bindRoundRobin(c, competitionID)
+	if !ok {
+		return
+	}
+
+	if err := models.UpdateRoundRobinSettings(changeMeta(c), &settings); err != nil {
+		if err.Error() == "round robin not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update round robin", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// DeleteRoundRobin handles requests to discard the round-robin league of a
+// competition
+func DeleteRoundRobin(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	if err := models.DeleteRoundRobin(changeMeta(c), competitionID); err != nil {
+		if err.Error() == "round robin not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete round robin", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Round robin deleted successfully"})
+}
+
+// RecordRoundRobinResult handles requests to enter or correct the result of
+// a round-robin match
+func RecordRoundRobinResult(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	matchID, err := strconv.Atoi(c.Param("match_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid match ID"})
+		return
+	}
+
+	var data struct {
+		Score1 *float64 `json:"score1" binding:"required"`
+		Score2 *float64 `json:"score2" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	match, err := models.RecordRoundRobinResult(changeMeta(c), competitionID, matchID, *data.Score1, *data.Score2)
+	if err != nil {
+		if err.Error() == "match not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record match result", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, match)
+}
+
+// GetRoundRobinStandings handles requests for the standings table of a
+// competition's round-robin league
+func GetRoundRobinStandings(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	standings, err := models.GetRoundRobinStandings(competitionID)
+	if err != nil {
+		if err.Error() == "round robin not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve standings", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, standings)
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"competition-app/middleware"
+	"competition-app/models"
+	"competition-app/scoring"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range middleware.AllowedOrigins {
+			if origin == allowed {
+				return true
+			}
+		}
+		return false
+	},
+}
+
+// GetStandings handles requests to get the current standings of a competition
+func GetStandings(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	if !models.CompetitionExists(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "competition not found"})
+		return
+	}
+
+	standings, err := models.GetStandings(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve standings", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, standings)
+}
+
+// ScoringChannel handles WebSocket connections to a competition's live scoring
+// channel. Officials authenticate with their token (the token query parameter
+// or a bearer token) to submit results; everyone else receives standings only.
+func ScoringChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	var lastSeq int64
+	if lastSeqStr := c.Query("last_seq"); lastSeqStr != "" {
+		lastSeq, err = strconv.ParseInt(lastSeqStr, 10, 64)
+		if err != nil || lastSeq < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last_seq"})
+			return
+		}
+	}
+
+	if !models.CompetitionExists(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "competition not found"})
+		return
+	}
+
+	var official *models.Official
+	token := c.Query("token")
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	if token != "" {
+		o, err := models.GetOfficialByToken(token)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid official token"})
+			return
+		}
+		if !o.CanScore(id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Official is not assigned to this competition"})
+			return
+		}
+		official = &o
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		return
+	}
+
+	scoring.Serve(conn, id, official, c.GetString(middleware.RequestIDKey), lastSeq)
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"competition-app/validation"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondSeedingError writes the response for an error from the seeding
+// models
+func respondSeedingError(c *gin.Context, err error, message string) {
+	switch err.Error() {
+	case "competition does not exist", "seeding not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case "seeding is locked":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case "event not found", "previous competition not found", "seeded participant is not registered for this competition":
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
+
+// GetSeeding handles requests for the seeding of a competition, with its
+// participants in seed order
+func GetSeeding(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	seeding, err := models.GetSeeding(competitionID)
+	if err != nil {
+		respondSeedingError(c, err, "Failed to retrieve seeding")
+		return
+	}
+
+	c.JSON(http.StatusOK, seeding)
+}
+
+// ComputeSeeding handles requests to (re)compute the seeds of a competition's
+// participants. Sources default to rating alone and previous results to
+// highest first.
+func ComputeSeeding(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	var data struct {
+		Sources               []string `json:"sources"`
+		Manual                []int    `json:"manual"`
+		EventID               *int     `json:"event_id"`
+		PreviousCompetitionID *int     `json:"previous_competition_id"`
+		PreviousResultOrder   string   `json:"previous_result_order"`
+		Lock                  bool     `json:"lock"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	if data.Sources == nil {
+		data.Sources = []string{models.SeedRating}
+	}
+	if data.Manual == nil {
+		data.Manual = []int{}
+	}
+	if data.PreviousResultOrder == "" {
+		data.PreviousResultOrder = models.HighestFirst
+	}
+
+	validationObj := validation.Seeding{
+		Sources:               data.Sources,
+		Manual:                data.Manual,
+		EventID:               data.EventID,
+		PreviousCompetitionID: data.PreviousCompetitionID,
+		PreviousResultOrder:   data.PreviousResultOrder,
+	}
+	if err := validation.ValidateSeeding(&validationObj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings := models.Seeding{
+		CompetitionID:         competitionID,
+		Sources:               data.Sources,
+		Manual:                data.Manual,
+		EventID:               data.EventID,
+		PreviousCompetitionID: data.PreviousCompetitionID,
+		PreviousResultOrder:   data.PreviousResultOrder,
+	}
+
+	seeding, err := models.ComputeSeeding(changeMeta(c), settings, data.Lock)
+	if err != nil {
+		respondSeedingError(c, err, "Failed to compute seeding")
+		return
+	}
+
+	c.JSON(http.StatusOK, seeding)
+}
+
+// LockSeeding handles requests to lock the seeding of a competition so it
+// is not recomputed
+func LockSeeding(c *gin.Context) {
+	setSeedingLocked(c, true)
+}
+
+// UnlockSeeding handles requests to unlock the seeding of a competition
+func UnlockSeeding(c *gin.Context) {
+	setSeedingLocked(c, false)
+}
+
+func setSeedingLocked(c *gin.Context, locked bool) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	seeding, err := models.SetSeedingLocked(changeMeta(c), competitionID, locked)
+	if err != nil {
+		respondSeedingError(c, err, "Failed to update seeding")
+		return
+	}
+
+	c.JSON(http.StatusOK, seeding)
+}
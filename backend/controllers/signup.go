@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"competition-app/auth"
+	"competition-app/middleware"
+	"competition-app/models"
+	"competition-app/validation"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenRequest is the body of requests redeeming an emailed token
+type tokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// respondWithSession signs a participant in by issuing a session token
+func respondWithSession(c *gin.Context, status int, participant models.Participant) {
+	token, claims, err := auth.Issue(auth.PurposeSession, middleware.Session{ParticipantID: participant.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session", "details": err.Error()})
+		return
+	}
+
+	// Sign-in links are one-time, so a session must never be replayed
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, gin.H{
+		"participant":   participant,
+		"session_token": token,
+		"expires_at":    claims.Expiry(),
+	})
+}
+
+// RequestSignup handles public self-registration requests. Nothing is created
+// yet; a confirmation link is emailed to the given address instead.
+func RequestSignup(c *gin.Context) {
+	var signup models.Signup
+	if err := c.ShouldBindJSON(&signup); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	signup.Name = strings.TrimSpace(signup.Name)
+	signup.Email = models.NormalizeEmail(signup.Email)
+
+	validationObj := validation.Participant{
+		Name:   signup.Name,
+		Email:  signup.Email,
+		Locale: signup.Locale,
+	}
+
+	if err := validation.ValidateParticipant(&validationObj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if signup.CompetitionID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "competition_id is required"})
+		return
+	}
+
+	competition, err := models.GetCompetition(signup.CompetitionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, _, err := auth.Issue(auth.PurposeSignup, signup)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create confirmation link", "details": err.Error()})
+		return
+	}
+
+	data := models.NotificationData{
+		CompetitionID:   competition.ID,
+		CompetitionName: competition.Name,
+		Description:     competition.Description,
+		Date:            competition.Date.Format("2006-01-02"),
+		Location:        competition.Location,
+		Link:            auth.Link("/signup/confirm", token),
+	}
+
+	if err := models.QueueEmail(signup.Email, signup.Name, signup.Locale, models.NotificationVerifyEmail, nil, data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send confirmation email", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Check your email to confirm your registration"})
+}
+
+// ConfirmSignup handles requests redeeming a self-registration confirmation
+// link. The participant is created and registered, then signed in.
+func ConfirmSignup(c *gin.Context) {
+	var data tokenRequest
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	var signup models.Signup
+	claims, err := auth.Verify(data.Token, auth.PurposeSignup, &signup)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation link"})
+		return
+	}
+
+	meta := changeMeta(c)
+	meta.Actor = "signup"
+
+	participant, err := models.ConfirmSignup(meta, claims.Nonce, claims.Expiry(), signup)
+	if err != nil {
+		switch err.Error() {
+		case "token already used":
+			c.JSON(http.StatusConflict, gin.H{"error": "This confirmation link has already been used"})
+		case "competition does not exist":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "participant matches no category":
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm registration", "details": err.Error()})
+		}
+		return
+	}
+
+	// Invalidate cache
+	models.DeleteCache("participants:all")
+	models.DeleteCache("participants:competition:" + strconv.Itoa(signup.CompetitionID))
+
+	respondWithSession(c, http.StatusCreated, participant)
+}
+
+// RequestMagicLink handles requests for a sign-in link. The response is the
+// same whether or not the address belongs to a participant.
+func RequestMagicLink(c *gin.Context) {
+	var data struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	accepted := gin.H{"message": "If the address belongs to a participant, a sign-in link is on its way"}
+
+	participant, err := models.GetParticipantByEmail(data.Email)
+	if err != nil {
+		if err.Error() != "participant not found" {
+			log.Printf("Warning: failed to look up participant for sign-in: %v", err)
+		}
+		c.JSON(http.StatusAccepted, accepted)
+		return
+	}
+
+	token, _, err := auth.Issue(auth.PurposeLogin, middleware.Session{ParticipantID: participant.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sign-in link", "details": err.Error()})
+		return
+	}
+
+	notification := models.NotificationData{Link: auth.Link("/sign-in", token)}
+	if err := models.QueueEmail(participant.Email, participant.Name, participant.Locale, models.NotificationMagicLink, &participant.ID, notification); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send sign-in email", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, accepted)
+}
+
+// VerifyMagicLink handles requests redeeming a sign-in link for a session
+func VerifyMagicLink(c *gin.Context) {
+	var data tokenRequest
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	var session middleware.Session
+	claims, err := auth.Verify(data.Token, auth.PurposeLogin, &session)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in link"})
+		return
+	}
+
+	if err := models.UseToken(claims.Nonce, claims.Expiry()); err != nil {
+		if err.Error() == "token already used" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "This sign-in link has already been used"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in", "details": err.Error()})
+		}
+		return
+	}
+
+	participant, err := models.GetParticipant(session.ParticipantID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired sign-in link"})
+		return
+	}
+
+	respondWithSession(c, http.StatusOK, participant)
+}
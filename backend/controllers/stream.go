@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	streamHeartbeatInterval = 15 * time.Second
+	streamReplayLimit       = 1000
+)
+
+// StreamCompetition handles Server-Sent Events requests streaming live changes
+// to a competition and its registrations. Clients reconnecting with a
+// Last-Event-ID header first receive the events they missed. Outbox IDs are
+// assigned when an event is recorded, not when its transaction commits, so an
+// event from a transaction that committed after a later ID was sent is not
+// replayed; clients that must not miss anything should reload the competition
+// after reconnecting.
+func StreamCompetition(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	var lastEventID int64
+	if lastEventIDStr := c.GetHeader("Last-Event-ID"); lastEventIDStr != "" {
+		lastEventID, err = strconv.ParseInt(lastEventIDStr, 10, 64)
+		if err != nil || lastEventID < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+	}
+
+	if _, err := models.GetCompetition(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Subscribe before replaying so nothing published in between is lost
+	pubsub, err := models.SubscribeCompetitionEvents(id)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Live updates are unavailable", "details": err.Error()})
+		return
+	}
+	defer pubsub.Close()
+
+	var missed []models.OutboxEvent
+	if lastEventID > 0 {
+		missed, err = models.GetCompetitionEventsSince(id, lastEventID, streamReplayLimit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve missed events", "details": err.Error()})
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprintf(c.Writer, "retry: 3000\n\n")
+	replayed := make(map[int64]bool, len(missed))
+	for _, event := range missed {
+		writeStreamEvent(c, event)
+		replayed[event.ID] = true
+	}
+	c.Writer.Flush()
+
+	messages := pubsub.Channel()
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprintf(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var event models.OutboxEvent
+			if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+				continue
+			}
+
+			// Skip events already sent during the replay
+			if replayed[event.ID] {
+				continue
+			}
+
+			writeStreamEvent(c, event)
+			c.Writer.Flush()
+		}
+	}
+}
+
+// writeStreamEvent writes a domain event in Server-Sent Events format
+func writeStreamEvent(c *gin.Context, event models.OutboxEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
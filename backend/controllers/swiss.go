@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gameResults maps the accepted game results to the points of white and black
+var gameResults = map[string][2]float64{
+	"1-0":     {1, 0},
+	"0-1":     {0, 1},
+	"1/2-1/2": {0.5, 0.5},
+}
+
+// GetSwissRounds handles requests for the rounds and pairings of a
+// competition's Swiss-system tournament
+func GetSwissRounds(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	if !models.CompetitionExists(competitionID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "competition not found"})
+		return
+	}
+
+	rounds, err := models.GetSwissRounds(competitionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve rounds", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rounds)
+}
+
+// GenerateSwissRound handles requests to pair the next round
+func GenerateSwissRound(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	round, err := models.GenerateSwissRound(changeMeta(c), competitionID)
+	if err != nil {
+		switch err.Error() {
+		case "competition does not exist":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "current round is not finished":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case "a round needs at least two participants", "no pairing possible without repeat opponents":
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate round", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, round)
+}
+
+// RecordSwissResult handles requests to enter or correct the result of a game
+func RecordSwissResult(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	gameID, err := strconv.Atoi(c.Param("game_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid game ID"})
+		return
+	}
+
+	var data struct {
+		Result string `json:"result" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	points, ok := gameResults[data.Result]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid result (expected 1-0, 0-1 or 1/2-1/2)"})
+		return
+	}
+
+	game, err := models.RecordSwissResult(changeMeta(c), competitionID, gameID, points[0], points[1])
+	if err != nil {
+		switch err.Error() {
+		case "game not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "a bye has no result to record":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record result", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, game)
+}
+
+// GetSwissStandings handles requests for the standings of a competition's
+// Swiss-system tournament
+func GetSwissStandings(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	if !models.CompetitionExists(competitionID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "competition not found"})
+		return
+	}
+
+	standings, err := models.GetSwissStandings(competitionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve standings", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, standings)
+}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"competition-app/validation"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindTeam reads and validates a team and its roster from the request body
+func bindTeam(c *gin.Context) (models.Team, bool) {
+	var team models.Team
+	if err := c.ShouldBindJSON(&team); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return team, false
+	}
+
+	validationObj := validation.Team{Name: team.Name}
+	for i := range team.Members {
+		if team.Members[i].Role == "" {
+			team.Members[i].Role = "member"
+		}
+		validationObj.Members = append(validationObj.Members, validation.TeamMember{
+			ParticipantID: team.Members[i].ParticipantID,
+			Role:          team.Members[i].Role,
+		})
+	}
+
+	if err := validation.ValidateTeam(&validationObj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return team, false
+	}
+
+	return team, true
+}
+
+// teamParams parses the competition and team IDs from the URL
+func teamParams(c *gin.Context) (int, int, bool) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return 0, 0, false
+	}
+
+	teamID, err := strconv.Atoi(c.Param("team_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+		return 0, 0, false
+	}
+
+	return competitionID, teamID, true
+}
+
+// respondTeamError maps the errors of saving a team to a response
+func respondTeamError(c *gin.Context, err error, action string) {
+	switch err.Error() {
+	case "competition does not exist", "team not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case "team name already used", "participant already on another team in this competition":
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case "team has too few members", "team has too many members", "participant does not exist", "participant matches no category":
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " team", "details": err.Error()})
+	}
+}
+
+// GetTeams handles requests to list the teams of a competition
+func GetTeams(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	if !models.CompetitionExists(competitionID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "competition not found"})
+		return
+	}
+
+	teams, err := models.GetTeams(competitionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve teams", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, teams)
+}
+
+// GetTeam handles requests to get a team with its roster
+func GetTeam(c *gin.Context) {
+	competitionID, teamID, ok := teamParams(c)
+	if !ok {
+		return
+	}
+
+	team, err := models.GetTeam(competitionID, teamID)
+	if err != nil {
+		if err.Error() == "team not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve team", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// CreateTeam handles requests to register a whole team for a competition
+func CreateTeam(c *gin.Context) {
+	competitionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	team, ok := bindTeam(c)
+	if !ok {
+		return
+	}
+	team.CompetitionID = competitionID
+
+	registered, err := models.CreateTeam(changeMeta(c), &team)
+	if err != nil {
+		respondTeamError(c, err, "create")
+		return
+	}
+
+	if registered {
+		models.DeleteCache("participants:all")
+		models.DeleteCache("participants:competition:" + strconv.Itoa(competitionID))
+	}
+
+	c.JSON(http.StatusCreated, team)
+}
+
+// UpdateTeam handles requests to rename a team or replace its roster
+func UpdateTeam(c *gin.Context) {
+	competitionID, teamID, ok := teamParams(c)
+	if !ok {
+		return
+	}
+
+	team, ok := bindTeam(c)
+	if !ok {
+		return
+	}
+	team.ID = teamID
+	team.CompetitionID = competitionID
+
+	registered, err := models.UpdateTeam(changeMeta(c), &team)
+	if err != nil {
+		respondTeamError(c, err, "update")
+		return
+	}
+
+	if registered {
+		models.DeleteCache("participants:all")
+		models.DeleteCache("participants:competition:" + strconv.Itoa(competitionID))
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// DeleteTeam handles requests to remove a team from a competition
+func DeleteTeam(c *gin.Context) {
+	competitionID, teamID, ok := teamParams(c)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteTeam(changeMeta(c), competitionID, teamID); err != nil {
+		if err.Error() == "team not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete team", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team deleted successfully"})
+}
+
+// GetTeamStandings handles requests to get the team results of a competition
+func GetTeamStandings(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid competition ID"})
+		return
+	}
+
+	if !models.CompetitionExists(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "competition not found"})
+		return
+	}
+
+	standings, err := models.GetTeamStandings(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve team standings", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, standings)
+}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"competition-app/models"
+	"competition-app/validation"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookRequest is the body accepted when creating or updating a subscription
+type webhookRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types"`
+	Active     *bool    `json:"active"`
+}
+
+// validateWebhookRequest checks the subscription URL and event types
+func validateWebhookRequest(c *gin.Context, data *webhookRequest) bool {
+	validationObj := validation.WebhookSubscription{
+		URL:        data.URL,
+		EventTypes: data.EventTypes,
+	}
+
+	if err := validation.ValidateWebhookSubscription(&validationObj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	for _, eventType := range data.EventTypes {
+		if !models.IsEventType(eventType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown event type: " + eventType, "event_types": models.EventTypes})
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetWebhooks handles requests to list all webhook subscriptions
+func GetWebhooks(c *gin.Context) {
+	subscriptions, err := models.GetAllWebhookSubscriptions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhooks", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// GetWebhook handles requests to get a specific webhook subscription
+func GetWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	subscription, err := models.GetWebhookSubscription(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+// CreateWebhook handles requests to create a webhook subscription. The signing
+// secret is generated unless provided and is only returned in this response.
+func CreateWebhook(c *gin.Context) {
+	var data webhookRequest
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	if !validateWebhookRequest(c, &data) {
+		return
+	}
+
+	subscription := models.WebhookSubscription{
+		URL:        data.URL,
+		Secret:     data.Secret,
+		EventTypes: data.EventTypes,
+		Active:     data.Active == nil || *data.Active,
+	}
+
+	if subscription.Secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook secret", "details": err.Error()})
+			return
+		}
+		subscription.Secret = hex.EncodeToString(buf)
+	}
+
+	if err := models.CreateWebhookSubscription(&subscription); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook", "details": err.Error()})
+		return
+	}
+
+	// The secret is only ever shown here, so the response must not be stored
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// UpdateWebhook handles requests to update a webhook subscription
+func UpdateWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	var data webhookRequest
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	if !validateWebhookRequest(c, &data) {
+		return
+	}
+
+	subscription := models.WebhookSubscription{
+		ID:         id,
+		URL:        data.URL,
+		EventTypes: data.EventTypes,
+		Active:     data.Active == nil || *data.Active,
+	}
+
+	if err := models.UpdateWebhookSubscription(&subscription); err != nil {
+		if err.Error() == "webhook subscription not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+// DeleteWebhook handles requests to delete a webhook subscription
+func DeleteWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	if err := models.DeleteWebhookSubscription(id); err != nil {
+		if err.Error() == "webhook subscription not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries handles requests to list the delivery history of a subscription
+func GetWebhookDeliveries(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && status != models.DeliveryPending && status != models.DeliveryDelivered && status != models.DeliveryDead {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery status"})
+		return
+	}
+
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit (must be between 1 and 1000)"})
+			return
+		}
+	}
+
+	if _, err := models.GetWebhookSubscription(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	deliveries, err := models.GetWebhookDeliveries(id, status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deliveries", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RetryWebhookDelivery handles requests to requeue a dead-lettered delivery
+func RetryWebhookDelivery(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	if err := models.RetryWebhookDelivery(id, deliveryID); err != nil {
+		if err.Error() == "dead delivery not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry delivery", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Delivery queued for retry"})
+}
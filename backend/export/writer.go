@@ -0,0 +1,144 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Supported export formats
+const (
+	FormatCSV   = "csv"
+	FormatXLSX  = "xlsx"
+	FormatJSONL = "jsonl"
+)
+
+// Writer streams tables of rows in an export format. Rows are written as they
+// arrive, so an export never has to be held in memory as a whole.
+type Writer interface {
+	// BeginTable starts a new table with the given column names
+	BeginTable(name string, columns []string) error
+	// WriteRow writes one row of the current table, in column order
+	WriteRow(values []interface{}) error
+	// Close finishes the export; it does not close the underlying writer
+	Close() error
+}
+
+// ContentType returns the MIME type of an export format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	}
+	return "application/octet-stream"
+}
+
+// IsFormat checks if the given name is a supported export format
+func IsFormat(format string) bool {
+	return format == FormatCSV || format == FormatXLSX || format == FormatJSONL
+}
+
+// SupportsTables checks if a format can hold more than one table
+func SupportsTables(format string) bool {
+	return format != FormatCSV
+}
+
+// NewWriter creates a Writer for the given format. JSON Lines exports of more
+// than one table tag every row with its table name.
+func NewWriter(format string, w io.Writer, multiTable bool) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w), nil
+	case FormatJSONL:
+		buf := bufio.NewWriter(w)
+		return &jsonlWriter{buf: buf, enc: json.NewEncoder(buf), tagged: multiTable}, nil
+	}
+	return nil, errors.New("unsupported export format")
+}
+
+// formatValue converts a database value to its text form for CSV and XLSX
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
+
+type csvWriter struct {
+	w     *csv.Writer
+	began bool
+	row   []string
+}
+
+func (c *csvWriter) BeginTable(name string, columns []string) error {
+	if c.began {
+		return errors.New("csv exports hold a single table")
+	}
+	c.began = true
+	c.row = make([]string, len(columns))
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) WriteRow(values []interface{}) error {
+	for i, value := range values {
+		c.row[i] = formatValue(value)
+	}
+	return c.w.Write(c.row)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	buf     *bufio.Writer
+	enc     *json.Encoder
+	tagged  bool
+	table   string
+	columns []string
+}
+
+func (j *jsonlWriter) BeginTable(name string, columns []string) error {
+	j.table = name
+	j.columns = columns
+	return nil
+}
+
+func (j *jsonlWriter) WriteRow(values []interface{}) error {
+	row := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		row[j.columns[i]] = value
+	}
+
+	if j.tagged {
+		return j.enc.Encode(map[string]interface{}{"table": j.table, "row": row})
+	}
+	return j.enc.Encode(row)
+}
+
+func (j *jsonlWriter) Close() error {
+	return j.buf.Flush()
+}
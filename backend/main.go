@@ -1,10 +1,15 @@
 package main
 
 import (
+	"competition-app/auth"
 	"competition-app/config"
 	"competition-app/models"
+	"competition-app/notifications"
 	"competition-app/routes"
+	"competition-app/validation"
+	"competition-app/webhooks"
 	"log"
+	"time"
 )
 
 func main() {
@@ -20,6 +25,21 @@ func main() {
 	}
 	defer models.DB.Close()
 
+	// Set up signing of emailed links and participant sessions
+	if err := auth.Init(cfg); err != nil {
+		log.Fatalf("Error initializing token signing: %v", err)
+	}
+
+	// Set up the license number formats of the configured federations
+	if err := validation.SetLicenseFormats(cfg.LicenseFormats); err != nil {
+		log.Fatalf("Error loading license formats: %v", err)
+	}
+
+	// Set up the rating system, recomputing ratings computed with another one
+	if err := models.InitRatings(cfg.RatingSystem); err != nil {
+		log.Fatalf("Error initializing ratings: %v", err)
+	}
+
 	// Initialize Redis connection
 	if err := models.InitRedis(cfg); err != nil {
 		log.Printf("Warning: Redis connection failed: %v", err)
@@ -27,8 +47,37 @@ func main() {
 	}
 	defer models.CloseRedis()
 
+	// Periodically remove expired idempotency keys and used tokens, old events,
+	// webhook deliveries and sent notifications, and purge the trash
+	go runPeriodically(time.Hour, "expired idempotency keys", models.PurgeExpiredIdempotencyKeys)
+	go runPeriodically(time.Hour, "used tokens", models.PurgeUsedTokens)
+	go runPeriodically(time.Hour, "deleted records", func() (int64, error) {
+		return models.PurgeDeleted(cfg.SoftDeleteRetention)
+	})
+	go runPeriodically(time.Hour, "finished webhook deliveries", func() (int64, error) {
+		return models.PurgeFinishedDeliveries(cfg.DeliveryRetention)
+	})
+	go runPeriodically(time.Hour, "dispatched events", func() (int64, error) {
+		return models.PurgeDispatchedEvents(cfg.DeliveryRetention)
+	})
+	go runPeriodically(time.Hour, "sent notifications", func() (int64, error) {
+		return models.PurgeSentNotifications(cfg.DeliveryRetention)
+	})
+
+	// Deliver domain events to webhook subscribers
+	go webhooks.NewDispatcher(cfg).Run()
+
+	// Send queued email notifications
+	if dispatcher, err := notifications.NewDispatcher(cfg); err != nil {
+		log.Printf("Warning: email notifications disabled: %v", err)
+	} else if dispatcher == nil {
+		log.Printf("Warning: SMTP_HOST is not set, email notifications stay queued")
+	} else {
+		go dispatcher.Run()
+	}
+
 	// Initialize router
-	router := routes.SetupRouter()
+	router := routes.SetupRouter(cfg)
 
 	// Start the server
 	port := cfg.ServerPort
@@ -37,3 +86,17 @@ func main() {
 		log.Fatalf("Error starting server: %v", err)
 	}
 }
+
+// runPeriodically runs a purge task at the given interval and logs its outcome
+func runPeriodically(interval time.Duration, name string, purge func() (int64, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if n, err := purge(); err != nil {
+			log.Printf("Warning: failed to purge %s: %v", name, err)
+		} else if n > 0 {
+			log.Printf("Purged %d %s", n, name)
+		}
+	}
+}
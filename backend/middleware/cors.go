@@ -6,12 +6,15 @@ import (
 	"time"
 )
 
+// AllowedOrigins lists the browser origins allowed to call the API
+var AllowedOrigins = []string{"http://localhost:7788"}
+
 func CORSMiddleware() gin.HandlerFunc {
 	return cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:7788"},
+		AllowOrigins:     AllowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Idempotency-Key", "If-Match", "If-None-Match", "X-Request-ID", "X-Actor", "Last-Event-ID"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed", "ETag", "X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	})
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bytes"
+	"competition-app/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+// maxIdempotentBodySize caps the bodies of requests sent with an
+// Idempotency-Key, which are read into memory to be hashed
+const maxIdempotentBodySize = 8 << 20
+
+// responseRecorder captures the response body while still writing it to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware stores the response of mutating requests sent with an
+// Idempotency-Key header and replays it when the same key is used again by the
+// same client on the same route. Responses sent with Cache-Control: no-store,
+// such as those carrying credentials, are not stored; using their key again is
+// rejected instead.
+func IdempotencyMiddleware(ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if key == "" || !isMutatingMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long (maximum 255 characters)"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			} else {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			}
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		bodyHash := sha256.Sum256(body)
+		record := models.IdempotencyKey{
+			Key:         key,
+			Scope:       clientScope(c),
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			RequestHash: hex.EncodeToString(bodyHash[:]),
+		}
+
+		reserved, existing, err := models.ReserveIdempotencyKey(&record, ttl)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to process idempotency key", "details": err.Error()})
+			return
+		}
+
+		if !reserved {
+			if existing.RequestHash != record.RequestHash {
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key has already been used with a different request body"})
+				return
+			}
+			if !existing.Completed {
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+				return
+			}
+			if !existing.Replayable {
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key has already been processed and its response cannot be replayed"})
+				return
+			}
+
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(existing.StatusCode, existing.ContentType, existing.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+
+		defer func() {
+			// Server errors and panics are not stored, so the client can retry
+			if r := recover(); r != nil {
+				releaseIdempotencyKey(&record)
+				panic(r)
+			}
+			if recorder.Status() >= http.StatusInternalServerError {
+				releaseIdempotencyKey(&record)
+				return
+			}
+
+			record.StatusCode = recorder.Status()
+			record.ContentType = recorder.Header().Get("Content-Type")
+			record.ResponseBody = recorder.body.Bytes()
+			record.Replayable = !strings.Contains(recorder.Header().Get("Cache-Control"), "no-store")
+			if err := models.CompleteIdempotencyKey(&record); err != nil {
+				// A key left pending would block retries until it expires
+				log.Printf("Warning: failed to store response for idempotency key %q: %v", record.Key, err)
+				releaseIdempotencyKey(&record)
+			}
+		}()
+
+		c.Next()
+	}
+}
+
+// releaseIdempotencyKey releases a key so the request can be retried, logging
+// if that fails
+func releaseIdempotencyKey(record *models.IdempotencyKey) {
+	if err := models.ReleaseIdempotencyKey(record); err != nil {
+		log.Printf("Warning: failed to release idempotency key %q: %v", record.Key, err)
+	}
+}
+
+// isMutatingMethod reports whether the HTTP method changes server state
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// clientScope identifies the caller so keys from different clients never collide
+func clientScope(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		return "auth:" + hex.EncodeToString(sum[:])
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"competition-app/auth"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParticipantIDKey is the context key holding the signed-in participant's ID
+const ParticipantIDKey = "participant_id"
+
+// Session is the data carried by a participant session token
+type Session struct {
+	ParticipantID int `json:"participant_id"`
+}
+
+// ParticipantAuthMiddleware restricts a route group to participants signed in
+// with a session token from a magic link or a confirmed self-registration
+func ParticipantAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+		var session Session
+		if _, err := auth.Verify(token, auth.PurposeSession, &session); err != nil || session.ParticipantID <= 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
+			return
+		}
+
+		c.Set(ParticipantIDKey, session.ParticipantID)
+		c.Set(ActorKey, "participant:"+strconv.Itoa(session.ParticipantID))
+		c.Next()
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireIfMatchMiddleware rejects updates sent without an If-Match header when
+// enabled, so clients cannot overwrite changes they have not seen
+func RequireIfMatchMiddleware(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if enabled && c.GetHeader("If-Match") == "" {
+			c.AbortWithStatusJSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+			return
+		}
+		c.Next()
+	}
+}
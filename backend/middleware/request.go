@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys set by the request middlewares
+const (
+	RequestIDKey = "request_id"
+	ActorKey     = "actor"
+)
+
+// clientActorPrefix namespaces the names clients give themselves, so they can
+// never pass for the admin, a participant or an official
+const clientActorPrefix = "client:"
+
+// RequestIDMiddleware assigns every request an ID, reusing the caller's
+// X-Request-ID when present, and echoes it in the response
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := strings.TrimSpace(c.GetHeader("X-Request-ID"))
+		if requestID == "" || len(requestID) > 64 {
+			buf := make([]byte, 16)
+			rand.Read(buf)
+			requestID = hex.EncodeToString(buf)
+		}
+
+		c.Set(RequestIDKey, requestID)
+		c.Header("X-Request-ID", requestID)
+		c.Next()
+	}
+}
+
+// ActorMiddleware records who is making the request. Callers holding the admin
+// token are "admin"; other clients identify themselves with the X-Actor header
+// and are recorded as "client:<name>".
+func ActorMiddleware(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := "anonymous"
+		if hasAdminToken(c, adminToken) {
+			actor = "admin"
+		} else if name := strings.TrimSpace(c.GetHeader("X-Actor")); name != "" && len(clientActorPrefix+name) <= 255 {
+			actor = clientActorPrefix + name
+		}
+
+		c.Set(ActorKey, actor)
+		c.Next()
+	}
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangeMeta identifies who made a change and in which request
+type ChangeMeta struct {
+	Actor     string
+	RequestID string
+}
+
+type AuditEntry struct {
+	ID         int                    `json:"id"`
+	Actor      string                 `json:"actor"`
+	RequestID  string                 `json:"request_id"`
+	EntityType string                 `json:"entity_type"`
+	EntityID   string                 `json:"entity_id"`
+	Action     string                 `json:"action"`
+	Before     json.RawMessage        `json:"before"`
+	After      json.RawMessage        `json:"after"`
+	Diff       map[string]AuditChange `json:"diff"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// AuditChange holds the old and new value of a single changed field
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditFilter narrows down the audit entries returned by GetAuditEntries
+type AuditFilter struct {
+	EntityType string
+	EntityID   string
+	Actor      string
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+// registrationEntityID builds the audit entity ID of a competition registration
+func registrationEntityID(competitionID, participantID int) string {
+	return strconv.Itoa(competitionID) + ":" + strconv.Itoa(participantID)
+}
+
+// recordAudit writes an audit entry as part of the caller's transaction.
+// before is nil for creations.
+func recordAudit(q querier, meta ChangeMeta, entityType, entityID, action string, before, after interface{}) error {
+	var beforeJson, afterJson []byte
+	var err error
+
+	if before != nil {
+		if beforeJson, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if afterJson, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+
+	_, err = q.Exec(`
+		INSERT INTO audit_log (actor, request_id, entity_type, entity_id, action, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, meta.Actor, meta.RequestID, entityType, entityID, action, nullableJSON(beforeJson), nullableJSON(afterJson))
+
+	return err
+}
+
+// nullableJSON maps an empty document to SQL NULL
+func nullableJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
+// GetAuditEntries retrieves audit entries matching the filter, newest first
+func GetAuditEntries(f AuditFilter) ([]AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	if f.EntityType != "" {
+		addCondition("entity_type = ?", f.EntityType)
+	}
+	if f.EntityID != "" {
+		addCondition("entity_id = ?", f.EntityID)
+	}
+	if f.Actor != "" {
+		addCondition("actor = ?", f.Actor)
+	}
+	if !f.From.IsZero() {
+		addCondition("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		addCondition("created_at < ?", f.To)
+	}
+
+	query := `
+		SELECT id, actor, request_id, entity_type, entity_id, action, before, after, created_at
+		FROM audit_log`
+	if len(conditions) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, f.Limit, f.Offset)
+	query += "\n\t\tORDER BY created_at DESC, id DESC\n\t\tLIMIT $" + strconv.Itoa(len(args)-1) + " OFFSET $" + strconv.Itoa(len(args))
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var before, after []byte
+		err := rows.Scan(&e.ID, &e.Actor, &e.RequestID, &e.EntityType, &e.EntityID, &e.Action, &before, &after, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if before != nil {
+			e.Before = before
+		}
+		if after != nil {
+			e.After = after
+		}
+		e.Diff = auditDiff(before, after)
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// auditDiff lists the top-level fields whose values differ between two snapshots
+func auditDiff(before, after []byte) map[string]AuditChange {
+	var beforeFields, afterFields map[string]interface{}
+	if before != nil {
+		json.Unmarshal(before, &beforeFields)
+	}
+	if after != nil {
+		json.Unmarshal(after, &afterFields)
+	}
+
+	diff := map[string]AuditChange{}
+	for field, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[field]) {
+			diff[field] = AuditChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			diff[field] = AuditChange{Before: nil, After: value}
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,625 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Bracket formats
+const (
+	BracketSingleElimination = "single_elimination"
+	BracketDoubleElimination = "double_elimination"
+)
+
+// Bracket stages
+const (
+	StageWinners    = "winners"
+	StageLosers     = "losers"
+	StageGrandFinal = "grand_final"
+)
+
+// bracketStages lists the stages of a bracket in the order they are shown
+var bracketStages = []string{StageWinners, StageLosers, StageGrandFinal}
+
+// Bracket is the knockout tree of a competition, grouped by stage and round
+type Bracket struct {
+	CompetitionID int            `json:"competition_id"`
+	Format        string         `json:"format"`
+	Size          int            `json:"size"`
+	ChampionID    *int           `json:"champion_id"`
+	Stages        []BracketStage `json:"stages"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// BracketStage is the winners bracket, the losers bracket or the grand final
+type BracketStage struct {
+	Name   string         `json:"name"`
+	Rounds []BracketRound `json:"rounds"`
+}
+
+// BracketRound is one round of a bracket stage
+type BracketRound struct {
+	Round   int      `json:"round"`
+	Matches []*Match `json:"matches"`
+}
+
+// slotKey identifies one of the two places in a match
+type slotKey struct {
+	matchID int
+	slot    int
+}
+
+// bracketFeeder is the match whose winner, or loser, fills a slot
+type bracketFeeder struct {
+	match *Match
+	loser bool
+}
+
+// bracketState indexes the matches of a bracket for advancing participants
+type bracketState struct {
+	matches []*Match
+	byID    map[int]*Match
+	feeders map[slotKey]bracketFeeder
+	changed map[int]bool
+}
+
+// newBracketState indexes a bracket's matches by ID and by the slots they feed
+func newBracketState(matches []*Match) *bracketState {
+	s := &bracketState{
+		matches: matches,
+		byID:    map[int]*Match{},
+		feeders: map[slotKey]bracketFeeder{},
+		changed: map[int]bool{},
+	}
+	for _, m := range matches {
+		s.byID[m.ID] = m
+	}
+	for _, m := range matches {
+		if m.NextMatchID != nil {
+			s.feeders[slotKey{*m.NextMatchID, *m.NextSlot}] = bracketFeeder{match: m}
+		}
+		if m.LoserMatchID != nil {
+			s.feeders[slotKey{*m.LoserMatchID, *m.LoserSlot}] = bracketFeeder{match: m, loser: true}
+		}
+	}
+	return s
+}
+
+// seedOrder returns the seed placed in each slot of the first round of a
+// bracket of the given size, so that the top seeds can only meet late and
+// byes go to the top seeds
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, len(order)*2)
+		for _, seed := range order {
+			next = append(next, seed, len(order)*2+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// buildBracket lays out the matches of a bracket for the entrants in seeding
+// order and advances the byes. Match IDs are only provisional until the
+// matches are stored.
+func buildBracket(format string, competitionID int, entrants []int) ([]*Match, int) {
+	size, rounds := 1, 0
+	for size < len(entrants) {
+		size *= 2
+		rounds++
+	}
+
+	var matches []*Match
+	add := func(stage string, round, position int) *Match {
+		m := &Match{ID: len(matches) + 1, CompetitionID: competitionID, Stage: stage, Round: round, Position: position, Status: MatchPending}
+		matches = append(matches, m)
+		return m
+	}
+	link := func(from *Match, to *Match, slot int, loser bool) {
+		id, s := to.ID, slot
+		if loser {
+			from.LoserMatchID, from.LoserSlot = &id, &s
+		} else {
+			from.NextMatchID, from.NextSlot = &id, &s
+		}
+	}
+
+	winners := make([][]*Match, rounds+1)
+	for r := 1; r <= rounds; r++ {
+		for p := 0; p < size>>r; p++ {
+			winners[r] = append(winners[r], add(StageWinners, r, p+1))
+		}
+	}
+	for r := 1; r < rounds; r++ {
+		for p, m := range winners[r] {
+			link(m, winners[r+1][p/2], p%2+1, false)
+		}
+	}
+
+	order := seedOrder(size)
+	for p, m := range winners[1] {
+		for slot := 1; slot <= 2; slot++ {
+			if seed := order[2*p+slot-1]; seed <= len(entrants) {
+				id := entrants[seed-1]
+				m.setSlot(slot, &id)
+			}
+		}
+	}
+
+	if format == BracketDoubleElimination {
+		// The losers bracket alternates between rounds among its own players
+		// and rounds where the losers of the next winners round drop in
+		losers := make([][]*Match, 2*rounds-1)
+		for r := 1; r <= 2*(rounds-1); r++ {
+			count := size >> ((r+1)/2 + 1)
+			for p := 0; p < count; p++ {
+				losers[r] = append(losers[r], add(StageLosers, r, p+1))
+			}
+		}
+		final := add(StageGrandFinal, 1, 1)
+		reset := add(StageGrandFinal, 2, 1)
+
+		link(winners[rounds][0], final, 1, false)
+		if rounds == 1 {
+			link(winners[1][0], final, 2, true)
+		} else {
+			for p, m := range winners[1] {
+				link(m, losers[1][p/2], p%2+1, true)
+			}
+			for r := 2; r <= rounds; r++ {
+				// Dropping losers in reverse order makes early rematches less likely
+				dropIn := losers[2*(r-1)]
+				for p, m := range winners[r] {
+					link(m, dropIn[len(dropIn)-1-p], 2, true)
+				}
+			}
+			for r := 1; r <= 2*(rounds-1); r++ {
+				for p, m := range losers[r] {
+					switch {
+					case r == 2*(rounds-1):
+						link(m, final, 2, false)
+					case r%2 == 1:
+						link(m, losers[r+1][p], 1, false)
+					default:
+						link(m, losers[r+1][p/2], p%2+1, false)
+					}
+				}
+			}
+		}
+
+		// The reset is only played if the winners bracket champion loses the final
+		link(final, reset, 1, false)
+		link(final, reset, 2, true)
+	}
+
+	newBracketState(matches).settle()
+	return matches, size
+}
+
+// settle advances participants through every match that can be decided
+// without being played: byes, and matches left with one or no participant
+// because their feeders had nobody to send
+func (s *bracketState) settle() {
+	for progress := true; progress; {
+		progress = false
+		for _, m := range s.matches {
+			if m.done() {
+				continue
+			}
+
+			settled := true
+			for slot := 1; slot <= 2; slot++ {
+				if f, ok := s.feeders[slotKey{m.ID, slot}]; ok && !f.match.done() {
+					settled = false
+				}
+			}
+			if !settled {
+				continue
+			}
+
+			switch {
+			case m.Participant1ID != nil && m.Participant2ID != nil:
+				if m.Status != MatchReady {
+					m.Status = MatchReady
+					s.changed[m.ID] = true
+				}
+			case m.Participant1ID != nil:
+				s.finish(m, MatchBye, m.Participant1ID)
+				progress = true
+			case m.Participant2ID != nil:
+				s.finish(m, MatchBye, m.Participant2ID)
+				progress = true
+			default:
+				s.finish(m, MatchBye, nil)
+				progress = true
+			}
+		}
+	}
+}
+
+// finish closes a match with its winner and moves the winner and loser on
+func (s *bracketState) finish(m *Match, status string, winnerID *int) {
+	m.Status = status
+	m.WinnerID = winnerID
+	s.changed[m.ID] = true
+
+	// The bracket reset is skipped when the winners bracket champion wins the final
+	if m.Stage == StageGrandFinal && m.Round == 1 && m.NextMatchID != nil && winnerID != nil && m.Participant1ID != nil && *winnerID == *m.Participant1ID {
+		reset := s.byID[*m.NextMatchID]
+		reset.Participant1ID, reset.Participant2ID = nil, nil
+		reset.Status = MatchSkipped
+		s.changed[reset.ID] = true
+		return
+	}
+
+	if m.NextMatchID != nil {
+		next := s.byID[*m.NextMatchID]
+		next.setSlot(*m.NextSlot, winnerID)
+		s.changed[next.ID] = true
+	}
+	if m.LoserMatchID != nil {
+		next := s.byID[*m.LoserMatchID]
+		next.setSlot(*m.LoserSlot, m.loserID())
+		s.changed[next.ID] = true
+	}
+}
+
+// score records the result of a match and advances the bracket. A result can
+// be corrected as long as the matches it leads to have not been played.
+func (s *bracketState) score(m *Match, score1, score2 float64) error {
+	if m.Status != MatchReady && m.Status != MatchCompleted {
+		return errors.New("match is not ready to be played")
+	}
+	if score1 == score2 {
+		return errors.New("a knockout match cannot end in a draw")
+	}
+
+	if m.Status == MatchCompleted {
+		for _, id := range []*int{m.NextMatchID, m.LoserMatchID} {
+			if id == nil {
+				continue
+			}
+			next := s.byID[*id]
+			if next.Status == MatchCompleted || next.Status == MatchBye {
+				return errors.New("later matches have already been played")
+			}
+		}
+
+		// Take the previous winner and loser back out of the later matches
+		if m.NextMatchID != nil {
+			next := s.byID[*m.NextMatchID]
+			next.setSlot(*m.NextSlot, nil)
+			next.Status = MatchPending
+			s.changed[next.ID] = true
+		}
+		if m.LoserMatchID != nil {
+			next := s.byID[*m.LoserMatchID]
+			next.setSlot(*m.LoserSlot, nil)
+			next.Status = MatchPending
+			s.changed[next.ID] = true
+		}
+	}
+
+	m.Score1, m.Score2 = &score1, &score2
+	winner := m.Participant1ID
+	if score2 > score1 {
+		winner = m.Participant2ID
+	}
+	s.finish(m, MatchCompleted, winner)
+	s.settle()
+	return nil
+}
+
+// champion returns the winner of a finished bracket
+func (s *bracketState) champion() *int {
+	var last *Match
+	for _, m := range s.matches {
+		if m.NextMatchID == nil && m.Stage != StageLosers {
+			if m.Status == MatchCompleted || m.Status == MatchBye {
+				return m.WinnerID
+			}
+			last = m
+		}
+	}
+
+	// A skipped bracket reset leaves the grand final as the deciding match
+	if last != nil && last.Status == MatchSkipped {
+		for _, m := range s.matches {
+			if m.NextMatchID != nil && *m.NextMatchID == last.ID && m.Stage == StageGrandFinal {
+				return m.WinnerID
+			}
+		}
+	}
+	return nil
+}
+
+// bracketEntrants loads the participants of a competition in seeding order:
+// the given seeds first, then everyone else by their stored seed, and those
+// without one by registration date
+func bracketEntrants(q querier, competitionID int, seeds []int) ([]int, error) {
+	rows, err := q.Query(`
+		SELECT cp.participant_id
+		FROM competition_participants cp
+		JOIN participants p ON p.id = cp.participant_id
+		WHERE cp.competition_id = $1 AND cp.deleted_at IS NULL AND p.deleted_at IS NULL
+		ORDER BY cp.seed ASC NULLS LAST, cp.registration_date ASC, cp.participant_id ASC
+	`, competitionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var registered []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		registered = append(registered, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	isRegistered := map[int]bool{}
+	for _, id := range registered {
+		isRegistered[id] = true
+	}
+
+	seeded := map[int]bool{}
+	entrants := make([]int, 0, len(registered))
+	for _, id := range seeds {
+		if !isRegistered[id] {
+			return nil, errors.New("seeded participant is not registered for this competition")
+		}
+		if seeded[id] {
+			return nil, errors.New("participant seeded more than once")
+		}
+		seeded[id] = true
+		entrants = append(entrants, id)
+	}
+	for _, id := range registered {
+		if !seeded[id] {
+			entrants = append(entrants, id)
+		}
+	}
+
+	return entrants, nil
+}
+
+// bracketStageFilter selects the matches belonging to a bracket
+const bracketStageFilter = "m.stage IN ('winners', 'losers', 'grand_final')"
+
+// GenerateBracket draws a knockout bracket from the participants registered
+// for a competition. seeds optionally lists participant IDs from the top seed
+// down; the others follow in order of registration.
+func GenerateBracket(meta ChangeMeta, competitionID int, format string, seeds []int) (Bracket, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return Bracket{}, err
+	}
+	defer tx.Rollback()
+
+	competition, err := lockCompetition(tx, competitionID)
+	if err == sql.ErrNoRows || (err == nil && competition.DeletedAt != nil) {
+		return Bracket{}, errors.New("competition does not exist")
+	}
+	if err != nil {
+		return Bracket{}, err
+	}
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM brackets WHERE competition_id = $1)", competitionID).Scan(&exists); err != nil {
+		return Bracket{}, err
+	}
+	if exists {
+		return Bracket{}, errors.New("bracket already exists")
+	}
+
+	entrants, err := bracketEntrants(tx, competitionID, seeds)
+	if err != nil {
+		return Bracket{}, err
+	}
+	if len(entrants) < 2 {
+		return Bracket{}, errors.New("a bracket needs at least two participants")
+	}
+
+	matches, size := buildBracket(format, competitionID, entrants)
+
+	_, err = tx.Exec("INSERT INTO brackets (competition_id, format, size) VALUES ($1, $2, $3)", competitionID, format, size)
+	if err != nil {
+		return Bracket{}, err
+	}
+
+	// Matches are stored first and linked once their IDs are known
+	ids := map[int]int{}
+	for _, m := range matches {
+		provisional := m.ID
+		if err := insertMatch(tx, m); err != nil {
+			return Bracket{}, err
+		}
+		ids[provisional] = m.ID
+	}
+	for _, m := range matches {
+		if m.NextMatchID == nil && m.LoserMatchID == nil {
+			continue
+		}
+		var next, loser *int
+		if m.NextMatchID != nil {
+			id := ids[*m.NextMatchID]
+			next = &id
+		}
+		if m.LoserMatchID != nil {
+			id := ids[*m.LoserMatchID]
+			loser = &id
+		}
+		_, err := tx.Exec(`
+			UPDATE matches
+			SET next_match_id = $2, next_slot = $3, loser_match_id = $4, loser_slot = $5
+			WHERE id = $1
+		`, m.ID, next, m.NextSlot, loser, m.LoserSlot)
+		if err != nil {
+			return Bracket{}, err
+		}
+	}
+
+	audit := map[string]interface{}{"format": format, "size": size, "entrants": entrants}
+	if err := recordAudit(tx, meta, "bracket", strconv.Itoa(competitionID), "create", nil, audit); err != nil {
+		return Bracket{}, err
+	}
+
+	if err := tx.commit(); err != nil {
+		return Bracket{}, err
+	}
+
+	return GetBracket(competitionID)
+}
+
+// GetBracket retrieves the bracket of a competition as a tree of stages,
+// rounds and matches
+func GetBracket(competitionID int) (Bracket, error) {
+	b := Bracket{CompetitionID: competitionID, Stages: []BracketStage{}}
+	err := DB.QueryRow(`
+		SELECT b.format, b.size, b.created_at
+		FROM brackets b
+		JOIN competitions c ON c.id = b.competition_id
+		WHERE b.competition_id = $1 AND c.deleted_at IS NULL
+	`, competitionID).Scan(&b.Format, &b.Size, &b.CreatedAt)
+	if err == sql.ErrNoRows {
+		return b, errors.New("bracket not found")
+	}
+	if err != nil {
+		return b, err
+	}
+
+	matches, err := getMatches(DB, competitionID, bracketStageFilter, false)
+	if err != nil {
+		return b, err
+	}
+
+	b.ChampionID = newBracketState(matches).champion()
+
+	for _, name := range bracketStages {
+		stage := BracketStage{Name: name, Rounds: []BracketRound{}}
+		for _, m := range matches {
+			if m.Stage != name {
+				continue
+			}
+			if len(stage.Rounds) == 0 || stage.Rounds[len(stage.Rounds)-1].Round != m.Round {
+				stage.Rounds = append(stage.Rounds, BracketRound{Round: m.Round})
+			}
+			last := &stage.Rounds[len(stage.Rounds)-1]
+			last.Matches = append(last.Matches, m)
+		}
+		if len(stage.Rounds) > 0 {
+			b.Stages = append(b.Stages, stage)
+		}
+	}
+
+	return b, nil
+}
+
+// RecordMatchResult stores the scores of a bracket match and advances the
+// winner, and in a double elimination bracket the loser, to their next match
+func RecordMatchResult(meta ChangeMeta, competitionID, matchID int, score1, score2 float64) (Match, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return Match{}, err
+	}
+	defer tx.Rollback()
+
+	// Locking the bracket serializes results, which may touch the same later matches
+	var format string
+	err = tx.QueryRow(`
+		SELECT b.format
+		FROM brackets b
+		JOIN competitions c ON c.id = b.competition_id
+		WHERE b.competition_id = $1 AND c.deleted_at IS NULL
+		FOR UPDATE OF b
+	`, competitionID).Scan(&format)
+	if err == sql.ErrNoRows {
+		return Match{}, errors.New("match not found")
+	}
+	if err != nil {
+		return Match{}, err
+	}
+
+	matches, err := getMatches(tx, competitionID, bracketStageFilter, true)
+	if err != nil {
+		return Match{}, err
+	}
+
+	state := newBracketState(matches)
+	m, ok := state.byID[matchID]
+	if !ok {
+		return Match{}, errors.New("match not found")
+	}
+	before := *m
+
+	if err := state.score(m, score1, score2); err != nil {
+		return Match{}, err
+	}
+
+	for _, changed := range matches {
+		if state.changed[changed.ID] {
+			if err := saveMatch(tx, changed); err != nil {
+				return Match{}, err
+			}
+		}
+	}
+
+	action := "create"
+	if before.Status == MatchCompleted {
+		action = "update"
+	}
+	if err := recordAudit(tx, meta, "match", strconv.Itoa(m.ID), action, before, m); err != nil {
+		return Match{}, err
+	}
+
+	if err := recordEvent(tx, EventMatchCompleted, competitionID, m); err != nil {
+		return Match{}, err
+	}
+
+	if err := updateCompetitionRatings(tx, competitionID); err != nil {
+		return Match{}, err
+	}
+
+	return *m, tx.commit()
+}
+
+// DeleteBracket discards the bracket of a competition with all its matches
+func DeleteBracket(meta ChangeMeta, competitionID int) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var format string
+	var size int
+	err = tx.QueryRow("DELETE FROM brackets WHERE competition_id = $1 RETURNING format, size", competitionID).Scan(&format, &size)
+	if err == sql.ErrNoRows {
+		return errors.New("bracket not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM matches m WHERE m.competition_id = $1 AND "+bracketStageFilter, competitionID); err != nil {
+		return err
+	}
+
+	audit := map[string]interface{}{"format": format, "size": size}
+	if err := recordAudit(tx, meta, "bracket", strconv.Itoa(competitionID), "delete", audit, nil); err != nil {
+		return err
+	}
+
+	if err := updateCompetitionRatings(tx, competitionID); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSeedOrder(t *testing.T) {
+	tests := []struct {
+		size int
+		want []int
+	}{
+		{1, []int{1}},
+		{2, []int{1, 2}},
+		{4, []int{1, 4, 2, 3}},
+		{8, []int{1, 8, 4, 5, 2, 7, 3, 6}},
+		{16, []int{1, 16, 8, 9, 4, 13, 5, 12, 2, 15, 7, 10, 3, 14, 6, 11}},
+	}
+
+	for _, tt := range tests {
+		if got := seedOrder(tt.size); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("seedOrder(%d) = %v, want %v", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestBuildBracket(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		entrants    int
+		wantSize    int
+		wantMatches map[string]int
+		wantByes    []int
+	}{
+		{
+			name:        "single elimination, full",
+			format:      BracketSingleElimination,
+			entrants:    4,
+			wantSize:    4,
+			wantMatches: map[string]int{StageWinners: 3},
+		},
+		{
+			name:        "single elimination with byes",
+			format:      BracketSingleElimination,
+			entrants:    5,
+			wantSize:    8,
+			wantMatches: map[string]int{StageWinners: 7},
+			wantByes:    []int{1, 2, 3},
+		},
+		{
+			name:        "single elimination, one short",
+			format:      BracketSingleElimination,
+			entrants:    7,
+			wantSize:    8,
+			wantMatches: map[string]int{StageWinners: 7},
+			wantByes:    []int{1},
+		},
+		{
+			name:        "double elimination, full",
+			format:      BracketDoubleElimination,
+			entrants:    8,
+			wantSize:    8,
+			wantMatches: map[string]int{StageWinners: 7, StageLosers: 6, StageGrandFinal: 2},
+		},
+		{
+			name:        "double elimination, two players",
+			format:      BracketDoubleElimination,
+			entrants:    2,
+			wantSize:    2,
+			wantMatches: map[string]int{StageWinners: 1, StageGrandFinal: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entrants := make([]int, tt.entrants)
+			for i := range entrants {
+				entrants[i] = 100 + i + 1
+			}
+
+			matches, size := buildBracket(tt.format, 1, entrants)
+			if size != tt.wantSize {
+				t.Errorf("got size %d, want %d", size, tt.wantSize)
+			}
+
+			counts := map[string]int{}
+			byID := map[int]*Match{}
+			for _, m := range matches {
+				counts[m.Stage]++
+				byID[m.ID] = m
+			}
+			if !reflect.DeepEqual(counts, tt.wantMatches) {
+				t.Errorf("got matches %v, want %v", counts, tt.wantMatches)
+			}
+
+			// Every entrant starts in exactly one first round slot
+			placed := map[int]int{}
+			var byes []int
+			for _, m := range matches {
+				if m.Stage != StageWinners || m.Round != 1 {
+					continue
+				}
+				for _, id := range []*int{m.Participant1ID, m.Participant2ID} {
+					if id != nil {
+						placed[*id]++
+					}
+				}
+				if m.Status == MatchBye {
+					byes = append(byes, *m.WinnerID-100)
+
+					// The seed with a bye is already through to the next round
+					next := byID[*m.NextMatchID]
+					slot := next.Participant2ID
+					if *m.NextSlot == 1 {
+						slot = next.Participant1ID
+					}
+					if slot == nil || *slot != *m.WinnerID {
+						t.Errorf("seed %d was not advanced past its bye", *m.WinnerID-100)
+					}
+				}
+			}
+			for _, id := range entrants {
+				if placed[id] != 1 {
+					t.Errorf("entrant %d placed %d times in the first round", id, placed[id])
+				}
+			}
+
+			sort.Ints(byes)
+			if !reflect.DeepEqual(byes, tt.wantByes) {
+				t.Errorf("got byes for seeds %v, want %v", byes, tt.wantByes)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// SetParticipantCalendarToken sets the token of a participant's calendar feed,
+// revoking any earlier one. Only a hash of the token is stored.
+func SetParticipantCalendarToken(participantID int, token string) error {
+	result, err := DB.Exec(`
+		UPDATE participants
+		SET calendar_token_hash = $2
+		WHERE id = $1 AND deleted_at IS NULL
+	`, participantID, hashToken(token))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("participant not found")
+	}
+
+	return nil
+}
+
+// GetParticipantByCalendarToken retrieves the participant a calendar feed token belongs to
+func GetParticipantByCalendarToken(token string) (Participant, error) {
+	var p Participant
+	err := DB.QueryRow(`
+		SELECT id, name, email, locale, date_of_birth, gender, club, nationality, federation, license_number, version, created_at, updated_at
+		FROM participants
+		WHERE calendar_token_hash = $1 AND deleted_at IS NULL
+	`, hashToken(token)).Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return p, errors.New("participant not found")
+	}
+
+	return p, err
+}
+
+// CalendarEntry is a competition in a participant's calendar feed. Sequence
+// increases with every change to the competition and with every withdrawal
+// and re-registration, so calendar clients pick up the latest state.
+type CalendarEntry struct {
+	Competition Competition
+	Sequence    int
+	Cancelled   bool
+}
+
+// GetParticipantCalendarEntries retrieves every competition a participant is
+// or was registered for. Competitions that were deleted or that the
+// participant was removed from are cancelled; DeletedAt is only set for
+// deleted competitions.
+func GetParticipantCalendarEntries(participantID int) ([]CalendarEntry, error) {
+	// Each withdrawal adds two, so the cancellation that follows it (one less)
+	// still ranks above the confirmation that came before it
+	rows, err := DB.Query(`
+		SELECT c.id, c.name, c.description, c.date, c.location, c.min_team_size, c.max_team_size, c.discipline, c.series, c.version, c.created_at, c.updated_at, c.deleted_at,
+			c.version + 2 * cp.withdrawals - CASE WHEN cp.deleted_at IS NOT NULL THEN 1 ELSE 0 END,
+			c.deleted_at IS NOT NULL OR cp.deleted_at IS NOT NULL
+		FROM competitions c
+		JOIN competition_participants cp ON c.id = cp.competition_id
+		WHERE cp.participant_id = $1
+		ORDER BY c.date ASC
+	`, participantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []CalendarEntry
+	for rows.Next() {
+		var e CalendarEntry
+		c := &e.Competition
+		err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.Date, &c.Location, &c.MinTeamSize, &c.MaxTeamSize, &c.Discipline, &c.Series, &c.Version, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt, &e.Sequence, &e.Cancelled)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,454 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Category is a division of a competition, such as an age group, that
+// registered participants are assigned to by its rules. Rules left empty match
+// everyone.
+type Category struct {
+	ID               int        `json:"id"`
+	CompetitionID    int        `json:"competition_id"`
+	Name             string     `json:"name"`
+	Gender           string     `json:"gender"`
+	MinAge           *int       `json:"min_age"`
+	MaxAge           *int       `json:"max_age"`
+	AgeReferenceDate *time.Time `json:"age_reference_date"`
+	SkillLevels      []string   `json:"skill_levels"`
+	Position         int        `json:"position"`
+	ParticipantCount int        `json:"participant_count"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// CategoryOverview lists a competition's categories together with the number
+// of registrations that fit none of them
+type CategoryOverview struct {
+	Categories []Category `json:"categories"`
+	Unassigned int        `json:"unassigned"`
+}
+
+// UnmarshalJSON implements custom JSON unmarshaling for Category
+func (c *Category) UnmarshalJSON(data []byte) error {
+	type Alias Category
+	aux := &struct {
+		AgeReferenceDate string `json:"age_reference_date"`
+		*Alias
+	}{
+		Alias: (*Alias)(c),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.AgeReferenceDate != "" {
+		parsedDate, err := time.Parse("2006-01-02", aux.AgeReferenceDate)
+		if err != nil {
+			return err
+		}
+		c.AgeReferenceDate = &parsedDate
+	}
+	return nil
+}
+
+// ageAt returns someone's age in whole years on the given day
+func ageAt(dateOfBirth, day time.Time) int {
+	age := day.Year() - dateOfBirth.Year()
+	if day.Month() < dateOfBirth.Month() || (day.Month() == dateOfBirth.Month() && day.Day() < dateOfBirth.Day()) {
+		age--
+	}
+	return age
+}
+
+// matches checks whether a participant fits the category's rules. Ages are
+// taken at the category's reference date, or else at the competition date.
+func (c Category) matches(dateOfBirth *time.Time, gender, skillLevel string, competitionDate time.Time) bool {
+	if c.Gender != "" && c.Gender != gender {
+		return false
+	}
+
+	if c.MinAge != nil || c.MaxAge != nil {
+		if dateOfBirth == nil {
+			return false
+		}
+		reference := competitionDate
+		if c.AgeReferenceDate != nil {
+			reference = *c.AgeReferenceDate
+		}
+		age := ageAt(*dateOfBirth, reference)
+		if (c.MinAge != nil && age < *c.MinAge) || (c.MaxAge != nil && age > *c.MaxAge) {
+			return false
+		}
+	}
+
+	if len(c.SkillLevels) > 0 {
+		found := false
+		for _, level := range c.SkillLevels {
+			found = found || level == skillLevel
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetCategories retrieves the categories of a competition in the order they
+// are tried, with the number of participants assigned to each
+func GetCategories(competitionID int) (CategoryOverview, error) {
+	overview := CategoryOverview{Categories: []Category{}}
+
+	categories, err := getCategories(DB, competitionID)
+	if err != nil {
+		return overview, err
+	}
+
+	counts := map[int]int{}
+	rows, err := DB.Query(`
+		SELECT cp.category_id, COUNT(*)
+		FROM competition_participants cp
+		JOIN participants p ON p.id = cp.participant_id
+		WHERE cp.competition_id = $1 AND cp.deleted_at IS NULL AND p.deleted_at IS NULL
+		GROUP BY cp.category_id
+	`, competitionID)
+	if err != nil {
+		return overview, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var categoryID sql.NullInt64
+		var count int
+		if err := rows.Scan(&categoryID, &count); err != nil {
+			return overview, err
+		}
+		if categoryID.Valid {
+			counts[int(categoryID.Int64)] = count
+		} else {
+			overview.Unassigned = count
+		}
+	}
+
+	for _, c := range categories {
+		c.ParticipantCount = counts[c.ID]
+		overview.Categories = append(overview.Categories, c)
+	}
+
+	return overview, nil
+}
+
+// getCategories loads the categories of a competition in the order they are tried
+func getCategories(q querier, competitionID int) ([]Category, error) {
+	rows, err := q.Query(`
+		SELECT id, competition_id, name, gender, min_age, max_age, age_reference_date, skill_levels, position, created_at, updated_at
+		FROM categories
+		WHERE competition_id = $1
+		ORDER BY position ASC, id ASC
+	`, competitionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []Category
+	for rows.Next() {
+		var c Category
+		err := rows.Scan(&c.ID, &c.CompetitionID, &c.Name, &c.Gender, &c.MinAge, &c.MaxAge, &c.AgeReferenceDate,
+			pq.Array(&c.SkillLevels), &c.Position, &c.CreatedAt, &c.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, rows.Err()
+}
+
+// lockCategory loads a category of a competition and locks it for the rest of
+// the transaction
+func lockCategory(q querier, competitionID, id int) (Category, error) {
+	var c Category
+	err := q.QueryRow(`
+		SELECT id, competition_id, name, gender, min_age, max_age, age_reference_date, skill_levels, position, created_at, updated_at
+		FROM categories
+		WHERE id = $1 AND competition_id = $2
+		FOR UPDATE
+	`, id, competitionID).Scan(&c.ID, &c.CompetitionID, &c.Name, &c.Gender, &c.MinAge, &c.MaxAge, &c.AgeReferenceDate,
+		pq.Array(&c.SkillLevels), &c.Position, &c.CreatedAt, &c.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return c, errors.New("category not found")
+	}
+
+	return c, err
+}
+
+// categoryNameTaken checks if a competition has a category other than excludeID with the given name
+func categoryNameTaken(q querier, competitionID int, name string, excludeID int) (bool, error) {
+	var count int
+	err := q.QueryRow("SELECT COUNT(*) FROM categories WHERE competition_id = $1 AND LOWER(name) = LOWER($2) AND id != $3", competitionID, name, excludeID).Scan(&count)
+	return count > 0, err
+}
+
+// GetCategoryParticipants retrieves the participants assigned to a category
+func GetCategoryParticipants(competitionID, categoryID int) ([]Participant, error) {
+	var exists bool
+	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1 AND competition_id = $2)", categoryID, competitionID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("category not found")
+	}
+
+	rows, err := DB.Query(`
+		SELECT p.id, p.name, p.email, p.locale, p.date_of_birth, p.gender, p.club, p.nationality, p.federation, p.license_number, p.version, p.created_at, p.updated_at
+		FROM participants p
+		JOIN competition_participants cp ON p.id = cp.participant_id
+		WHERE cp.competition_id = $1 AND cp.category_id = $2
+			AND p.deleted_at IS NULL AND cp.deleted_at IS NULL
+		ORDER BY p.name ASC
+	`, competitionID, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	participants := []Participant{}
+	for rows.Next() {
+		var p Participant
+		err := rows.Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, p)
+	}
+
+	return participants, nil
+}
+
+// CreateCategory adds a category to a competition and reassigns the
+// competition's registrations
+func CreateCategory(meta ChangeMeta, c *Category) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if !competitionExists(tx, c.CompetitionID) {
+		return errors.New("competition does not exist")
+	}
+
+	taken, err := categoryNameTaken(tx, c.CompetitionID, c.Name, 0)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errors.New("category name already used")
+	}
+
+	if c.SkillLevels == nil {
+		c.SkillLevels = []string{}
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO categories (competition_id, name, gender, min_age, max_age, age_reference_date, skill_levels, position)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at
+	`, c.CompetitionID, c.Name, c.Gender, c.MinAge, c.MaxAge, c.AgeReferenceDate, pq.Array(c.SkillLevels), c.Position).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "category", strconv.Itoa(c.ID), "create", nil, c); err != nil {
+		return err
+	}
+
+	if err := classifyRegistrations(tx, meta, "competition_id", c.CompetitionID); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// UpdateCategory changes a category's name and rules and reassigns the
+// competition's registrations
+func UpdateCategory(meta ChangeMeta, c *Category) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockCategory(tx, c.CompetitionID, c.ID)
+	if err != nil {
+		return err
+	}
+
+	taken, err := categoryNameTaken(tx, c.CompetitionID, c.Name, c.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errors.New("category name already used")
+	}
+
+	if c.SkillLevels == nil {
+		c.SkillLevels = []string{}
+	}
+
+	err = tx.QueryRow(`
+		UPDATE categories
+		SET name = $2, gender = $3, min_age = $4, max_age = $5, age_reference_date = $6, skill_levels = $7, position = $8,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, c.ID, c.Name, c.Gender, c.MinAge, c.MaxAge, c.AgeReferenceDate, pq.Array(c.SkillLevels), c.Position).Scan(&c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "category", strconv.Itoa(c.ID), "update", before, c); err != nil {
+		return err
+	}
+
+	if err := classifyRegistrations(tx, meta, "competition_id", c.CompetitionID); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// DeleteCategory removes a category from a competition and reassigns the
+// registrations that were in it
+func DeleteCategory(meta ChangeMeta, competitionID, id int) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockCategory(tx, competitionID, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM categories WHERE id = $1", id); err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "category", strconv.Itoa(id), "delete", before, nil); err != nil {
+		return err
+	}
+
+	if err := classifyRegistrations(tx, meta, "competition_id", competitionID); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// classifyParticipant picks the first category of a competition that the
+// participant fits. Competitions without categories take everyone, which is
+// reported as a nil category.
+func classifyParticipant(q querier, competitionID, participantID int, skillLevel string) (*int, error) {
+	categories, err := getCategories(q, competitionID)
+	if err != nil || len(categories) == 0 {
+		return nil, err
+	}
+
+	var competitionDate time.Time
+	var dateOfBirth *time.Time
+	var gender string
+	err = q.QueryRow(`
+		SELECT c.date, p.date_of_birth, p.gender
+		FROM competitions c, participants p
+		WHERE c.id = $1 AND p.id = $2
+	`, competitionID, participantID).Scan(&competitionDate, &dateOfBirth, &gender)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range categories {
+		if c.matches(dateOfBirth, gender, skillLevel, competitionDate) {
+			return &c.ID, nil
+		}
+	}
+
+	return nil, errors.New("participant matches no category")
+}
+
+// classifyRegistrations reassigns the active registrations of a competition or
+// of a participant, selected by column, after categories or profiles changed.
+// Registrations that no longer fit any category are left without one rather
+// than removed.
+func classifyRegistrations(tx *changeTx, meta ChangeMeta, column string, id int) error {
+	rows, err := tx.Query(`
+		SELECT competition_id, participant_id, registration_date, category_id, skill_level, seed, created_at, updated_at
+		FROM competition_participants
+		WHERE `+column+` = $1 AND deleted_at IS NULL
+		ORDER BY competition_id, participant_id
+		FOR UPDATE
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	var registrations []CompetitionParticipant
+	for rows.Next() {
+		var cp CompetitionParticipant
+		if err := rows.Scan(&cp.CompetitionID, &cp.ParticipantID, &cp.RegistrationDate, &cp.CategoryID, &cp.SkillLevel, &cp.Seed, &cp.CreatedAt, &cp.UpdatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		registrations = append(registrations, cp)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, before := range registrations {
+		categoryID, err := classifyParticipant(tx, before.CompetitionID, before.ParticipantID, before.SkillLevel)
+		if err != nil && err.Error() != "participant matches no category" {
+			return err
+		}
+		if sameCategory(categoryID, before.CategoryID) {
+			continue
+		}
+
+		after := before
+		after.CategoryID = categoryID
+		err = tx.QueryRow(`
+			UPDATE competition_participants
+			SET category_id = $3, updated_at = CURRENT_TIMESTAMP
+			WHERE competition_id = $1 AND participant_id = $2
+			RETURNING updated_at
+		`, before.CompetitionID, before.ParticipantID, categoryID).Scan(&after.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		if err := recordAudit(tx, meta, "registration", registrationEntityID(before.CompetitionID, before.ParticipantID), "update", before, after); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sameCategory reports whether two optional category IDs are equal
+func sameCategory(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
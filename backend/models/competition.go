@@ -4,17 +4,25 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"strconv"
 	"time"
 )
 
 type Competition struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Date        time.Time `json:"date"`
-	Location    string    `json:"location"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Date        time.Time  `json:"date"`
+	Location    string     `json:"location"`
+	MinTeamSize *int       `json:"min_team_size"`
+	MaxTeamSize *int       `json:"max_team_size"`
+	Discipline  string     `json:"discipline"`
+	Series      string     `json:"series"`
+	Version     int        `json:"version"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Events      []Event    `json:"events,omitempty"`
 }
 
 // UnmarshalJSON implements custom JSON unmarshaling for Competition
@@ -42,8 +50,9 @@ func (c *Competition) UnmarshalJSON(data []byte) error {
 // GetAllCompetitions retrieves all competitions from the database
 func GetAllCompetitions() ([]Competition, error) {
 	rows, err := DB.Query(`
-		SELECT id, name, description, date, location, created_at, updated_at 
+		SELECT id, name, description, date, location, min_team_size, max_team_size, discipline, series, version, created_at, updated_at 
 		FROM competitions
+		WHERE deleted_at IS NULL
 		ORDER BY date ASC
 	`)
 	if err != nil {
@@ -54,7 +63,7 @@ func GetAllCompetitions() ([]Competition, error) {
 	var competitions []Competition
 	for rows.Next() {
 		var c Competition
-		err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.Date, &c.Location, &c.CreatedAt, &c.UpdatedAt)
+		err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.Date, &c.Location, &c.MinTeamSize, &c.MaxTeamSize, &c.Discipline, &c.Series, &c.Version, &c.CreatedAt, &c.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -68,78 +77,329 @@ func GetAllCompetitions() ([]Competition, error) {
 func GetCompetition(id int) (Competition, error) {
 	var c Competition
 	err := DB.QueryRow(`
-		SELECT id, name, description, date, location, created_at, updated_at 
+		SELECT id, name, description, date, location, min_team_size, max_team_size, discipline, series, version, created_at, updated_at 
 		FROM competitions 
-		WHERE id = $1
-	`, id).Scan(&c.ID, &c.Name, &c.Description, &c.Date, &c.Location, &c.CreatedAt, &c.UpdatedAt)
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id).Scan(&c.ID, &c.Name, &c.Description, &c.Date, &c.Location, &c.MinTeamSize, &c.MaxTeamSize, &c.Discipline, &c.Series, &c.Version, &c.CreatedAt, &c.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return c, errors.New("competition not found")
 	}
+	if err != nil {
+		return c, err
+	}
 
+	c.Events, err = getEvents(DB, id)
 	return c, err
 }
 
-// CreateCompetition adds a new competition to the database
-func CreateCompetition(c *Competition) error {
-	err := DB.QueryRow(`
-		INSERT INTO competitions (name, description, date, location)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, updated_at
-	`, c.Name, c.Description, c.Date, c.Location).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+// lockCompetition loads a competition, including trashed ones, and locks its
+// row for the rest of the transaction
+func lockCompetition(q querier, id int) (Competition, error) {
+	var c Competition
+	err := q.QueryRow(`
+		SELECT id, name, description, date, location, min_team_size, max_team_size, discipline, series, version, created_at, updated_at, deleted_at
+		FROM competitions
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&c.ID, &c.Name, &c.Description, &c.Date, &c.Location, &c.MinTeamSize, &c.MaxTeamSize, &c.Discipline, &c.Series, &c.Version, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt)
 
-	return err
+	return c, err
 }
 
-// UpdateCompetition updates an existing competition
-func UpdateCompetition(c *Competition) error {
-	result, err := DB.Exec(`
-		UPDATE competitions
-		SET name = $2, description = $3, date = $4, location = $5, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $1
-	`, c.ID, c.Name, c.Description, c.Date, c.Location)
+// CreateCompetition adds a new competition to the database
+func CreateCompetition(meta ChangeMeta, c *Competition) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	err = tx.QueryRow(`
+		INSERT INTO competitions (name, description, date, location, min_team_size, max_team_size, discipline, series)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, version, created_at, updated_at
+	`, c.Name, c.Description, c.Date, c.Location, c.MinTeamSize, c.MaxTeamSize, c.Discipline, c.Series).Scan(&c.ID, &c.Version, &c.CreatedAt, &c.UpdatedAt)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	if err := recordAudit(tx, meta, "competition", strconv.Itoa(c.ID), "create", nil, c); err != nil {
+		return err
+	}
+
+	if err := recordEvent(tx, EventCompetitionCreated, c.ID, c); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// UpdateCompetition updates an existing competition. When expectedVersion is
+// non-zero the update only succeeds if the stored version still matches it.
+func UpdateCompetition(meta ChangeMeta, c *Competition, expectedVersion int) error {
+	tx, err := beginChange()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	if rowsAffected == 0 {
+	before, err := lockCompetition(tx, c.ID)
+	if err == sql.ErrNoRows || (err == nil && before.DeletedAt != nil) {
 		return errors.New("competition not found")
 	}
+	if err != nil {
+		return err
+	}
+	if expectedVersion != 0 && before.Version != expectedVersion {
+		return errors.New("competition has been modified")
+	}
 
-	return nil
+	err = tx.QueryRow(`
+		UPDATE competitions
+		SET name = $2, description = $3, date = $4, location = $5, min_team_size = $6, max_team_size = $7, discipline = $8, series = $9,
+			version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING version, created_at, updated_at
+	`, c.ID, c.Name, c.Description, c.Date, c.Location, c.MinTeamSize, c.MaxTeamSize, c.Discipline, c.Series).Scan(&c.Version, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := moveCompetitionRatings(tx, before, *c); err != nil {
+		return err
+	}
+
+	if err := moveCompetitionMarks(tx, before, *c); err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "competition", strconv.Itoa(c.ID), "update", before, c); err != nil {
+		return err
+	}
+
+	if err := recordEvent(tx, EventCompetitionUpdated, c.ID, c); err != nil {
+		return err
+	}
+
+	if err := queueCompetitionChangeNotifications(tx, before, *c); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// patchableCompetitionColumns lists the columns PatchCompetition may change
+var patchableCompetitionColumns = map[string]bool{
+	"name":          true,
+	"description":   true,
+	"date":          true,
+	"location":      true,
+	"min_team_size": true,
+	"max_team_size": true,
+	"discipline":    true,
+	"series":        true,
 }
 
-// DeleteCompetition removes a competition from the database
-func DeleteCompetition(id int) error {
-	result, err := DB.Exec("DELETE FROM competitions WHERE id = $1", id)
+// PatchCompetition updates only the given columns of a competition. When
+// expectedVersion is non-zero the stored version must still match it.
+func PatchCompetition(meta ChangeMeta, id int, changes map[string]interface{}, expectedVersion int) (Competition, error) {
+	if len(changes) == 0 {
+		return GetCompetition(id)
+	}
+
+	var c Competition
+
+	setClause, args, err := buildSetClause(changes, patchableCompetitionColumns, 2)
 	if err != nil {
-		return err
+		return c, err
+	}
+
+	tx, err := beginChange()
+	if err != nil {
+		return c, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockCompetition(tx, id)
+	if err == sql.ErrNoRows || (err == nil && before.DeletedAt != nil) {
+		return c, errors.New("competition not found")
+	}
+	if err != nil {
+		return c, err
+	}
+	if expectedVersion != 0 && before.Version != expectedVersion {
+		return c, errors.New("competition has been modified")
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	err = tx.QueryRow(`
+		UPDATE competitions
+		SET `+setClause+`, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING id, name, description, date, location, min_team_size, max_team_size, discipline, series, version, created_at, updated_at
+	`, append([]interface{}{id}, args...)...).Scan(&c.ID, &c.Name, &c.Description, &c.Date, &c.Location, &c.MinTeamSize, &c.MaxTeamSize, &c.Discipline, &c.Series, &c.Version, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return c, err
+	}
+
+	if err := moveCompetitionRatings(tx, before, c); err != nil {
+		return c, err
+	}
+
+	if err := moveCompetitionMarks(tx, before, c); err != nil {
+		return c, err
+	}
+
+	if err := recordAudit(tx, meta, "competition", strconv.Itoa(id), "update", before, c); err != nil {
+		return c, err
+	}
+
+	if err := recordEvent(tx, EventCompetitionUpdated, c.ID, c); err != nil {
+		return c, err
+	}
+
+	if err := queueCompetitionChangeNotifications(tx, before, c); err != nil {
+		return c, err
+	}
+
+	return c, tx.commit()
+}
+
+// DeleteCompetition moves a competition to the trash. Its registrations are
+// kept so they come back when the competition is restored.
+func DeleteCompetition(meta ChangeMeta, id int) error {
+	tx, err := beginChange()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	if rowsAffected == 0 {
+	before, err := lockCompetition(tx, id)
+	if err == sql.ErrNoRows || (err == nil && before.DeletedAt != nil) {
 		return errors.New("competition not found")
 	}
+	if err != nil {
+		return err
+	}
 
-	return nil
+	after := before
+	err = tx.QueryRow(`
+		UPDATE competitions
+		SET deleted_at = CURRENT_TIMESTAMP, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING version, updated_at, deleted_at
+	`, id).Scan(&after.Version, &after.UpdatedAt, &after.DeletedAt)
+	if err != nil {
+		return err
+	}
+
+	// Matches and marks of trashed competitions no longer count for ratings
+	// and records
+	if err := updateCompetitionRatings(tx, id); err != nil {
+		return err
+	}
+	if err := updateCompetitionMarks(tx, id); err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "competition", strconv.Itoa(id), "delete", before, after); err != nil {
+		return err
+	}
+
+	if err := recordEvent(tx, EventCompetitionDeleted, id, after); err != nil {
+		return err
+	}
+
+	if err := queueCompetitionNotifications(tx, id, NotificationCompetitionCancelled, notificationData(before)); err != nil {
+		return err
+	}
+
+	return tx.commit()
 }
 
 // CompetitionExists checks if a competition with the given ID exists
 func CompetitionExists(id int) bool {
+	return competitionExists(DB, id)
+}
+
+// competitionExists checks if a competition exists using the given querier
+func competitionExists(q querier, id int) bool {
 	var exists bool
-	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM competitions WHERE id = $1)", id).Scan(&exists)
+	err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM competitions WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exists)
 	if err != nil {
 		return false
 	}
 	return exists
 }
+
+// RestoreCompetition takes a competition out of the trash
+func RestoreCompetition(meta ChangeMeta, id int) (Competition, error) {
+	var c Competition
+
+	tx, err := beginChange()
+	if err != nil {
+		return c, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockCompetition(tx, id)
+	if err == sql.ErrNoRows || (err == nil && before.DeletedAt == nil) {
+		return c, errors.New("deleted competition not found")
+	}
+	if err != nil {
+		return c, err
+	}
+
+	err = tx.QueryRow(`
+		UPDATE competitions
+		SET deleted_at = NULL, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING id, name, description, date, location, min_team_size, max_team_size, discipline, series, version, created_at, updated_at
+	`, id).Scan(&c.ID, &c.Name, &c.Description, &c.Date, &c.Location, &c.MinTeamSize, &c.MaxTeamSize, &c.Discipline, &c.Series, &c.Version, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return c, err
+	}
+
+	if err := updateCompetitionRatings(tx, id); err != nil {
+		return c, err
+	}
+	if err := updateCompetitionMarks(tx, id); err != nil {
+		return c, err
+	}
+
+	if err := recordAudit(tx, meta, "competition", strconv.Itoa(id), "restore", before, c); err != nil {
+		return c, err
+	}
+
+	if err := recordEvent(tx, EventCompetitionRestored, id, c); err != nil {
+		return c, err
+	}
+
+	return c, tx.commit()
+}
+
+// GetDeletedCompetitions retrieves all competitions in the trash
+func GetDeletedCompetitions() ([]Competition, error) {
+	rows, err := DB.Query(`
+		SELECT id, name, description, date, location, min_team_size, max_team_size, discipline, series, version, created_at, updated_at, deleted_at
+		FROM competitions
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var competitions []Competition
+	for rows.Next() {
+		var c Competition
+		err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.Date, &c.Location, &c.MinTeamSize, &c.MaxTeamSize, &c.Discipline, &c.Series, &c.Version, &c.CreatedAt, &c.UpdatedAt, &c.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		competitions = append(competitions, c)
+	}
+
+	return competitions, nil
+}
@@ -3,13 +3,24 @@ package models
 import (
 	"competition-app/config"
 	"database/sql"
+	"errors"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
 
 	_ "github.com/lib/pq"
 )
 
 var DB *sql.DB
 
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 // InitDB initializes the database connection
 func InitDB(cfg *config.Config) error {
 	var err error
@@ -28,3 +39,25 @@ func InitDB(cfg *config.Config) error {
 	log.Println("Database connection established")
 	return nil
 }
+
+// buildSetClause builds the assignments of an UPDATE statement for the changed
+// columns, numbering placeholders from start. Columns not in allowed are rejected.
+func buildSetClause(changes map[string]interface{}, allowed map[string]bool, start int) (string, []interface{}, error) {
+	columns := make([]string, 0, len(changes))
+	for column := range changes {
+		if !allowed[column] {
+			return "", nil, errors.New("column cannot be updated: " + column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	assignments := make([]string, 0, len(columns))
+	args := make([]interface{}, 0, len(columns))
+	for i, column := range columns {
+		assignments = append(assignments, column+" = $"+strconv.Itoa(start+i))
+		args = append(args, changes[column])
+	}
+
+	return strings.Join(assignments, ", "), args, nil
+}
@@ -0,0 +1,460 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultDuplicateScore is the similarity above which two participants are
+// reported as possible duplicates
+const DefaultDuplicateScore = 0.75
+
+// DuplicateCandidate is a pair of participants that are likely the same person
+type DuplicateCandidate struct {
+	Participants [2]Participant `json:"participants"`
+	Score        float64        `json:"score"`
+	Reasons      []string       `json:"reasons"`
+}
+
+// MergeResult describes what a merge moved into the surviving participant
+type MergeResult struct {
+	Participant   Participant `json:"participant"`
+	MergedID      int         `json:"merged_id"`
+	Registrations int         `json:"registrations_moved"`
+	Results       int         `json:"results_moved"`
+	Competitions  []int       `json:"competitions"`
+}
+
+// FindDuplicateParticipants compares all active participants with each other
+// and returns the pairs scoring at least minScore, most similar first. The
+// score weighs the similarity of the names against that of the email local
+// parts; identical addresses always count as duplicates.
+func FindDuplicateParticipants(minScore float64) ([]DuplicateCandidate, error) {
+	participants, err := GetAllParticipants()
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		name  string
+		local string
+		email string
+	}
+	keys := make([]key, len(participants))
+	for i, p := range participants {
+		email := NormalizeEmail(p.Email)
+		keys[i] = key{name: normalizeName(p.Name), local: normalizeLocalPart(email), email: email}
+	}
+
+	candidates := []DuplicateCandidate{}
+	for i := range participants {
+		for j := i + 1; j < len(participants); j++ {
+			a, b := keys[i], keys[j]
+
+			nameScore := similarity(a.name, b.name)
+			localScore := similarity(a.local, b.local)
+			score := 0.6*nameScore + 0.4*localScore
+
+			var reasons []string
+			if a.email == b.email {
+				reasons = append(reasons, "same_email")
+				score = 1
+			}
+			if nameScore >= 0.85 {
+				reasons = append(reasons, "similar_name")
+			}
+			if a.email != b.email && localScore >= 0.85 {
+				reasons = append(reasons, "similar_email")
+			}
+
+			if score < minScore || len(reasons) == 0 {
+				continue
+			}
+
+			// List the older record first, as it is usually the one to keep
+			pair := [2]Participant{participants[j], participants[i]}
+			if participants[i].ID < participants[j].ID {
+				pair = [2]Participant{participants[i], participants[j]}
+			}
+
+			candidates = append(candidates, DuplicateCandidate{
+				Participants: pair,
+				Score:        math.Round(score*1000) / 1000,
+				Reasons:      reasons,
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	return candidates, nil
+}
+
+// normalizeName lowercases a name and sorts its words, so "Smith, John" and
+// "john smith" compare as equal
+func normalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// normalizeLocalPart reduces the local part of an email address to its letters
+// and digits, dropping any "+tag" suffix
+func normalizeLocalPart(email string) string {
+	local, _, _ := strings.Cut(email, "@")
+	local, _, _ = strings.Cut(local, "+")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, local)
+}
+
+// similarity returns how alike two strings are, from 0 to 1, based on their
+// Levenshtein distance
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein computes the edit distance between two strings
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+// MergeParticipants merges the participant mergedID into survivorID: their
+// registrations and results are moved over and the merged participant is moved
+// to the trash, all in one transaction. Registrations for a competition both
+// are registered for are combined, keeping the earlier registration date; the
+// merge is refused if both have a result in the same competition.
+func MergeParticipants(meta ChangeMeta, survivorID, mergedID int) (MergeResult, error) {
+	var result MergeResult
+
+	if survivorID == mergedID {
+		return result, errors.New("cannot merge a participant into itself")
+	}
+
+	tx, err := beginChange()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	// Lock both participants in a fixed order so concurrent merges cannot deadlock
+	first, second := survivorID, mergedID
+	if first > second {
+		first, second = second, first
+	}
+	locked := map[int]Participant{}
+	for _, id := range []int{first, second} {
+		p, err := lockParticipant(tx, id)
+		if err == sql.ErrNoRows || (err == nil && p.DeletedAt != nil) {
+			return result, errors.New("participant not found")
+		}
+		if err != nil {
+			return result, err
+		}
+		locked[id] = p
+	}
+	survivor, merged := locked[survivorID], locked[mergedID]
+
+	var conflicts int
+	err = tx.QueryRow(`
+		SELECT COUNT(*)
+		FROM results r
+		JOIN results s ON s.competition_id = r.competition_id AND s.participant_id = $1
+		WHERE r.participant_id = $2
+	`, survivorID, mergedID).Scan(&conflicts)
+	if err != nil {
+		return result, err
+	}
+	if conflicts > 0 {
+		return result, errors.New("both participants have results in the same competition")
+	}
+
+	err = tx.QueryRow(`
+		SELECT COUNT(*)
+		FROM matches r
+		JOIN matches s ON s.competition_id = r.competition_id AND $1 IN (s.participant1_id, s.participant2_id)
+		WHERE $2 IN (r.participant1_id, r.participant2_id)
+	`, survivorID, mergedID).Scan(&conflicts)
+	if err != nil {
+		return result, err
+	}
+	if conflicts > 0 {
+		return result, errors.New("both participants have matches in the same competition")
+	}
+
+	// The merged participant leaves their competitions before the survivor joins them
+	if err := recordRegistrationEvents(tx, EventParticipantUnregistered, mergedID); err != nil {
+		return result, err
+	}
+
+	registrations, err := lockParticipantRegistrations(tx, mergedID)
+	if err != nil {
+		return result, err
+	}
+
+	for _, moving := range registrations {
+		before, err := lockRegistration(tx, survivorID, moving.CompetitionID)
+		if err != nil && err != sql.ErrNoRows {
+			return result, err
+		}
+		exists := err == nil
+
+		// An active registration wins over one in the trash, and the earlier
+		// registration date is kept
+		after := moving
+		after.ParticipantID = survivorID
+		if exists {
+			if before.DeletedAt == nil || moving.DeletedAt != nil {
+				after.DeletedAt = before.DeletedAt
+			}
+			if before.RegistrationDate.Before(after.RegistrationDate) {
+				after.RegistrationDate = before.RegistrationDate
+			}
+			if before.DeletedAt == nil {
+				after.SkillLevel = before.SkillLevel
+				after.Seed = before.Seed
+			}
+		}
+
+		// Event entries and team places hang off the registration, so they are
+		// saved before it is deleted
+		entries, err := getEventEntries(tx, moving.CompetitionID, mergedID)
+		if err != nil {
+			return result, err
+		}
+		lanes, err := getHeatLanes(tx, moving.CompetitionID, mergedID)
+		if err != nil {
+			return result, err
+		}
+		teamID, role, err := getTeamMembership(tx, moving.CompetitionID, mergedID)
+		if err != nil {
+			return result, err
+		}
+
+		_, err = tx.Exec(`
+			DELETE FROM competition_participants
+			WHERE participant_id = $1 AND competition_id = $2
+		`, mergedID, moving.CompetitionID)
+		if err != nil {
+			return result, err
+		}
+
+		// Overwriting the survivor's own registration counts as a withdrawal,
+		// so its calendar entry is superseded rather than silently replaced
+		err = tx.QueryRow(`
+			INSERT INTO competition_participants (competition_id, participant_id, registration_date, category_id, skill_level, seed, deleted_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (competition_id, participant_id) DO UPDATE
+			SET registration_date = EXCLUDED.registration_date, category_id = EXCLUDED.category_id, skill_level = EXCLUDED.skill_level,
+				seed = EXCLUDED.seed, deleted_at = EXCLUDED.deleted_at, withdrawals = competition_participants.withdrawals + 1,
+				updated_at = CURRENT_TIMESTAMP
+			RETURNING created_at, updated_at
+		`, after.CompetitionID, survivorID, after.RegistrationDate, after.CategoryID, after.SkillLevel, after.Seed, after.DeletedAt).Scan(&after.CreatedAt, &after.UpdatedAt)
+		if err != nil {
+			return result, err
+		}
+
+		for _, entry := range entries {
+			_, err := tx.Exec(`
+				INSERT INTO event_participants (event_id, competition_id, participant_id, entry_time)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (event_id, participant_id) DO NOTHING
+			`, entry.EventID, moving.CompetitionID, survivorID, entry.EntryTime)
+			if err != nil {
+				return result, err
+			}
+		}
+
+		// Heat lanes follow the event entries, unless the survivor already
+		// has a lane in the same round
+		for _, lane := range lanes {
+			lane.ParticipantID = survivorID
+			if err := insertHeatLane(tx, moving.CompetitionID, lane); err != nil {
+				return result, err
+			}
+		}
+
+		// The survivor keeps their own team if they are already on one
+		if teamID != 0 {
+			_, err := tx.Exec(`
+				INSERT INTO team_members (team_id, competition_id, participant_id, role)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT DO NOTHING
+			`, teamID, moving.CompetitionID, survivorID, role)
+			if err != nil {
+				return result, err
+			}
+		}
+
+		var auditBefore interface{}
+		if exists {
+			auditBefore = before
+		}
+		if err := recordAudit(tx, meta, "registration", registrationEntityID(moving.CompetitionID, mergedID), "delete", moving, nil); err != nil {
+			return result, err
+		}
+		if err := recordAudit(tx, meta, "registration", registrationEntityID(moving.CompetitionID, survivorID), "merge", auditBefore, after); err != nil {
+			return result, err
+		}
+
+		if after.DeletedAt == nil && (!exists || before.DeletedAt != nil) && competitionExists(tx, moving.CompetitionID) {
+			if err := recordEvent(tx, EventParticipantRegistered, moving.CompetitionID, after); err != nil {
+				return result, err
+			}
+		}
+
+		result.Registrations++
+		result.Competitions = append(result.Competitions, moving.CompetitionID)
+	}
+
+	// The merged registrations may fall into other categories with the survivor's profile
+	if err := classifyRegistrations(tx, meta, "participant_id", survivorID); err != nil {
+		return result, err
+	}
+
+	moved, err := tx.Exec(`
+		UPDATE results
+		SET participant_id = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE participant_id = $2
+	`, survivorID, mergedID)
+	if err != nil {
+		return result, err
+	}
+	movedResults, err := moved.RowsAffected()
+	if err != nil {
+		return result, err
+	}
+	result.Results = int(movedResults)
+
+	_, err = tx.Exec(`
+		UPDATE matches
+		SET participant1_id = CASE WHEN participant1_id = $2 THEN $1 ELSE participant1_id END,
+			participant2_id = CASE WHEN participant2_id = $2 THEN $1 ELSE participant2_id END,
+			winner_id = CASE WHEN winner_id = $2 THEN $1 ELSE winner_id END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE $2 IN (participant1_id, participant2_id, winner_id)
+	`, survivorID, mergedID)
+	if err != nil {
+		return result, err
+	}
+
+	// The moved matches now count for the survivor's ratings
+	if err := updateParticipantRatings(tx, survivorID); err != nil {
+		return result, err
+	}
+
+	// The moved results and heat times now count for the survivor's personal bests
+	if err := updateParticipantMarks(tx, survivorID); err != nil {
+		return result, err
+	}
+
+	// Past notifications stay visible in the survivor's history
+	_, err = tx.Exec("UPDATE notifications SET participant_id = $1 WHERE participant_id = $2", survivorID, mergedID)
+	if err != nil {
+		return result, err
+	}
+
+	trashed := merged
+	err = tx.QueryRow(`
+		UPDATE participants
+		SET deleted_at = CURRENT_TIMESTAMP, calendar_token_hash = NULL, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING version, updated_at, deleted_at
+	`, mergedID).Scan(&trashed.Version, &trashed.UpdatedAt, &trashed.DeletedAt)
+	if err != nil {
+		return result, err
+	}
+
+	if err := recordAudit(tx, meta, "participant", strconv.Itoa(mergedID), "delete", merged, trashed); err != nil {
+		return result, err
+	}
+
+	err = tx.QueryRow(`
+		UPDATE participants
+		SET version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING id, name, email, locale, date_of_birth, gender, club, nationality, federation, license_number, version, created_at, updated_at
+	`, survivorID).Scan(&result.Participant.ID, &result.Participant.Name, &result.Participant.Email, &result.Participant.Locale,
+		&result.Participant.DateOfBirth, &result.Participant.Gender, &result.Participant.Club, &result.Participant.Nationality,
+		&result.Participant.Federation, &result.Participant.LicenseNumber, &result.Participant.Version, &result.Participant.CreatedAt, &result.Participant.UpdatedAt)
+	if err != nil {
+		return result, err
+	}
+
+	after := struct {
+		Participant
+		MergedFrom int `json:"merged_from"`
+	}{result.Participant, mergedID}
+	if err := recordAudit(tx, meta, "participant", strconv.Itoa(survivorID), "merge", survivor, after); err != nil {
+		return result, err
+	}
+
+	result.MergedID = mergedID
+	return result, tx.commit()
+}
+
+// lockParticipantRegistrations loads all registrations of a participant,
+// including trashed ones, and locks them for the rest of the transaction
+func lockParticipantRegistrations(q querier, participantID int) ([]CompetitionParticipant, error) {
+	rows, err := q.Query(`
+		SELECT competition_id, participant_id, registration_date, category_id, skill_level, seed, created_at, updated_at, deleted_at
+		FROM competition_participants
+		WHERE participant_id = $1
+		ORDER BY competition_id
+		FOR UPDATE
+	`, participantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var registrations []CompetitionParticipant
+	for rows.Next() {
+		var cp CompetitionParticipant
+		err := rows.Scan(&cp.CompetitionID, &cp.ParticipantID, &cp.RegistrationDate, &cp.CategoryID, &cp.SkillLevel, &cp.Seed, &cp.CreatedAt, &cp.UpdatedAt, &cp.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		registrations = append(registrations, cp)
+	}
+
+	return registrations, rows.Err()
+}
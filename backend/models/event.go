@@ -0,0 +1,494 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Event is a discipline within a competition, such as the 100m or the long
+// jump, with its own schedule, capacity and way of ranking results
+type Event struct {
+	ID            int        `json:"id"`
+	CompetitionID int        `json:"competition_id"`
+	Name          string     `json:"name"`
+	StartsAt      *time.Time `json:"starts_at"`
+	EndsAt        *time.Time `json:"ends_at"`
+	Capacity      *int       `json:"capacity"`
+	ScoringType   string     `json:"scoring_type"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// EventEntry is a participant's registration for a single event. It belongs
+// to the participant's registration for the competition. Timed events take an
+// entry time in seconds, which seeds the participant into heats.
+type EventEntry struct {
+	EventID       int       `json:"event_id"`
+	CompetitionID int       `json:"competition_id"`
+	ParticipantID int       `json:"participant_id"`
+	EntryTime     *float64  `json:"entry_time"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// eventEntityID builds the audit entity ID of an event entry
+func eventEntityID(eventID, participantID int) string {
+	return strconv.Itoa(eventID) + ":" + strconv.Itoa(participantID)
+}
+
+// GetEvents retrieves the events of a competition in schedule order
+func GetEvents(competitionID int) ([]Event, error) {
+	events, err := getEvents(DB, competitionID)
+	if events == nil {
+		events = []Event{}
+	}
+	return events, err
+}
+
+// getEvents loads the events of a competition, unscheduled events last
+func getEvents(q querier, competitionID int) ([]Event, error) {
+	rows, err := q.Query(`
+		SELECT id, competition_id, name, starts_at, ends_at, capacity, scoring_type, created_at, updated_at
+		FROM events
+		WHERE competition_id = $1
+		ORDER BY starts_at ASC NULLS LAST, id ASC
+	`, competitionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		err := rows.Scan(&e.ID, &e.CompetitionID, &e.Name, &e.StartsAt, &e.EndsAt, &e.Capacity, &e.ScoringType, &e.CreatedAt, &e.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// lockEvent loads an event of a competition and locks it for the rest of the
+// transaction, which also serializes registrations against its capacity
+func lockEvent(q querier, competitionID, id int) (Event, error) {
+	var e Event
+	err := q.QueryRow(`
+		SELECT id, competition_id, name, starts_at, ends_at, capacity, scoring_type, created_at, updated_at
+		FROM events
+		WHERE id = $1 AND competition_id = $2
+		FOR UPDATE
+	`, id, competitionID).Scan(&e.ID, &e.CompetitionID, &e.Name, &e.StartsAt, &e.EndsAt, &e.Capacity, &e.ScoringType, &e.CreatedAt, &e.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return e, errors.New("event not found")
+	}
+
+	return e, err
+}
+
+// eventNameTaken checks if a competition has an event other than excludeID with the given name
+func eventNameTaken(q querier, competitionID int, name string, excludeID int) (bool, error) {
+	var count int
+	err := q.QueryRow("SELECT COUNT(*) FROM events WHERE competition_id = $1 AND LOWER(name) = LOWER($2) AND id != $3", competitionID, name, excludeID).Scan(&count)
+	return count > 0, err
+}
+
+// countEventEntries counts the active participants entered in an event
+func countEventEntries(q querier, eventID int) (int, error) {
+	var count int
+	err := q.QueryRow(`
+		SELECT COUNT(*)
+		FROM event_participants ep
+		JOIN competition_participants cp ON cp.competition_id = ep.competition_id AND cp.participant_id = ep.participant_id
+		JOIN participants p ON p.id = ep.participant_id
+		WHERE ep.event_id = $1 AND cp.deleted_at IS NULL AND p.deleted_at IS NULL
+	`, eventID).Scan(&count)
+	return count, err
+}
+
+// touchCompetition bumps the version of a competition after its events
+// changed, so cached copies and ETags of the nested competition are renewed
+func touchCompetition(tx *changeTx, competitionID int) error {
+	var c Competition
+	err := tx.QueryRow(`
+		UPDATE competitions
+		SET version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING id, name, description, date, location, min_team_size, max_team_size, discipline, series, version, created_at, updated_at
+	`, competitionID).Scan(&c.ID, &c.Name, &c.Description, &c.Date, &c.Location, &c.MinTeamSize, &c.MaxTeamSize, &c.Discipline, &c.Series, &c.Version, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	c.Events, err = getEvents(tx, competitionID)
+	if err != nil {
+		return err
+	}
+
+	return recordEvent(tx, EventCompetitionUpdated, competitionID, c)
+}
+
+// CreateEvent adds an event to a competition
+func CreateEvent(meta ChangeMeta, e *Event) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	competition, err := lockCompetition(tx, e.CompetitionID)
+	if err == sql.ErrNoRows || (err == nil && competition.DeletedAt != nil) {
+		return errors.New("competition does not exist")
+	}
+	if err != nil {
+		return err
+	}
+
+	taken, err := eventNameTaken(tx, e.CompetitionID, e.Name, 0)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errors.New("event name already used")
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO events (competition_id, name, starts_at, ends_at, capacity, scoring_type)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`, e.CompetitionID, e.Name, e.StartsAt, e.EndsAt, e.Capacity, e.ScoringType).Scan(&e.ID, &e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "event", strconv.Itoa(e.ID), "create", nil, e); err != nil {
+		return err
+	}
+
+	if err := touchCompetition(tx, e.CompetitionID); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// UpdateEvent changes an event's name, schedule, capacity and scoring type.
+// The capacity cannot drop below the number of participants already entered.
+func UpdateEvent(meta ChangeMeta, e *Event) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if !competitionExists(tx, e.CompetitionID) {
+		return errors.New("event not found")
+	}
+
+	before, err := lockEvent(tx, e.CompetitionID, e.ID)
+	if err != nil {
+		return err
+	}
+
+	taken, err := eventNameTaken(tx, e.CompetitionID, e.Name, e.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errors.New("event name already used")
+	}
+
+	if e.Capacity != nil {
+		entries, err := countEventEntries(tx, e.ID)
+		if err != nil {
+			return err
+		}
+		if entries > *e.Capacity {
+			return errors.New("capacity is below the number of entries")
+		}
+	}
+
+	err = tx.QueryRow(`
+		UPDATE events
+		SET name = $2, starts_at = $3, ends_at = $4, capacity = $5, scoring_type = $6, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, e.ID, e.Name, e.StartsAt, e.EndsAt, e.Capacity, e.ScoringType).Scan(&e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Times are compared across competitions by event name
+	if recordKey(e.Name) != recordKey(before.Name) {
+		if err := updateCompetitionMarks(tx, e.CompetitionID); err != nil {
+			return err
+		}
+	}
+
+	if err := recordAudit(tx, meta, "event", strconv.Itoa(e.ID), "update", before, e); err != nil {
+		return err
+	}
+
+	if err := touchCompetition(tx, e.CompetitionID); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// DeleteEvent removes an event and its entries from a competition. The
+// participants stay registered for the competition.
+func DeleteEvent(meta ChangeMeta, competitionID, id int) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if !competitionExists(tx, competitionID) {
+		return errors.New("event not found")
+	}
+
+	before, err := lockEvent(tx, competitionID, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM events WHERE id = $1", id); err != nil {
+		return err
+	}
+
+	if err := updateCompetitionMarks(tx, competitionID); err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "event", strconv.Itoa(id), "delete", before, nil); err != nil {
+		return err
+	}
+
+	if err := touchCompetition(tx, competitionID); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// GetEventParticipants retrieves the participants entered in an event
+func GetEventParticipants(competitionID, eventID int) ([]Participant, error) {
+	var exists bool
+	err := DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM events e
+			JOIN competitions c ON c.id = e.competition_id
+			WHERE e.id = $1 AND e.competition_id = $2 AND c.deleted_at IS NULL
+		)
+	`, eventID, competitionID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("event not found")
+	}
+
+	rows, err := DB.Query(`
+		SELECT p.id, p.name, p.email, p.locale, p.date_of_birth, p.gender, p.club, p.nationality, p.federation, p.license_number, p.version, p.created_at, p.updated_at
+		FROM participants p
+		JOIN event_participants ep ON p.id = ep.participant_id
+		JOIN competition_participants cp ON cp.competition_id = ep.competition_id AND cp.participant_id = ep.participant_id
+		WHERE ep.event_id = $1 AND p.deleted_at IS NULL AND cp.deleted_at IS NULL
+		ORDER BY p.name ASC
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	participants := []Participant{}
+	for rows.Next() {
+		var p Participant
+		err := rows.Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, p)
+	}
+
+	return participants, nil
+}
+
+// AddParticipantToEvent enters a participant in an event. Participants not yet
+// registered for the competition are registered first, with the given skill
+// level, so the competition registration always exists as the parent entry.
+// It reports whether that competition registration was created.
+func AddParticipantToEvent(meta ChangeMeta, competitionID, eventID, participantID int, skillLevel string, entryTime *float64) (EventEntry, bool, error) {
+	entry := EventEntry{EventID: eventID, CompetitionID: competitionID, ParticipantID: participantID, EntryTime: entryTime}
+
+	tx, err := beginChange()
+	if err != nil {
+		return entry, false, err
+	}
+	defer tx.Rollback()
+
+	if !competitionExists(tx, competitionID) {
+		return entry, false, errors.New("event not found")
+	}
+
+	event, err := lockEvent(tx, competitionID, eventID)
+	if err != nil {
+		return entry, false, err
+	}
+
+	registration, err := lockRegistration(tx, participantID, competitionID)
+	if err != nil && err != sql.ErrNoRows {
+		return entry, false, err
+	}
+	registered := err == nil && registration.DeletedAt == nil
+	if !registered {
+		if err := addParticipantToCompetition(tx, meta, participantID, competitionID, time.Now(), skillLevel); err != nil {
+			return entry, false, err
+		}
+	} else if !participantExists(tx, participantID) {
+		return entry, false, errors.New("participant does not exist")
+	}
+
+	var entered bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM event_participants WHERE event_id = $1 AND participant_id = $2)", eventID, participantID).Scan(&entered)
+	if err != nil {
+		return entry, false, err
+	}
+	if entered {
+		return entry, false, errors.New("participant already registered for this event")
+	}
+
+	if event.Capacity != nil {
+		entries, err := countEventEntries(tx, eventID)
+		if err != nil {
+			return entry, false, err
+		}
+		if entries >= *event.Capacity {
+			return entry, false, errors.New("event is full")
+		}
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO event_participants (event_id, competition_id, participant_id, entry_time)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`, eventID, competitionID, participantID, entryTime).Scan(&entry.CreatedAt)
+	if err != nil {
+		return entry, false, err
+	}
+
+	if err := recordAudit(tx, meta, "event_registration", eventEntityID(eventID, participantID), "create", nil, entry); err != nil {
+		return entry, false, err
+	}
+
+	return entry, !registered, tx.commit()
+}
+
+// RemoveParticipantFromEvent withdraws a participant from an event. The
+// participant stays registered for the competition and its other events.
+func RemoveParticipantFromEvent(meta ChangeMeta, competitionID, eventID, participantID int) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before EventEntry
+	err = tx.QueryRow(`
+		DELETE FROM event_participants
+		WHERE event_id = $1 AND competition_id = $2 AND participant_id = $3
+		RETURNING event_id, competition_id, participant_id, entry_time, created_at
+	`, eventID, competitionID, participantID).Scan(&before.EventID, &before.CompetitionID, &before.ParticipantID, &before.EntryTime, &before.CreatedAt)
+	if err == sql.ErrNoRows {
+		return errors.New("participant not registered for this event")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "event_registration", eventEntityID(eventID, participantID), "delete", before, nil); err != nil {
+		return err
+	}
+
+	// The entry takes any times swum in its heats with it
+	if err := updateCompetitionMarks(tx, competitionID); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// SetEntryTime changes the entry time of a participant's event entry; nil
+// clears it
+func SetEntryTime(meta ChangeMeta, competitionID, eventID, participantID int, entryTime *float64) (EventEntry, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return EventEntry{}, err
+	}
+	defer tx.Rollback()
+
+	if !competitionExists(tx, competitionID) {
+		return EventEntry{}, errors.New("participant not registered for this event")
+	}
+
+	var before EventEntry
+	err = tx.QueryRow(`
+		SELECT event_id, competition_id, participant_id, entry_time, created_at
+		FROM event_participants
+		WHERE event_id = $1 AND competition_id = $2 AND participant_id = $3
+		FOR UPDATE
+	`, eventID, competitionID, participantID).Scan(&before.EventID, &before.CompetitionID, &before.ParticipantID, &before.EntryTime, &before.CreatedAt)
+	if err == sql.ErrNoRows {
+		return EventEntry{}, errors.New("participant not registered for this event")
+	}
+	if err != nil {
+		return EventEntry{}, err
+	}
+
+	entry := before
+	entry.EntryTime = entryTime
+	_, err = tx.Exec(`
+		UPDATE event_participants SET entry_time = $4
+		WHERE event_id = $1 AND competition_id = $2 AND participant_id = $3
+	`, eventID, competitionID, participantID, entryTime)
+	if err != nil {
+		return EventEntry{}, err
+	}
+
+	if err := recordAudit(tx, meta, "event_registration", eventEntityID(eventID, participantID), "update", before, entry); err != nil {
+		return EventEntry{}, err
+	}
+
+	return entry, tx.commit()
+}
+
+// getEventEntries lists a participant's event entries within a competition
+func getEventEntries(q querier, competitionID, participantID int) ([]EventEntry, error) {
+	rows, err := q.Query(`
+		SELECT event_id, competition_id, participant_id, entry_time, created_at
+		FROM event_participants
+		WHERE competition_id = $1 AND participant_id = $2
+		ORDER BY event_id
+	`, competitionID, participantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []EventEntry
+	for rows.Next() {
+		var e EventEntry
+		if err := rows.Scan(&e.EventID, &e.CompetitionID, &e.ParticipantID, &e.EntryTime, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
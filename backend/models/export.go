@@ -0,0 +1,327 @@
+package models
+
+import (
+	"errors"
+)
+
+// ExportTable describes a table included in the full database export
+type ExportTable struct {
+	Name    string
+	Columns []string
+	query   string
+}
+
+// RosterColumns are the columns of a competition roster export
+var RosterColumns = []string{"participant_id", "name", "email", "registration_date", "score", "notes"}
+
+// ExportTables lists the tables of the full database export, trashed rows
+// included. Secrets and token hashes are left out, as are idempotency keys and
+// used tokens, which only matter until they expire.
+var ExportTables = []ExportTable{
+	{
+		Name:    "competitions",
+		Columns: []string{"id", "name", "description", "date", "location", "min_team_size", "max_team_size", "discipline", "series", "version", "created_at", "updated_at", "deleted_at"},
+		query: `
+			SELECT id, name, COALESCE(description, ''), date, location, min_team_size, max_team_size, discipline, series, version, created_at, updated_at, deleted_at
+			FROM competitions
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "participants",
+		Columns: []string{"id", "name", "email", "locale", "date_of_birth", "gender", "club", "nationality", "federation", "license_number", "version", "created_at", "updated_at", "deleted_at"},
+		query: `
+			SELECT id, name, email, locale, date_of_birth, gender, club, nationality, federation, license_number, version, created_at, updated_at, deleted_at
+			FROM participants
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "categories",
+		Columns: []string{"id", "competition_id", "name", "gender", "min_age", "max_age", "age_reference_date", "skill_levels", "position", "created_at", "updated_at"},
+		query: `
+			SELECT id, competition_id, name, gender, min_age, max_age, age_reference_date, skill_levels, position, created_at, updated_at
+			FROM categories
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "registrations",
+		Columns: []string{"competition_id", "participant_id", "registration_date", "category_id", "skill_level", "seed", "withdrawals", "created_at", "updated_at", "deleted_at"},
+		query: `
+			SELECT competition_id, participant_id, registration_date, category_id, skill_level, seed, withdrawals, created_at, updated_at, deleted_at
+			FROM competition_participants
+			ORDER BY competition_id ASC, participant_id ASC
+		`,
+	},
+	{
+		Name:    "events",
+		Columns: []string{"id", "competition_id", "name", "starts_at", "ends_at", "capacity", "scoring_type", "created_at", "updated_at"},
+		query: `
+			SELECT id, competition_id, name, starts_at, ends_at, capacity, scoring_type, created_at, updated_at
+			FROM events
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "event_participants",
+		Columns: []string{"event_id", "competition_id", "participant_id", "entry_time", "created_at"},
+		query: `
+			SELECT event_id, competition_id, participant_id, entry_time::float8, created_at
+			FROM event_participants
+			ORDER BY event_id ASC, participant_id ASC
+		`,
+	},
+	{
+		Name:    "seedings",
+		Columns: []string{"competition_id", "sources", "manual", "event_id", "previous_competition_id", "previous_result_order", "locked", "computed_at", "updated_at"},
+		query: `
+			SELECT competition_id, sources, manual, event_id, previous_competition_id, previous_result_order, locked, computed_at, updated_at
+			FROM seedings
+			ORDER BY competition_id ASC
+		`,
+	},
+	{
+		Name:    "heat_rounds",
+		Columns: []string{"event_id", "competition_id", "round", "lanes", "created_at", "updated_at"},
+		query: `
+			SELECT event_id, competition_id, round, lanes, created_at, updated_at
+			FROM heat_rounds
+			ORDER BY event_id ASC, round ASC
+		`,
+	},
+	{
+		Name:    "heat_lanes",
+		Columns: []string{"event_id", "competition_id", "round", "heat", "lane", "participant_id", "seed_time", "time", "created_at", "updated_at"},
+		query: `
+			SELECT event_id, competition_id, round, heat, lane, participant_id, seed_time::float8, time::float8, created_at, updated_at
+			FROM heat_lanes
+			ORDER BY event_id ASC, round ASC, heat ASC, lane ASC
+		`,
+	},
+	{
+		Name:    "teams",
+		Columns: []string{"id", "competition_id", "name", "created_at", "updated_at"},
+		query: `
+			SELECT id, competition_id, name, created_at, updated_at
+			FROM teams
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "team_members",
+		Columns: []string{"team_id", "competition_id", "participant_id", "role"},
+		query: `
+			SELECT team_id, competition_id, participant_id, role
+			FROM team_members
+			ORDER BY team_id ASC, participant_id ASC
+		`,
+	},
+	{
+		Name:    "team_results",
+		Columns: []string{"id", "competition_id", "team_id", "score", "notes", "recorded_by", "created_at", "updated_at"},
+		query: `
+			SELECT id, competition_id, team_id, score::float8, notes, recorded_by, created_at, updated_at
+			FROM team_results
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "brackets",
+		Columns: []string{"competition_id", "format", "size", "created_at"},
+		query: `
+			SELECT competition_id, format, size, created_at
+			FROM brackets
+			ORDER BY competition_id ASC
+		`,
+	},
+	{
+		Name:    "round_robins",
+		Columns: []string{"competition_id", "legs", "points_win", "points_draw", "points_loss", "tie_breakers", "created_at", "updated_at"},
+		query: `
+			SELECT competition_id, legs, points_win::float8, points_draw::float8, points_loss::float8, tie_breakers, created_at, updated_at
+			FROM round_robins
+			ORDER BY competition_id ASC
+		`,
+	},
+	{
+		Name:    "matches",
+		Columns: []string{"id", "competition_id", "stage", "round", "position", "participant1_id", "participant2_id", "score1", "score2", "winner_id", "status", "next_match_id", "next_slot", "loser_match_id", "loser_slot", "created_at", "updated_at"},
+		query: `
+			SELECT id, competition_id, stage, round, position, participant1_id, participant2_id, score1::float8, score2::float8, winner_id, status, next_match_id, next_slot, loser_match_id, loser_slot, created_at, updated_at
+			FROM matches
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "ratings",
+		Columns: []string{"participant_id", "discipline", "system", "rating", "deviation", "volatility", "games", "wins", "draws", "losses", "updated_at"},
+		query: `
+			SELECT participant_id, discipline, system, rating::float8, deviation::float8, volatility::float8, games, wins, draws, losses, updated_at
+			FROM ratings
+			ORDER BY participant_id ASC, discipline ASC
+		`,
+	},
+	{
+		Name:    "rating_history",
+		Columns: []string{"id", "participant_id", "discipline", "sequence", "match_id", "competition_id", "opponent_id", "score", "rating_before", "rating_after", "deviation_before", "deviation_after", "volatility_after", "played_on"},
+		query: `
+			SELECT id, participant_id, discipline, sequence, match_id, competition_id, opponent_id, score::float8, rating_before, rating_after, deviation_before, deviation_after, volatility_after, played_on
+			FROM rating_history
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "performances",
+		Columns: []string{"id", "participant_id", "discipline", "event", "sequence", "competition_id", "event_id", "round", "mark", "lower_is_better", "achieved_on", "venue_key", "series_key", "personal_best", "venue_record", "series_record"},
+		query: `
+			SELECT id, participant_id, discipline, event, sequence, competition_id, event_id, round, mark::float8, lower_is_better, achieved_on, venue_key, series_key, personal_best, venue_record, series_record
+			FROM performances
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "personal_bests",
+		Columns: []string{"participant_id", "discipline", "event", "mark", "lower_is_better", "competition_id", "achieved_on"},
+		query: `
+			SELECT participant_id, discipline, event, mark::float8, lower_is_better, competition_id, achieved_on
+			FROM personal_bests
+			ORDER BY participant_id ASC, discipline ASC, event ASC
+		`,
+	},
+	{
+		Name:    "records",
+		Columns: []string{"scope", "scope_key", "name", "discipline", "event", "mark", "lower_is_better", "participant_id", "competition_id", "achieved_on"},
+		query: `
+			SELECT scope, scope_key, name, discipline, event, mark::float8, lower_is_better, participant_id, competition_id, achieved_on
+			FROM records
+			ORDER BY scope ASC, scope_key ASC, discipline ASC, event ASC
+		`,
+	},
+	{
+		Name:    "results",
+		Columns: []string{"id", "competition_id", "participant_id", "score", "notes", "recorded_by", "created_at", "updated_at"},
+		query: `
+			SELECT id, competition_id, participant_id, score::float8, notes, recorded_by, created_at, updated_at
+			FROM results
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "officials",
+		Columns: []string{"id", "name", "competition_id", "created_at"},
+		query: `
+			SELECT id, name, competition_id, created_at
+			FROM officials
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "audit_log",
+		Columns: []string{"id", "actor", "request_id", "entity_type", "entity_id", "action", "before", "after", "created_at"},
+		query: `
+			SELECT id, actor, request_id, entity_type, entity_id, action, before, after, created_at
+			FROM audit_log
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "outbox_events",
+		Columns: []string{"id", "event_type", "competition_id", "payload", "created_at", "dispatched_at"},
+		query: `
+			SELECT id, event_type, competition_id, payload, created_at, dispatched_at
+			FROM outbox_events
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "webhook_subscriptions",
+		Columns: []string{"id", "url", "event_types", "active", "created_at", "updated_at"},
+		query: `
+			SELECT id, url, event_types, active, created_at, updated_at
+			FROM webhook_subscriptions
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "webhook_deliveries",
+		Columns: []string{"id", "subscription_id", "event_id", "status", "attempts", "next_attempt_at", "last_status_code", "last_error", "delivered_at", "created_at", "updated_at"},
+		query: `
+			SELECT id, subscription_id, event_id, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, created_at, updated_at
+			FROM webhook_deliveries
+			ORDER BY id ASC
+		`,
+	},
+	{
+		Name:    "notifications",
+		Columns: []string{"id", "participant_id", "recipient", "recipient_name", "locale", "template", "data", "status", "attempts", "last_error", "next_attempt_at", "sent_at", "created_at", "updated_at"},
+		query: `
+			SELECT id, participant_id, recipient, recipient_name, locale, template, data - 'link', status, attempts, last_error, next_attempt_at, sent_at, created_at, updated_at
+			FROM notifications
+			ORDER BY id ASC
+		`,
+	},
+}
+
+// GetExportTable looks up a table of the full database export by name
+func GetExportTable(name string) (ExportTable, error) {
+	for _, table := range ExportTables {
+		if table.Name == name {
+			return table, nil
+		}
+	}
+	return ExportTable{}, errors.New("export table not found")
+}
+
+// StreamCompetitionRoster calls fn for every participant registered for a
+// competition, in RosterColumns order, reading rows straight from the database
+// cursor instead of loading the whole roster into memory
+func StreamCompetitionRoster(competitionID int, fn func(values []interface{}) error) error {
+	return streamRows(fn, len(RosterColumns), `
+		SELECT p.id, p.name, p.email, cp.registration_date, r.score::float8, COALESCE(r.notes, '')
+		FROM participants p
+		JOIN competition_participants cp ON p.id = cp.participant_id
+		JOIN competitions c ON c.id = cp.competition_id
+		LEFT JOIN results r ON r.competition_id = cp.competition_id AND r.participant_id = p.id
+		WHERE cp.competition_id = $1
+			AND p.deleted_at IS NULL AND cp.deleted_at IS NULL AND c.deleted_at IS NULL
+		ORDER BY cp.registration_date ASC, p.id ASC
+	`, competitionID)
+}
+
+// StreamExportTable calls fn for every row of an export table, in column order
+func StreamExportTable(table ExportTable, fn func(values []interface{}) error) error {
+	return streamRows(fn, len(table.Columns), table.query)
+}
+
+// streamRows runs a query and calls fn with the values of each row as it is
+// read. Text is returned as strings, so values can be written out directly.
+func streamRows(fn func(values []interface{}) error, columns int, query string, args ...interface{}) error {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, columns)
+	pointers := make([]interface{}, columns)
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
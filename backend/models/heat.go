@@ -0,0 +1,606 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Rounds of a timed event. Events without preliminaries are swum as timed
+// finals, seeded straight from the entry times.
+const (
+	HeatRoundPrelim = "prelim"
+	HeatRoundFinal  = "final"
+)
+
+// minFirstHeat is the smallest first heat seeding leaves when there is more
+// than one heat; the first heat is topped up from the second
+const minFirstHeat = 3
+
+// HeatLane is a participant's place in a heat, with the time they were seeded
+// with and the time they swam
+type HeatLane struct {
+	EventID       int      `json:"-"`
+	Round         string   `json:"-"`
+	Heat          int      `json:"heat"`
+	Lane          int      `json:"lane"`
+	ParticipantID int      `json:"participant_id"`
+	Name          string   `json:"name"`
+	Club          string   `json:"club"`
+	Nationality   string   `json:"nationality"`
+	SeedTime      *float64 `json:"seed_time"`
+	Time          *float64 `json:"time"`
+	MarkFlags
+
+	// active is false once the participant or their registration is in the
+	// trash; their lane is then shown as empty and may be given to someone else
+	active bool
+}
+
+// Heat is one start of a round, with its lanes in lane order
+type Heat struct {
+	Heat  int        `json:"heat"`
+	Lanes []HeatLane `json:"lanes"`
+}
+
+// HeatRound is the seeding of one round of a timed event
+type HeatRound struct {
+	EventID       int       `json:"event_id"`
+	CompetitionID int       `json:"competition_id"`
+	Round         string    `json:"round"`
+	Lanes         int       `json:"lanes"`
+	Heats         []Heat    `json:"heats"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// heatEntityID builds the audit entity ID of a round or of a lane in it
+func heatEntityID(eventID int, round string, participantID int) string {
+	id := strconv.Itoa(eventID) + ":" + round
+	if participantID != 0 {
+		id += ":" + strconv.Itoa(participantID)
+	}
+	return id
+}
+
+// FormatRaceTime formats a time in seconds as a start list shows it, such as
+// 58.31 or 1:02.07; participants without a time are shown as NT
+func FormatRaceTime(seconds *float64) string {
+	if seconds == nil {
+		return "NT"
+	}
+
+	hundredths := int(math.Round(*seconds * 100))
+	minutes := hundredths / 6000
+	rest := strconv.Itoa(hundredths % 6000 / 100)
+	fraction := strconv.Itoa(hundredths % 100)
+	if len(fraction) == 1 {
+		fraction = "0" + fraction
+	}
+	if minutes == 0 {
+		return rest + "." + fraction
+	}
+	if len(rest) == 1 {
+		rest = "0" + rest
+	}
+	return strconv.Itoa(minutes) + ":" + rest + "." + fraction
+}
+
+// laneOrder lists the lanes of a pool from the centre outwards, the order in
+// which the fastest participants of a heat are placed: 4, 5, 3, 6, 2, 7, 1, 8
+// for eight lanes
+func laneOrder(lanes int) []int {
+	centre := (lanes + 1) / 2
+	order := []int{centre}
+	for offset := 1; len(order) < lanes; offset++ {
+		if centre+offset <= lanes {
+			order = append(order, centre+offset)
+		}
+		if centre-offset >= 1 {
+			order = append(order, centre-offset)
+		}
+	}
+	return order
+}
+
+// seedHeats assigns heats and lanes to participants ordered from the fastest
+// seed time. The fastest swim in the last heat and every heat is filled from
+// the centre lanes outwards.
+func seedHeats(seeded []HeatLane, lanes int) {
+	heats := (len(seeded) + lanes - 1) / lanes
+	if heats == 0 {
+		return
+	}
+
+	sizes := make([]int, heats)
+	for h := range sizes {
+		sizes[h] = lanes
+	}
+	sizes[0] = len(seeded) - (heats-1)*lanes
+	if heats > 1 && sizes[0] < minFirstHeat && lanes >= minFirstHeat {
+		sizes[1] -= minFirstHeat - sizes[0]
+		sizes[0] = minFirstHeat
+	}
+
+	order := laneOrder(lanes)
+	next := 0
+	for h := heats - 1; h >= 0; h-- {
+		for i := 0; i < sizes[h]; i++ {
+			seeded[next].Heat = h + 1
+			seeded[next].Lane = order[i]
+			next++
+		}
+	}
+}
+
+// getHeatRound loads a round of a timed event with its heats. With lock set
+// the round is locked for the rest of the transaction.
+func getHeatRound(q querier, competitionID, eventID int, round string, lock bool) (HeatRound, []HeatLane, error) {
+	query := `
+		SELECT event_id, competition_id, round, lanes, created_at, updated_at
+		FROM heat_rounds
+		WHERE event_id = $1 AND competition_id = $2 AND round = $3`
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	hr := HeatRound{Heats: []Heat{}}
+	err := q.QueryRow(query, eventID, competitionID, round).Scan(&hr.EventID, &hr.CompetitionID, &hr.Round, &hr.Lanes, &hr.CreatedAt, &hr.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return hr, nil, errors.New("heats not found")
+	}
+	if err != nil {
+		return hr, nil, err
+	}
+
+	lanes, err := queryHeatLanes(q, "hl.event_id = $1 AND hl.round = $2", eventID, round)
+	if err != nil {
+		return hr, nil, err
+	}
+
+	for _, lane := range lanes {
+		if !lane.active {
+			continue
+		}
+		if len(hr.Heats) == 0 || hr.Heats[len(hr.Heats)-1].Heat != lane.Heat {
+			hr.Heats = append(hr.Heats, Heat{Heat: lane.Heat})
+		}
+		last := &hr.Heats[len(hr.Heats)-1]
+		last.Lanes = append(last.Lanes, lane)
+	}
+
+	return hr, lanes, nil
+}
+
+// queryHeatLanes loads the heat lanes matching a condition on hl, in heat and
+// lane order, including lanes of participants in the trash
+func queryHeatLanes(q querier, condition string, args ...interface{}) ([]HeatLane, error) {
+	rows, err := q.Query(`
+		SELECT hl.event_id, hl.round, hl.heat, hl.lane, hl.participant_id, p.name, p.club, p.nationality,
+			hl.seed_time, hl.time, p.deleted_at IS NULL AND cp.deleted_at IS NULL
+		FROM heat_lanes hl
+		JOIN participants p ON p.id = hl.participant_id
+		JOIN competition_participants cp ON cp.competition_id = hl.competition_id AND cp.participant_id = hl.participant_id
+		WHERE `+condition+`
+		ORDER BY hl.event_id, hl.round, hl.heat, hl.lane
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lanes []HeatLane
+	for rows.Next() {
+		var l HeatLane
+		err := rows.Scan(&l.EventID, &l.Round, &l.Heat, &l.Lane, &l.ParticipantID, &l.Name, &l.Club, &l.Nationality,
+			&l.SeedTime, &l.Time, &l.active)
+		if err != nil {
+			return nil, err
+		}
+		lanes = append(lanes, l)
+	}
+
+	return lanes, rows.Err()
+}
+
+// getHeatLanes lists a participant's heat lanes within a competition
+func getHeatLanes(q querier, competitionID, participantID int) ([]HeatLane, error) {
+	return queryHeatLanes(q, "hl.competition_id = $1 AND hl.participant_id = $2", competitionID, participantID)
+}
+
+// insertHeatLane stores a participant's heat lane, unless they already have
+// one in the same round
+func insertHeatLane(q querier, competitionID int, l HeatLane) error {
+	_, err := q.Exec(`
+		INSERT INTO heat_lanes (event_id, competition_id, round, heat, lane, participant_id, seed_time, time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (event_id, round, participant_id) DO NOTHING
+	`, l.EventID, competitionID, l.Round, l.Heat, l.Lane, l.ParticipantID, l.SeedTime, l.Time)
+	return err
+}
+
+// lockTimedEvent loads an event of a live competition for a change to its
+// heats and locks it, which serializes all changes to the event's heats
+func lockTimedEvent(q querier, competitionID, eventID int) (Event, error) {
+	if !competitionExists(q, competitionID) {
+		return Event{}, errors.New("event not found")
+	}
+
+	event, err := lockEvent(q, competitionID, eventID)
+	if err != nil {
+		return event, err
+	}
+	if event.ScoringType != "time" {
+		return event, errors.New("heats are only for timed events")
+	}
+
+	return event, nil
+}
+
+// heatRoundExists checks if a round of an event has been seeded
+func heatRoundExists(q querier, eventID int, round string) (bool, error) {
+	var exists bool
+	err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM heat_rounds WHERE event_id = $1 AND round = $2)", eventID, round).Scan(&exists)
+	return exists, err
+}
+
+// heatEntrants lists the participants to seed into a round, fastest first.
+// Preliminaries and timed finals are seeded by entry time, with participants
+// without one last, in competition seeding order and then in order of entry.
+// Finals after preliminaries take the fastest top participants of the
+// preliminaries.
+func heatEntrants(q querier, competitionID, eventID int, round string, top int) ([]HeatLane, error) {
+	hasPrelims := false
+	if round == HeatRoundFinal {
+		var err error
+		if hasPrelims, err = heatRoundExists(q, eventID, HeatRoundPrelim); err != nil {
+			return nil, err
+		}
+	}
+
+	if !hasPrelims {
+		rows, err := q.Query(`
+			SELECT ep.participant_id, ep.entry_time
+			FROM event_participants ep
+			JOIN participants p ON p.id = ep.participant_id
+			JOIN competition_participants cp ON cp.competition_id = ep.competition_id AND cp.participant_id = ep.participant_id
+			WHERE ep.event_id = $1 AND p.deleted_at IS NULL AND cp.deleted_at IS NULL
+			ORDER BY ep.entry_time ASC NULLS LAST, cp.seed ASC NULLS LAST, ep.created_at ASC, ep.participant_id ASC
+		`, eventID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var entrants []HeatLane
+		for rows.Next() {
+			l := HeatLane{EventID: eventID, Round: round}
+			if err := rows.Scan(&l.ParticipantID, &l.SeedTime); err != nil {
+				return nil, err
+			}
+			entrants = append(entrants, l)
+		}
+		return entrants, rows.Err()
+	}
+
+	_, prelims, err := getHeatRound(q, competitionID, eventID, HeatRoundPrelim, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var swum []HeatLane
+	for _, l := range prelims {
+		if l.active && l.Time != nil {
+			swum = append(swum, HeatLane{EventID: eventID, Round: round, ParticipantID: l.ParticipantID, SeedTime: l.Time})
+		}
+	}
+	if len(swum) == 0 {
+		return nil, errors.New("no preliminary times recorded")
+	}
+
+	sort.SliceStable(swum, func(i, j int) bool {
+		return *swum[i].SeedTime < *swum[j].SeedTime
+	})
+	if len(swum) > top {
+		if *swum[top-1].SeedTime == *swum[top].SeedTime {
+			return nil, errors.New("tied times at the last qualifying place need a swim-off")
+		}
+		swum = swum[:top]
+	}
+
+	return swum, nil
+}
+
+// GetHeats retrieves the heats of a round of a timed event
+func GetHeats(competitionID, eventID int, round string) (HeatRound, error) {
+	if !CompetitionExists(competitionID) {
+		return HeatRound{}, errors.New("heats not found")
+	}
+
+	hr, _, err := getHeatRound(DB, competitionID, eventID, round, false)
+	return hr, err
+}
+
+// GenerateHeats seeds a round of a timed event into heats of the given number
+// of lanes. Finals after preliminaries take the top fastest participants.
+func GenerateHeats(meta ChangeMeta, competitionID, eventID int, round string, lanes, top int) (HeatRound, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return HeatRound{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockTimedEvent(tx, competitionID, eventID); err != nil {
+		return HeatRound{}, err
+	}
+
+	exists, err := heatRoundExists(tx, eventID, round)
+	if err != nil {
+		return HeatRound{}, err
+	}
+	if exists {
+		return HeatRound{}, errors.New("heats already exist")
+	}
+	if round == HeatRoundPrelim {
+		finals, err := heatRoundExists(tx, eventID, HeatRoundFinal)
+		if err != nil {
+			return HeatRound{}, err
+		}
+		if finals {
+			return HeatRound{}, errors.New("finals have already been seeded")
+		}
+	}
+
+	entrants, err := heatEntrants(tx, competitionID, eventID, round, top)
+	if err != nil {
+		return HeatRound{}, err
+	}
+	if len(entrants) == 0 {
+		return HeatRound{}, errors.New("the event has no entries")
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO heat_rounds (event_id, competition_id, round, lanes)
+		VALUES ($1, $2, $3, $4)
+	`, eventID, competitionID, round, lanes)
+	if err != nil {
+		return HeatRound{}, err
+	}
+
+	seedHeats(entrants, lanes)
+	for _, l := range entrants {
+		if err := insertHeatLane(tx, competitionID, l); err != nil {
+			return HeatRound{}, err
+		}
+	}
+
+	hr, _, err := getHeatRound(tx, competitionID, eventID, round, false)
+	if err != nil {
+		return HeatRound{}, err
+	}
+
+	if err := recordAudit(tx, meta, "heats", heatEntityID(eventID, round, 0), "create", nil, hr); err != nil {
+		return HeatRound{}, err
+	}
+
+	return hr, tx.commit()
+}
+
+// DeleteHeats discards the seeding of a round, with any times swum in it.
+// Preliminaries cannot be discarded once the finals have been seeded from them.
+func DeleteHeats(meta ChangeMeta, competitionID, eventID int, round string) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockTimedEvent(tx, competitionID, eventID); err != nil {
+		if err.Error() == "heats are only for timed events" {
+			return errors.New("heats not found")
+		}
+		return err
+	}
+
+	before, _, err := getHeatRound(tx, competitionID, eventID, round, true)
+	if err != nil {
+		return err
+	}
+
+	if round == HeatRoundPrelim {
+		finals, err := heatRoundExists(tx, eventID, HeatRoundFinal)
+		if err != nil {
+			return err
+		}
+		if finals {
+			return errors.New("finals have already been seeded")
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM heat_rounds WHERE event_id = $1 AND round = $2", eventID, round); err != nil {
+		return err
+	}
+
+	// Discarded heats take their times with them
+	if err := updateCompetitionMarks(tx, competitionID); err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "heats", heatEntityID(eventID, round, 0), "delete", before, nil); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// MoveHeatLane overrides the seeding by moving a participant to another heat
+// and lane. A lane held by someone else is a conflict unless swap is set, in
+// which case the two trade places. Heats that have times recorded are final.
+func MoveHeatLane(meta ChangeMeta, competitionID, eventID int, round string, participantID, heat, lane int, swap bool) (HeatRound, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return HeatRound{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockTimedEvent(tx, competitionID, eventID); err != nil {
+		if err.Error() == "heats are only for timed events" {
+			return HeatRound{}, errors.New("heats not found")
+		}
+		return HeatRound{}, err
+	}
+
+	hr, lanes, err := getHeatRound(tx, competitionID, eventID, round, true)
+	if err != nil {
+		return HeatRound{}, err
+	}
+
+	if lane < 1 || lane > hr.Lanes {
+		return HeatRound{}, errors.New("lane does not exist")
+	}
+	if heat < 1 || len(hr.Heats) == 0 || heat > hr.Heats[len(hr.Heats)-1].Heat {
+		return HeatRound{}, errors.New("heat does not exist")
+	}
+
+	var moving, occupant *HeatLane
+	swum := map[int]bool{}
+	for i := range lanes {
+		l := &lanes[i]
+		if l.ParticipantID == participantID && l.active {
+			moving = l
+		}
+		if l.Heat == heat && l.Lane == lane {
+			occupant = l
+		}
+		if l.active && l.Time != nil {
+			swum[l.Heat] = true
+		}
+	}
+	if moving == nil {
+		return HeatRound{}, errors.New("participant not in this round")
+	}
+	if moving == occupant {
+		return hr, nil
+	}
+	if swum[moving.Heat] || swum[heat] {
+		return HeatRound{}, errors.New("heat has already been swum")
+	}
+
+	if occupant != nil {
+		switch {
+		case !occupant.active:
+			// The lane of a withdrawn participant is free to take
+			_, err := tx.Exec("DELETE FROM heat_lanes WHERE event_id = $1 AND round = $2 AND participant_id = $3", eventID, round, occupant.ParticipantID)
+			if err != nil {
+				return HeatRound{}, err
+			}
+		case !swap:
+			return HeatRound{}, errors.New("lane is already taken")
+		default:
+			_, err := tx.Exec(`
+				UPDATE heat_lanes SET heat = $4, lane = $5, updated_at = CURRENT_TIMESTAMP
+				WHERE event_id = $1 AND round = $2 AND participant_id = $3
+			`, eventID, round, occupant.ParticipantID, moving.Heat, moving.Lane)
+			if err != nil {
+				return HeatRound{}, err
+			}
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE heat_lanes SET heat = $4, lane = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE event_id = $1 AND round = $2 AND participant_id = $3
+	`, eventID, round, participantID, heat, lane)
+	if err != nil {
+		return HeatRound{}, err
+	}
+
+	_, err = tx.Exec("UPDATE heat_rounds SET updated_at = CURRENT_TIMESTAMP WHERE event_id = $1 AND round = $2", eventID, round)
+	if err != nil {
+		return HeatRound{}, err
+	}
+
+	before := *moving
+	after := before
+	after.Heat, after.Lane = heat, lane
+	if err := recordAudit(tx, meta, "heat_lane", heatEntityID(eventID, round, participantID), "update", before, after); err != nil {
+		return HeatRound{}, err
+	}
+	if occupant != nil && occupant.active {
+		swapped := *occupant
+		swapped.Heat, swapped.Lane = before.Heat, before.Lane
+		if err := recordAudit(tx, meta, "heat_lane", heatEntityID(eventID, round, occupant.ParticipantID), "update", *occupant, swapped); err != nil {
+			return HeatRound{}, err
+		}
+	}
+
+	hr, _, err = getHeatRound(tx, competitionID, eventID, round, false)
+	if err != nil {
+		return HeatRound{}, err
+	}
+
+	return hr, tx.commit()
+}
+
+// RecordHeatTime stores or corrects the time a participant swam in a round;
+// nil clears it, for a participant who did not start
+func RecordHeatTime(meta ChangeMeta, competitionID, eventID int, round string, participantID int, swumTime *float64) (HeatLane, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return HeatLane{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockTimedEvent(tx, competitionID, eventID); err != nil {
+		if err.Error() == "heats are only for timed events" {
+			return HeatLane{}, errors.New("heats not found")
+		}
+		return HeatLane{}, err
+	}
+
+	_, lanes, err := getHeatRound(tx, competitionID, eventID, round, true)
+	if err != nil {
+		return HeatLane{}, err
+	}
+
+	var before *HeatLane
+	for i := range lanes {
+		if lanes[i].ParticipantID == participantID && lanes[i].active {
+			before = &lanes[i]
+		}
+	}
+	if before == nil {
+		return HeatLane{}, errors.New("participant not in this round")
+	}
+
+	after := *before
+	after.Time = swumTime
+	_, err = tx.Exec(`
+		UPDATE heat_lanes SET time = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE event_id = $1 AND round = $2 AND participant_id = $3
+	`, eventID, round, participantID, swumTime)
+	if err != nil {
+		return HeatLane{}, err
+	}
+
+	if err := updateCompetitionMarks(tx, competitionID); err != nil {
+		return HeatLane{}, err
+	}
+	after.MarkFlags, err = getMarkFlags(tx, competitionID, &eventID, round, participantID)
+	if err != nil {
+		return HeatLane{}, err
+	}
+
+	action := "create"
+	if before.Time != nil {
+		action = "update"
+	}
+	if err := recordAudit(tx, meta, "heat_lane", heatEntityID(eventID, round, participantID), action, *before, after); err != nil {
+		return HeatLane{}, err
+	}
+
+	return after, tx.commit()
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLaneOrder(t *testing.T) {
+	tests := []struct {
+		lanes int
+		want  []int
+	}{
+		{1, []int{1}},
+		{2, []int{1, 2}},
+		{5, []int{3, 4, 2, 5, 1}},
+		{6, []int{3, 4, 2, 5, 1, 6}},
+		{8, []int{4, 5, 3, 6, 2, 7, 1, 8}},
+		{10, []int{5, 6, 4, 7, 3, 8, 2, 9, 1, 10}},
+	}
+
+	for _, tt := range tests {
+		if got := laneOrder(tt.lanes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("laneOrder(%d) = %v, want %v", tt.lanes, got, tt.want)
+		}
+	}
+}
+
+func TestSeedHeats(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries int
+		lanes   int
+		// want lists the heat and lane of each entry, fastest first
+		want [][2]int
+	}{
+		{
+			name:    "no entries",
+			entries: 0,
+			lanes:   8,
+			want:    nil,
+		},
+		{
+			name:    "single heat",
+			entries: 5,
+			lanes:   8,
+			want:    [][2]int{{1, 4}, {1, 5}, {1, 3}, {1, 6}, {1, 2}},
+		},
+		{
+			name:    "full heats",
+			entries: 16,
+			lanes:   8,
+			want: [][2]int{
+				{2, 4}, {2, 5}, {2, 3}, {2, 6}, {2, 2}, {2, 7}, {2, 1}, {2, 8},
+				{1, 4}, {1, 5}, {1, 3}, {1, 6}, {1, 2}, {1, 7}, {1, 1}, {1, 8},
+			},
+		},
+		{
+			name:    "first heat topped up",
+			entries: 10,
+			lanes:   8,
+			want: [][2]int{
+				{2, 4}, {2, 5}, {2, 3}, {2, 6}, {2, 2}, {2, 7}, {2, 1},
+				{1, 4}, {1, 5}, {1, 3},
+			},
+		},
+		{
+			name:    "second heat gives up entries",
+			entries: 17,
+			lanes:   8,
+			want: [][2]int{
+				{3, 4}, {3, 5}, {3, 3}, {3, 6}, {3, 2}, {3, 7}, {3, 1}, {3, 8},
+				{2, 4}, {2, 5}, {2, 3}, {2, 6}, {2, 2}, {2, 7},
+				{1, 4}, {1, 5}, {1, 3},
+			},
+		},
+		{
+			name:    "narrow pool is not topped up",
+			entries: 3,
+			lanes:   2,
+			want:    [][2]int{{2, 1}, {2, 2}, {1, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seeded := make([]HeatLane, tt.entries)
+			seedHeats(seeded, tt.lanes)
+
+			var got [][2]int
+			for _, l := range seeded {
+				got = append(got, [2]int{l.Heat, l.Lane})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type IdempotencyKey struct {
+	Key          string
+	Scope        string
+	Method       string
+	Path         string
+	RequestHash  string
+	StatusCode   int
+	ContentType  string
+	ResponseBody []byte
+	Replayable   bool
+	Completed    bool
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+// ReserveIdempotencyKey claims a key for a new request. It returns true when the
+// key was free (or its previous entry had expired) and the caller should process
+// the request, or false together with the stored entry when the key is taken.
+func ReserveIdempotencyKey(k *IdempotencyKey, ttl time.Duration) (bool, IdempotencyKey, error) {
+	var existing IdempotencyKey
+
+	row := DB.QueryRow(`
+		INSERT INTO idempotency_keys (key, scope, method, path, request_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP + make_interval(secs => $6))
+		ON CONFLICT (key, scope, method, path) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			content_type = NULL,
+			response_body = NULL,
+			replayable = TRUE,
+			created_at = CURRENT_TIMESTAMP,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP
+		RETURNING created_at, expires_at
+	`, k.Key, k.Scope, k.Method, k.Path, k.RequestHash, int64(ttl/time.Second))
+
+	err := row.Scan(&k.CreatedAt, &k.ExpiresAt)
+	if err == nil {
+		return true, existing, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, existing, err
+	}
+
+	existing, err = GetIdempotencyKey(k.Key, k.Scope, k.Method, k.Path)
+	return false, existing, err
+}
+
+// GetIdempotencyKey retrieves a stored idempotency entry
+func GetIdempotencyKey(key, scope, method, path string) (IdempotencyKey, error) {
+	var k IdempotencyKey
+	var statusCode sql.NullInt64
+	var contentType sql.NullString
+
+	err := DB.QueryRow(`
+		SELECT key, scope, method, path, request_hash, status_code, content_type, response_body, replayable, created_at, expires_at
+		FROM idempotency_keys
+		WHERE key = $1 AND scope = $2 AND method = $3 AND path = $4
+	`, key, scope, method, path).Scan(&k.Key, &k.Scope, &k.Method, &k.Path, &k.RequestHash,
+		&statusCode, &contentType, &k.ResponseBody, &k.Replayable, &k.CreatedAt, &k.ExpiresAt)
+
+	if err == sql.ErrNoRows {
+		return k, errors.New("idempotency key not found")
+	}
+	if err != nil {
+		return k, err
+	}
+
+	k.Completed = statusCode.Valid
+	k.StatusCode = int(statusCode.Int64)
+	k.ContentType = contentType.String
+
+	return k, nil
+}
+
+// CompleteIdempotencyKey stores the response produced for a reserved key. Only
+// the status code is kept for responses that are not replayable.
+func CompleteIdempotencyKey(k *IdempotencyKey) error {
+	var contentType, body interface{}
+	if k.Replayable {
+		contentType, body = k.ContentType, k.ResponseBody
+	}
+
+	_, err := DB.Exec(`
+		UPDATE idempotency_keys
+		SET status_code = $5, content_type = $6, response_body = $7, replayable = $8
+		WHERE key = $1 AND scope = $2 AND method = $3 AND path = $4
+	`, k.Key, k.Scope, k.Method, k.Path, k.StatusCode, contentType, body, k.Replayable)
+
+	return err
+}
+
+// ReleaseIdempotencyKey removes a reserved key so the request can be retried
+func ReleaseIdempotencyKey(k *IdempotencyKey) error {
+	_, err := DB.Exec(`
+		DELETE FROM idempotency_keys
+		WHERE key = $1 AND scope = $2 AND method = $3 AND path = $4
+	`, k.Key, k.Scope, k.Method, k.Path)
+
+	return err
+}
+
+// PurgeExpiredIdempotencyKeys removes all expired idempotency entries
+func PurgeExpiredIdempotencyKeys() (int64, error) {
+	result, err := DB.Exec("DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+// ImportRow is one participant row of a bulk import together with its outcome
+type ImportRow struct {
+	Line           int        `json:"line"`
+	Name           string     `json:"name"`
+	Email          string     `json:"email"`
+	Locale         string     `json:"locale,omitempty"`
+	DateOfBirth    *time.Time `json:"date_of_birth,omitempty"`
+	Gender         string     `json:"gender,omitempty"`
+	Club           string     `json:"club,omitempty"`
+	Nationality    string     `json:"nationality,omitempty"`
+	Federation     string     `json:"federation,omitempty"`
+	LicenseNumber  string     `json:"license_number,omitempty"`
+	CompetitionIDs []int      `json:"competition_ids"`
+	ParticipantID  int        `json:"participant_id,omitempty"`
+	Errors         []string   `json:"errors,omitempty"`
+}
+
+// importRowErrors lists the errors that reject a single row rather than failing
+// the whole import
+var importRowErrors = map[string]bool{
+	"email already registered":                            true,
+	"license number already registered":                   true,
+	"competition does not exist":                          true,
+	"participant already registered for this competition": true,
+	"participant matches no category":                     true,
+}
+
+// ImportParticipants creates the given participants and registers them for
+// their competitions in a single transaction. Rows that already carry errors
+// are skipped; every other row is applied with the same rules as
+// CreateParticipant and AddParticipantToCompetition, so duplicates within the
+// import are caught as well. The transaction is only committed when no row has
+// errors and dryRun is false; ImportParticipants reports whether it was.
+func ImportParticipants(meta ChangeMeta, rows []ImportRow, dryRun bool) (bool, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	registrationDate := time.Now()
+	failed := false
+
+	for i := range rows {
+		row := &rows[i]
+		if len(row.Errors) > 0 {
+			failed = true
+			continue
+		}
+
+		p := Participant{
+			Name:          row.Name,
+			Email:         row.Email,
+			Locale:        row.Locale,
+			DateOfBirth:   row.DateOfBirth,
+			Gender:        row.Gender,
+			Club:          row.Club,
+			Nationality:   row.Nationality,
+			Federation:    row.Federation,
+			LicenseNumber: row.LicenseNumber,
+		}
+		if err := createParticipant(tx, meta, &p); err != nil {
+			if !importRowErrors[err.Error()] {
+				return false, err
+			}
+			row.Errors = append(row.Errors, err.Error())
+			failed = true
+			continue
+		}
+		row.ParticipantID = p.ID
+
+		for _, competitionID := range row.CompetitionIDs {
+			if err := addParticipantToCompetition(tx, meta, p.ID, competitionID, registrationDate, ""); err != nil {
+				if !importRowErrors[err.Error()] {
+					return false, err
+				}
+				row.Errors = append(row.Errors, err.Error()+" (competition "+strconv.Itoa(competitionID)+")")
+				failed = true
+			}
+		}
+	}
+
+	if failed || dryRun {
+		// Nothing is written, so the IDs assigned along the way are meaningless
+		for i := range rows {
+			rows[i].ParticipantID = 0
+		}
+		return false, nil
+	}
+
+	return true, tx.commit()
+}
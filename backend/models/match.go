@@ -0,0 +1,144 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Match statuses
+const (
+	MatchPending   = "pending"
+	MatchReady     = "ready"
+	MatchCompleted = "completed"
+	MatchBye       = "bye"
+	MatchSkipped   = "skipped"
+)
+
+// Match is a game between two participants of a competition. Matches of a
+// bracket link to the matches their winner and loser move on to.
+type Match struct {
+	ID               int       `json:"id"`
+	CompetitionID    int       `json:"competition_id"`
+	Stage            string    `json:"stage"`
+	Round            int       `json:"round"`
+	Position         int       `json:"position"`
+	Participant1ID   *int      `json:"participant1_id"`
+	Participant1Name string    `json:"participant1_name,omitempty"`
+	Participant2ID   *int      `json:"participant2_id"`
+	Participant2Name string    `json:"participant2_name,omitempty"`
+	Score1           *float64  `json:"score1"`
+	Score2           *float64  `json:"score2"`
+	WinnerID         *int      `json:"winner_id"`
+	Status           string    `json:"status"`
+	NextMatchID      *int      `json:"next_match_id"`
+	NextSlot         *int      `json:"next_slot,omitempty"`
+	LoserMatchID     *int      `json:"loser_match_id"`
+	LoserSlot        *int      `json:"loser_slot,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// done reports whether a match needs no further result
+func (m *Match) done() bool {
+	return m.Status == MatchCompleted || m.Status == MatchBye || m.Status == MatchSkipped
+}
+
+// slot returns the participant in slot 1 or 2
+func (m *Match) slot(slot int) *int {
+	if slot == 1 {
+		return m.Participant1ID
+	}
+	return m.Participant2ID
+}
+
+// setSlot puts a participant, or nobody, in slot 1 or 2
+func (m *Match) setSlot(slot int, participantID *int) {
+	if slot == 1 {
+		m.Participant1ID = participantID
+	} else {
+		m.Participant2ID = participantID
+	}
+}
+
+// loserID returns the participant who lost a completed match, if any
+func (m *Match) loserID() *int {
+	if m.Status != MatchCompleted || m.WinnerID == nil || m.Participant1ID == nil || m.Participant2ID == nil {
+		return nil
+	}
+	if *m.WinnerID == *m.Participant1ID {
+		return m.Participant2ID
+	}
+	return m.Participant1ID
+}
+
+// matchColumns is the column list read by scanMatch
+const matchColumns = `m.id, m.competition_id, m.stage, m.round, m.position,
+	m.participant1_id, COALESCE(p1.name, ''), m.participant2_id, COALESCE(p2.name, ''),
+	m.score1, m.score2, m.winner_id, m.status, m.next_match_id, m.next_slot, m.loser_match_id, m.loser_slot,
+	m.created_at, m.updated_at`
+
+// matchJoins joins the participant names read by scanMatch
+const matchJoins = `LEFT JOIN participants p1 ON p1.id = m.participant1_id
+	LEFT JOIN participants p2 ON p2.id = m.participant2_id`
+
+// scanMatch reads a row selected with matchColumns
+func scanMatch(rows *sql.Rows) (*Match, error) {
+	var m Match
+	err := rows.Scan(&m.ID, &m.CompetitionID, &m.Stage, &m.Round, &m.Position,
+		&m.Participant1ID, &m.Participant1Name, &m.Participant2ID, &m.Participant2Name,
+		&m.Score1, &m.Score2, &m.WinnerID, &m.Status, &m.NextMatchID, &m.NextSlot, &m.LoserMatchID, &m.LoserSlot,
+		&m.CreatedAt, &m.UpdatedAt)
+	return &m, err
+}
+
+// getMatches loads the matches of a competition in the given stages, in
+// round order. With lock set the match rows are locked for the rest of the
+// transaction.
+func getMatches(q querier, competitionID int, stageFilter string, lock bool) ([]*Match, error) {
+	query := `
+		SELECT ` + matchColumns + `
+		FROM matches m
+		` + matchJoins + `
+		WHERE m.competition_id = $1 AND ` + stageFilter + `
+		ORDER BY m.stage, m.round, m.position`
+	if lock {
+		query += " FOR UPDATE OF m"
+	}
+
+	rows, err := q.Query(query, competitionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*Match
+	for rows.Next() {
+		m, err := scanMatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+
+	return matches, rows.Err()
+}
+
+// insertMatch stores a new match without its links to later matches
+func insertMatch(q querier, m *Match) error {
+	return q.QueryRow(`
+		INSERT INTO matches (competition_id, stage, round, position, participant1_id, participant2_id, score1, score2, winner_id, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at
+	`, m.CompetitionID, m.Stage, m.Round, m.Position, m.Participant1ID, m.Participant2ID, m.Score1, m.Score2, m.WinnerID, m.Status).Scan(&m.ID, &m.CreatedAt, &m.UpdatedAt)
+}
+
+// saveMatch stores the participants, scores and status of a match
+func saveMatch(q querier, m *Match) error {
+	return q.QueryRow(`
+		UPDATE matches
+		SET participant1_id = $2, participant2_id = $3, score1 = $4, score2 = $5, winner_id = $6, status = $7,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING updated_at
+	`, m.ID, m.Participant1ID, m.Participant2ID, m.Score1, m.Score2, m.WinnerID, m.Status).Scan(&m.UpdatedAt)
+}
@@ -0,0 +1,301 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// DefaultLocale is the locale of participants who have not chosen one
+const DefaultLocale = "en"
+
+// Notification templates
+const (
+	NotificationRegistrationConfirmed = "registration_confirmed"
+	NotificationRegistrationRemoved   = "registration_removed"
+	NotificationCompetitionChanged    = "competition_changed"
+	NotificationCompetitionCancelled  = "competition_cancelled"
+	NotificationVerifyEmail           = "verify_email"
+	NotificationMagicLink             = "magic_link"
+)
+
+// Notification states
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationDead    = "dead"
+)
+
+type Notification struct {
+	ID            int64           `json:"id"`
+	ParticipantID *int            `json:"participant_id"`
+	Recipient     string          `json:"recipient"`
+	RecipientName string          `json:"recipient_name"`
+	Locale        string          `json:"locale"`
+	Template      string          `json:"template"`
+	Data          json.RawMessage `json:"data"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     *string         `json:"last_error"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at"`
+	SentAt        *time.Time      `json:"sent_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+
+	// Claim numbers the claim a notification is being sent under
+	Claim int `json:"-"`
+}
+
+// NotificationData is the template data of a notification, captured when it is
+// queued so the email describes the change even if it is sent much later
+type NotificationData struct {
+	CompetitionID    int    `json:"competition_id"`
+	CompetitionName  string `json:"competition_name"`
+	Description      string `json:"description"`
+	Date             string `json:"date"`
+	Location         string `json:"location"`
+	PreviousDate     string `json:"previous_date,omitempty"`
+	PreviousLocation string `json:"previous_location,omitempty"`
+	// Link carries a sign-in or confirmation token, so it is dropped once the
+	// notification is sent and left out of notification lists and exports
+	Link string `json:"link,omitempty"`
+}
+
+// notificationData builds the template data describing a competition
+func notificationData(c Competition) NotificationData {
+	return NotificationData{
+		CompetitionID:   c.ID,
+		CompetitionName: c.Name,
+		Description:     c.Description,
+		Date:            c.Date.Format("2006-01-02"),
+		Location:        c.Location,
+	}
+}
+
+// QueueEmail queues a notification for a recipient who may not be a
+// participant yet, such as someone confirming their email address
+func QueueEmail(recipient, recipientName, locale, template string, participantID *int, data NotificationData) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if locale == "" {
+		locale = DefaultLocale
+	}
+
+	_, err = DB.Exec(`
+		INSERT INTO notifications (participant_id, recipient, recipient_name, locale, template, data)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, participantID, recipient, recipientName, locale, template, string(payload))
+
+	return err
+}
+
+// queueParticipantNotification queues a notification about a competition for
+// one participant as part of the caller's transaction
+func queueParticipantNotification(q querier, participantID, competitionID int, template string) error {
+	competition, err := lockCompetition(q, competitionID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(notificationData(competition))
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(`
+		INSERT INTO notifications (participant_id, recipient, recipient_name, locale, template, data)
+		SELECT id, email, name, locale, $2, $3
+		FROM participants
+		WHERE id = $1 AND deleted_at IS NULL
+	`, participantID, template, string(data))
+
+	return err
+}
+
+// queueCompetitionNotifications queues a notification for every participant
+// registered for a competition as part of the caller's transaction
+func queueCompetitionNotifications(q querier, competitionID int, template string, data NotificationData) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(`
+		INSERT INTO notifications (participant_id, recipient, recipient_name, locale, template, data)
+		SELECT p.id, p.email, p.name, p.locale, $2, $3
+		FROM participants p
+		JOIN competition_participants cp ON p.id = cp.participant_id
+		WHERE cp.competition_id = $1 AND p.deleted_at IS NULL AND cp.deleted_at IS NULL
+	`, competitionID, template, string(payload))
+
+	return err
+}
+
+// queueCompetitionChangeNotifications tells registered participants when a
+// competition moves to another date or location. Other edits are not announced.
+func queueCompetitionChangeNotifications(q querier, before, after Competition) error {
+	data := notificationData(after)
+	previous := notificationData(before)
+	if data.Date == previous.Date && data.Location == previous.Location {
+		return nil
+	}
+
+	if data.Date != previous.Date {
+		data.PreviousDate = previous.Date
+	}
+	if data.Location != previous.Location {
+		data.PreviousLocation = previous.Location
+	}
+
+	return queueCompetitionNotifications(q, after.ID, NotificationCompetitionChanged, data)
+}
+
+// GetNotifications retrieves the most recent notifications, optionally only those in the given state
+func GetNotifications(status string, limit int) ([]Notification, error) {
+	rows, err := DB.Query(`
+		SELECT id, participant_id, recipient, recipient_name, locale, template, data - 'link', status, attempts,
+			last_error, next_attempt_at, sent_at, created_at, updated_at
+		FROM notifications
+		WHERE $1 = '' OR status = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+	for rows.Next() {
+		var n Notification
+		var data []byte
+		err := rows.Scan(&n.ID, &n.ParticipantID, &n.Recipient, &n.RecipientName, &n.Locale, &n.Template, &data, &n.Status, &n.Attempts,
+			&n.LastError, &n.NextAttemptAt, &n.SentAt, &n.CreatedAt, &n.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		n.Data = json.RawMessage(data)
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// RetryNotification moves a dead notification back to the queue for another round of attempts
+func RetryNotification(id int64) error {
+	result, err := DB.Exec(`
+		UPDATE notifications
+		SET status = $2, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $3
+	`, id, NotificationPending, NotificationDead)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("dead notification not found")
+	}
+
+	return nil
+}
+
+// PurgeSentNotifications removes notifications sent longer than the retention
+// period ago. Dead notifications are kept until an admin retries them.
+func PurgeSentNotifications(retention time.Duration) (int64, error) {
+	result, err := DB.Exec(`
+		DELETE FROM notifications
+		WHERE status = $1 AND sent_at <= CURRENT_TIMESTAMP - make_interval(secs => $2)
+	`, NotificationSent, int64(retention/time.Second))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ClaimDueNotifications picks notifications that are due to be sent and leases
+// them for the given duration, so concurrent senders skip them. Like webhook
+// deliveries, the outcome is only recorded under the claim it was sent with.
+func ClaimDueNotifications(limit int, lease time.Duration) ([]Notification, error) {
+	rows, err := DB.Query(`
+		WITH due AS (
+			SELECT id
+			FROM notifications
+			WHERE status = $1 AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY next_attempt_at ASC
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE notifications n
+		SET claims = n.claims + 1, next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $3), updated_at = CURRENT_TIMESTAMP
+		FROM due
+		WHERE n.id = due.id
+		RETURNING n.id, n.participant_id, n.recipient, n.recipient_name, n.locale, n.template, n.data, n.attempts, n.claims
+	`, NotificationPending, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+	for rows.Next() {
+		var n Notification
+		var data []byte
+		if err := rows.Scan(&n.ID, &n.ParticipantID, &n.Recipient, &n.RecipientName, &n.Locale, &n.Template, &data, &n.Attempts, &n.Claim); err != nil {
+			return nil, err
+		}
+		n.Data = json.RawMessage(data)
+		n.Status = NotificationPending
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// MarkNotificationSent records that a notification sent under the given claim
+// was handed to the mail server
+func MarkNotificationSent(id int64, claim int) error {
+	result, err := DB.Exec(`
+		UPDATE notifications
+		SET status = $3, attempts = attempts + 1, last_error = NULL, data = data - 'link',
+			next_attempt_at = NULL, sent_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND claims = $2 AND status = $4
+	`, id, claim, NotificationSent, NotificationPending)
+	if err != nil {
+		return err
+	}
+
+	return claimedRowUpdated(result)
+}
+
+// MarkNotificationFailed records a failed sending attempt made under the given
+// claim. A zero retryAfter moves the notification to the dead-letter state
+// instead of scheduling a retry.
+func MarkNotificationFailed(id int64, claim int, message string, retryAfter time.Duration) error {
+	status := NotificationPending
+	var retrySeconds interface{} = retryAfter.Seconds()
+	if retryAfter == 0 {
+		status = NotificationDead
+		retrySeconds = nil
+	}
+
+	result, err := DB.Exec(`
+		UPDATE notifications
+		SET status = $3, attempts = attempts + 1, last_error = $4,
+			next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $5), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND claims = $2 AND status = $6
+	`, id, claim, status, message, retrySeconds, NotificationPending)
+	if err != nil {
+		return err
+	}
+
+	return claimedRowUpdated(result)
+}
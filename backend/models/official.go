@@ -0,0 +1,100 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+type Official struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	CompetitionID *int      `json:"competition_id"`
+	Token         string    `json:"token,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// hashToken hashes an access token for storage
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CanScore checks if the official may submit results for the competition
+func (o *Official) CanScore(competitionID int) bool {
+	return o.CompetitionID == nil || *o.CompetitionID == competitionID
+}
+
+// GetAllOfficials retrieves all officials
+func GetAllOfficials() ([]Official, error) {
+	rows, err := DB.Query(`
+		SELECT id, name, competition_id, created_at
+		FROM officials
+		ORDER BY name ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var officials []Official
+	for rows.Next() {
+		var o Official
+		if err := rows.Scan(&o.ID, &o.Name, &o.CompetitionID, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		officials = append(officials, o)
+	}
+
+	return officials, nil
+}
+
+// CreateOfficial adds a new official. Only a hash of the token is stored.
+func CreateOfficial(o *Official) error {
+	if o.CompetitionID != nil && !CompetitionExists(*o.CompetitionID) {
+		return errors.New("competition does not exist")
+	}
+
+	return DB.QueryRow(`
+		INSERT INTO officials (name, competition_id, token_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, o.Name, o.CompetitionID, hashToken(o.Token)).Scan(&o.ID, &o.CreatedAt)
+}
+
+// GetOfficialByToken retrieves the official an access token belongs to
+func GetOfficialByToken(token string) (Official, error) {
+	var o Official
+	err := DB.QueryRow(`
+		SELECT id, name, competition_id, created_at
+		FROM officials
+		WHERE token_hash = $1
+	`, hashToken(token)).Scan(&o.ID, &o.Name, &o.CompetitionID, &o.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return o, errors.New("official not found")
+	}
+
+	return o, err
+}
+
+// DeleteOfficial removes an official, revoking their token
+func DeleteOfficial(id int) error {
+	result, err := DB.Exec("DELETE FROM officials WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("official not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,281 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Domain event types written to the outbox
+const (
+	EventCompetitionCreated      = "competition.created"
+	EventCompetitionUpdated      = "competition.updated"
+	EventCompetitionDeleted      = "competition.deleted"
+	EventCompetitionRestored     = "competition.restored"
+	EventParticipantRegistered   = "participant.registered"
+	EventParticipantUnregistered = "participant.unregistered"
+	EventResultRecorded          = "result.recorded"
+	EventTeamResultRecorded      = "team_result.recorded"
+	EventMatchCompleted          = "match.completed"
+)
+
+// EventTypes lists every domain event type
+var EventTypes = []string{
+	EventCompetitionCreated,
+	EventCompetitionUpdated,
+	EventCompetitionDeleted,
+	EventCompetitionRestored,
+	EventParticipantRegistered,
+	EventParticipantUnregistered,
+	EventResultRecorded,
+	EventTeamResultRecorded,
+	EventMatchCompleted,
+}
+
+type OutboxEvent struct {
+	ID            int64           `json:"id"`
+	Type          string          `json:"type"`
+	CompetitionID int             `json:"competition_id"`
+	Data          json.RawMessage `json:"data"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+}
+
+// changeTx is a transaction that collects the domain events it records, so they
+// can be published once the transaction has committed
+type changeTx struct {
+	*sql.Tx
+	events []OutboxEvent
+}
+
+// beginChange starts a transaction for a change that may record domain events
+func beginChange() (*changeTx, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &changeTx{Tx: tx}, nil
+}
+
+// commit commits the transaction and then publishes its events for live subscribers
+func (tx *changeTx) commit() error {
+	if err := tx.Tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, e := range tx.events {
+		if err := publishEvent(e); err != nil {
+			log.Printf("Warning: failed to publish event %d: %v", e.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// IsEventType checks if the given name is a known domain event type
+func IsEventType(name string) bool {
+	for _, eventType := range EventTypes {
+		if eventType == name {
+			return true
+		}
+	}
+	return false
+}
+
+// recordEvent writes a domain event about a competition to the outbox as part
+// of the caller's transaction
+func recordEvent(tx *changeTx, eventType string, competitionID int, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	e := OutboxEvent{Type: eventType, CompetitionID: competitionID, Data: payload}
+	err = tx.QueryRow(`
+		INSERT INTO outbox_events (event_type, competition_id, payload)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, eventType, competitionID, string(payload)).Scan(&e.ID, &e.OccurredAt)
+	if err != nil {
+		return err
+	}
+
+	tx.events = append(tx.events, e)
+	return nil
+}
+
+// recordRegistrationEvents writes a registration event for every active
+// registration of a participant, used when the participant is deleted or restored
+func recordRegistrationEvents(tx *changeTx, eventType string, participantID int) error {
+	rows, err := tx.Query(`
+		SELECT cp.competition_id, cp.participant_id, cp.registration_date, cp.category_id, cp.skill_level, cp.seed, cp.created_at, cp.updated_at
+		FROM competition_participants cp
+		JOIN competitions c ON c.id = cp.competition_id
+		WHERE cp.participant_id = $1 AND cp.deleted_at IS NULL AND c.deleted_at IS NULL
+	`, participantID)
+	if err != nil {
+		return err
+	}
+
+	var registrations []CompetitionParticipant
+	for rows.Next() {
+		var cp CompetitionParticipant
+		if err := rows.Scan(&cp.CompetitionID, &cp.ParticipantID, &cp.RegistrationDate, &cp.CategoryID, &cp.SkillLevel, &cp.Seed, &cp.CreatedAt, &cp.UpdatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		registrations = append(registrations, cp)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, cp := range registrations {
+		if err := recordEvent(tx, eventType, cp.CompetitionID, cp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FanOutOutboxEvents creates a webhook delivery for every subscription
+// interested in pending outbox events and marks those events as dispatched
+func FanOutOutboxEvents(limit int) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, event_type
+		FROM outbox_events
+		WHERE dispatched_at IS NULL
+		ORDER BY id ASC
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		if err := rows.Scan(&e.ID, &e.Type); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, e := range events {
+		_, err := tx.Exec(`
+			INSERT INTO webhook_deliveries (subscription_id, event_id)
+			SELECT id, $1
+			FROM webhook_subscriptions
+			WHERE active AND (cardinality(event_types) = 0 OR $2 = ANY(event_types))
+			ON CONFLICT (subscription_id, event_id) DO NOTHING
+		`, e.ID, e.Type)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err := tx.Exec("UPDATE outbox_events SET dispatched_at = CURRENT_TIMESTAMP WHERE id = $1", e.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(events), tx.Commit()
+}
+
+// competitionEventsChannel is the Redis channel carrying live events of a competition
+func competitionEventsChannel(competitionID int) string {
+	return "competitions:" + strconv.Itoa(competitionID) + ":events"
+}
+
+// publishEvent broadcasts a committed event to every backend instance
+func publishEvent(e OutboxEvent) error {
+	if RedisClient == nil {
+		return nil
+	}
+
+	message, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return RedisClient.Publish(ctx, competitionEventsChannel(e.CompetitionID), message).Err()
+}
+
+// SubscribeCompetitionEvents subscribes to the live events of a competition.
+// The caller must close the returned subscription.
+func SubscribeCompetitionEvents(competitionID int) (*redis.PubSub, error) {
+	if RedisClient == nil {
+		return nil, errors.New("redis is not available")
+	}
+
+	pubsub := RedisClient.Subscribe(ctx, competitionEventsChannel(competitionID))
+
+	// Wait for the subscription to be confirmed so no event published afterwards is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	return pubsub, nil
+}
+
+// PurgeDispatchedEvents removes events dispatched longer than the retention
+// period ago once none of their webhook deliveries are left
+func PurgeDispatchedEvents(retention time.Duration) (int64, error) {
+	result, err := DB.Exec(`
+		DELETE FROM outbox_events e
+		WHERE e.dispatched_at <= CURRENT_TIMESTAMP - make_interval(secs => $1)
+			AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.event_id = e.id)
+	`, int64(retention/time.Second))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// GetCompetitionEventsSince retrieves the events of a competition recorded after
+// the given event ID, oldest first
+func GetCompetitionEventsSince(competitionID int, lastID int64, limit int) ([]OutboxEvent, error) {
+	rows, err := DB.Query(`
+		SELECT id, event_type, competition_id, payload, created_at
+		FROM outbox_events
+		WHERE competition_id = $1 AND id > $2
+		ORDER BY id ASC
+		LIMIT $3
+	`, competitionID, lastID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.CompetitionID, &payload, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		e.Data = json.RawMessage(payload)
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -4,29 +4,52 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Participant struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            int        `json:"id"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email"`
+	Locale        string     `json:"locale"`
+	DateOfBirth   *time.Time `json:"date_of_birth"`
+	Gender        string     `json:"gender"`
+	Club          string     `json:"club"`
+	Nationality   string     `json:"nationality"`
+	Federation    string     `json:"federation"`
+	LicenseNumber string     `json:"license_number"`
+	Version       int        `json:"version"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ParticipantFilter narrows down the participants returned by FindParticipants
+type ParticipantFilter struct {
+	CompetitionID int
+	Club          string
+	Nationality   string
 }
 
 type CompetitionParticipant struct {
-	CompetitionID    int       `json:"competition_id"`
-	ParticipantID    int       `json:"participant_id"`
-	RegistrationDate time.Time `json:"registration_date"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	CompetitionID    int        `json:"competition_id"`
+	ParticipantID    int        `json:"participant_id"`
+	RegistrationDate time.Time  `json:"registration_date"`
+	CategoryID       *int       `json:"category_id"`
+	SkillLevel       string     `json:"skill_level"`
+	Seed             *int       `json:"seed"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
 }
 
 // UnmarshalJSON implements custom JSON unmarshaling for Participant
 func (p *Participant) UnmarshalJSON(data []byte) error {
 	type Alias Participant
 	aux := &struct {
+		DateOfBirth string `json:"date_of_birth"`
 		*Alias
 	}{
 		Alias: (*Alias)(p),
@@ -34,14 +57,37 @@ func (p *Participant) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
+	if aux.DateOfBirth != "" {
+		// Full timestamps are accepted too, as found in cached participants
+		parsedDate, err := time.Parse("2006-01-02", aux.DateOfBirth)
+		if err != nil {
+			if parsedDate, err = time.Parse(time.RFC3339, aux.DateOfBirth); err != nil {
+				return err
+			}
+		}
+		p.DateOfBirth = &parsedDate
+	}
 	return nil
 }
 
+// normalizeProfile brings the free-form fields into the form they are stored in
+func (p *Participant) normalizeProfile() {
+	p.Email = NormalizeEmail(p.Email)
+	p.Club = strings.TrimSpace(p.Club)
+	p.Nationality = strings.ToUpper(strings.TrimSpace(p.Nationality))
+	p.Federation = strings.ToUpper(strings.TrimSpace(p.Federation))
+	p.LicenseNumber = strings.TrimSpace(p.LicenseNumber)
+	if p.Locale == "" {
+		p.Locale = DefaultLocale
+	}
+}
+
 // GetAllParticipants retrieves all participants
 func GetAllParticipants() ([]Participant, error) {
 	rows, err := DB.Query(`
-		SELECT id, name, email, created_at, updated_at 
+		SELECT id, name, email, locale, date_of_birth, gender, club, nationality, federation, license_number, version, created_at, updated_at 
 		FROM participants
+		WHERE deleted_at IS NULL
 		ORDER BY created_at DESC
 	`)
 	if err != nil {
@@ -52,7 +98,7 @@ func GetAllParticipants() ([]Participant, error) {
 	var participants []Participant
 	for rows.Next() {
 		var p Participant
-		err := rows.Scan(&p.ID, &p.Name, &p.Email, &p.CreatedAt, &p.UpdatedAt)
+		err := rows.Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -65,10 +111,12 @@ func GetAllParticipants() ([]Participant, error) {
 // GetParticipantsByCompetition retrieves all participants for a specific competition
 func GetParticipantsByCompetition(competitionID int) ([]Participant, error) {
 	rows, err := DB.Query(`
-		SELECT p.id, p.name, p.email, p.created_at, p.updated_at 
+		SELECT p.id, p.name, p.email, p.locale, p.date_of_birth, p.gender, p.club, p.nationality, p.federation, p.license_number, p.version, p.created_at, p.updated_at 
 		FROM participants p
 		JOIN competition_participants cp ON p.id = cp.participant_id
+		JOIN competitions c ON c.id = cp.competition_id
 		WHERE cp.competition_id = $1
+			AND p.deleted_at IS NULL AND cp.deleted_at IS NULL AND c.deleted_at IS NULL
 		ORDER BY cp.registration_date DESC
 	`, competitionID)
 	if err != nil {
@@ -79,7 +127,57 @@ func GetParticipantsByCompetition(competitionID int) ([]Participant, error) {
 	var participants []Participant
 	for rows.Next() {
 		var p Participant
-		err := rows.Scan(&p.ID, &p.Name, &p.Email, &p.CreatedAt, &p.UpdatedAt)
+		err := rows.Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, p)
+	}
+
+	return participants, nil
+}
+
+// FindParticipants retrieves the participants matching a filter. Clubs are
+// matched regardless of case.
+func FindParticipants(f ParticipantFilter) ([]Participant, error) {
+	conditions := []string{"p.deleted_at IS NULL"}
+	var args []interface{}
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	if f.CompetitionID != 0 {
+		addCondition(`EXISTS(
+			SELECT 1 FROM competition_participants cp
+			JOIN competitions c ON c.id = cp.competition_id
+			WHERE cp.participant_id = p.id AND cp.competition_id = ?
+				AND cp.deleted_at IS NULL AND c.deleted_at IS NULL
+		)`, f.CompetitionID)
+	}
+	if f.Club != "" {
+		addCondition("LOWER(p.club) = LOWER(?)", strings.TrimSpace(f.Club))
+	}
+	if f.Nationality != "" {
+		addCondition("p.nationality = ?", strings.ToUpper(strings.TrimSpace(f.Nationality)))
+	}
+
+	rows, err := DB.Query(`
+		SELECT p.id, p.name, p.email, p.locale, p.date_of_birth, p.gender, p.club, p.nationality, p.federation, p.license_number, p.version, p.created_at, p.updated_at
+		FROM participants p
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY p.created_at DESC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []Participant
+	for rows.Next() {
+		var p Participant
+		err := rows.Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -93,10 +191,10 @@ func GetParticipantsByCompetition(competitionID int) ([]Participant, error) {
 func GetParticipant(id int) (Participant, error) {
 	var p Participant
 	err := DB.QueryRow(`
-		SELECT id, name, email, created_at, updated_at 
+		SELECT id, name, email, locale, date_of_birth, gender, club, nationality, federation, license_number, version, created_at, updated_at 
 		FROM participants 
-		WHERE id = $1
-	`, id).Scan(&p.ID, &p.Name, &p.Email, &p.CreatedAt, &p.UpdatedAt)
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id).Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return p, errors.New("participant not found")
@@ -108,10 +206,10 @@ func GetParticipant(id int) (Participant, error) {
 // GetParticipantCompetitions retrieves all competitions for a specific participant
 func GetParticipantCompetitions(participantID int) ([]Competition, error) {
 	rows, err := DB.Query(`
-		SELECT c.id, c.name, c.description, c.date, c.location, c.created_at, c.updated_at
+		SELECT c.id, c.name, c.description, c.date, c.location, c.min_team_size, c.max_team_size, c.discipline, c.series, c.version, c.created_at, c.updated_at
 		FROM competitions c
 		JOIN competition_participants cp ON c.id = cp.competition_id
-		WHERE cp.participant_id = $1
+		WHERE cp.participant_id = $1 AND c.deleted_at IS NULL AND cp.deleted_at IS NULL
 		ORDER BY c.date ASC
 	`, participantID)
 	if err != nil {
@@ -122,7 +220,7 @@ func GetParticipantCompetitions(participantID int) ([]Competition, error) {
 	var competitions []Competition
 	for rows.Next() {
 		var c Competition
-		err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.Date, &c.Location, &c.CreatedAt, &c.UpdatedAt)
+		err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.Date, &c.Location, &c.MinTeamSize, &c.MaxTeamSize, &c.Discipline, &c.Series, &c.Version, &c.CreatedAt, &c.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -132,133 +230,614 @@ func GetParticipantCompetitions(participantID int) ([]Competition, error) {
 	return competitions, nil
 }
 
+// lockParticipant loads a participant, including trashed ones, and locks their
+// row for the rest of the transaction
+func lockParticipant(q querier, id int) (Participant, error) {
+	var p Participant
+	err := q.QueryRow(`
+		SELECT id, name, email, locale, date_of_birth, gender, club, nationality, federation, license_number, version, created_at, updated_at, deleted_at
+		FROM participants
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt)
+
+	return p, err
+}
+
+// lockRegistration loads a competition registration, including trashed ones,
+// and locks its row for the rest of the transaction
+func lockRegistration(q querier, participantID, competitionID int) (CompetitionParticipant, error) {
+	var cp CompetitionParticipant
+	err := q.QueryRow(`
+		SELECT competition_id, participant_id, registration_date, category_id, skill_level, seed, created_at, updated_at, deleted_at
+		FROM competition_participants
+		WHERE participant_id = $1 AND competition_id = $2
+		FOR UPDATE
+	`, participantID, competitionID).Scan(&cp.CompetitionID, &cp.ParticipantID, &cp.RegistrationDate, &cp.CategoryID, &cp.SkillLevel, &cp.Seed, &cp.CreatedAt, &cp.UpdatedAt, &cp.DeletedAt)
+
+	return cp, err
+}
+
+// NormalizeEmail brings an email address into the form it is stored in, so the
+// same address is never registered twice with different case or whitespace
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// emailTaken checks if an email is used by an active participant other than excludeID
+func emailTaken(q querier, email string, excludeID int) (bool, error) {
+	var count int
+	err := q.QueryRow("SELECT COUNT(*) FROM participants WHERE LOWER(email) = $1 AND id != $2 AND deleted_at IS NULL", NormalizeEmail(email), excludeID).Scan(&count)
+	return count > 0, err
+}
+
+// licenseTaken checks if a federation license number is held by an active
+// participant other than excludeID
+func licenseTaken(q querier, federation, licenseNumber string, excludeID int) (bool, error) {
+	if licenseNumber == "" {
+		return false, nil
+	}
+	var count int
+	err := q.QueryRow("SELECT COUNT(*) FROM participants WHERE federation = $1 AND license_number = $2 AND id != $3 AND deleted_at IS NULL", federation, licenseNumber, excludeID).Scan(&count)
+	return count > 0, err
+}
+
 // CreateParticipant adds a new participant to the database
-func CreateParticipant(p *Participant) error {
+func CreateParticipant(meta ChangeMeta, p *Participant) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createParticipant(tx, meta, p); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// createParticipant inserts a participant as part of the caller's transaction
+func createParticipant(tx *changeTx, meta ChangeMeta, p *Participant) error {
+	p.normalizeProfile()
+
 	// Check if email is already used
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM participants WHERE email = $1", p.Email).Scan(&count)
+	taken, err := emailTaken(tx, p.Email, 0)
 	if err != nil {
 		return err
 	}
-	if count > 0 {
+	if taken {
 		return errors.New("email already registered")
 	}
 
-	err = DB.QueryRow(`
-		INSERT INTO participants (name, email)
-		VALUES ($1, $2)
-		RETURNING id, created_at, updated_at
-	`, p.Name, p.Email).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	// Check if the license number is already used
+	taken, err = licenseTaken(tx, p.Federation, p.LicenseNumber, 0)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errors.New("license number already registered")
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO participants (name, email, locale, date_of_birth, gender, club, nationality, federation, license_number)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, version, created_at, updated_at
+	`, p.Name, p.Email, p.Locale, p.DateOfBirth, p.Gender, p.Club, p.Nationality, p.Federation, p.LicenseNumber).Scan(&p.ID, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return recordAudit(tx, meta, "participant", strconv.Itoa(p.ID), "create", nil, p)
+}
+
+// AddParticipantToCompetition adds a participant to a competition. The
+// participant's skill level is only used to pick their category.
+func AddParticipantToCompetition(meta ChangeMeta, participantID, competitionID int, registrationDate time.Time, skillLevel string) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	return err
+	if err := addParticipantToCompetition(tx, meta, participantID, competitionID, registrationDate, skillLevel); err != nil {
+		return err
+	}
+
+	return tx.commit()
 }
 
-// AddParticipantToCompetition adds a participant to a competition
-func AddParticipantToCompetition(participantID, competitionID int, registrationDate time.Time) error {
+// addParticipantToCompetition registers a participant for a competition as part
+// of the caller's transaction
+func addParticipantToCompetition(tx *changeTx, meta ChangeMeta, participantID, competitionID int, registrationDate time.Time, skillLevel string) error {
 	// Check if the competition exists
-	if !CompetitionExists(competitionID) {
+	if !competitionExists(tx, competitionID) {
 		return errors.New("competition does not exist")
 	}
 
 	// Check if the participant exists
-	var exists bool
-	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM participants WHERE id = $1)", participantID).Scan(&exists)
-	if err != nil {
-		return err
-	}
-	if !exists {
+	if !participantExists(tx, participantID) {
 		return errors.New("participant does not exist")
 	}
 
 	// Check if the participant is already registered for this competition
-	err = DB.QueryRow("SELECT EXISTS(SELECT 1 FROM competition_participants WHERE participant_id = $1 AND competition_id = $2)", 
-		participantID, competitionID).Scan(&exists)
+	var before interface{}
+	previous, err := lockRegistration(tx, participantID, competitionID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		if previous.DeletedAt == nil {
+			return errors.New("participant already registered for this competition")
+		}
+		before = previous
+
+		// A new registration starts without the events and team of the removed one
+		if _, err := tx.Exec("DELETE FROM event_participants WHERE competition_id = $1 AND participant_id = $2", competitionID, participantID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM team_members WHERE competition_id = $1 AND participant_id = $2", competitionID, participantID); err != nil {
+			return err
+		}
+		if err := updateCompetitionMarks(tx, competitionID); err != nil {
+			return err
+		}
+	}
+
+	// Competitions with categories only take participants who fit one of them
+	categoryID, err := classifyParticipant(tx, competitionID, participantID, skillLevel)
+	if err != nil {
+		return err
+	}
+
+	// A registration removed earlier is brought back rather than duplicated
+	var after CompetitionParticipant
+	err = tx.QueryRow(`
+		INSERT INTO competition_participants (participant_id, competition_id, registration_date, category_id, skill_level)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (competition_id, participant_id) DO UPDATE
+		SET registration_date = EXCLUDED.registration_date, category_id = EXCLUDED.category_id, skill_level = EXCLUDED.skill_level,
+			seed = NULL, deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		RETURNING competition_id, participant_id, registration_date, category_id, skill_level, seed, created_at, updated_at
+	`, participantID, competitionID, registrationDate, categoryID, skillLevel).Scan(&after.CompetitionID, &after.ParticipantID, &after.RegistrationDate, &after.CategoryID, &after.SkillLevel, &after.Seed, &after.CreatedAt, &after.UpdatedAt)
 	if err != nil {
 		return err
 	}
-	if exists {
-		return errors.New("participant already registered for this competition")
+
+	if err := recordAudit(tx, meta, "registration", registrationEntityID(competitionID, participantID), "create", before, after); err != nil {
+		return err
 	}
 
-	_, err = DB.Exec(`
-		INSERT INTO competition_participants (participant_id, competition_id, registration_date)
-		VALUES ($1, $2, $3)
-	`, participantID, competitionID, registrationDate)
+	if err := recordEvent(tx, EventParticipantRegistered, competitionID, after); err != nil {
+		return err
+	}
 
-	return err
+	return queueParticipantNotification(tx, participantID, competitionID, NotificationRegistrationConfirmed)
 }
 
-// UpdateParticipant updates an existing participant
-func UpdateParticipant(p *Participant) error {
+// UpdateParticipant updates an existing participant. When expectedVersion is
+// non-zero the update only succeeds if the stored version still matches it.
+func UpdateParticipant(meta ChangeMeta, p *Participant, expectedVersion int) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockParticipant(tx, p.ID)
+	if err == sql.ErrNoRows || (err == nil && before.DeletedAt != nil) {
+		return errors.New("participant not found")
+	}
+	if err != nil {
+		return err
+	}
+	if expectedVersion != 0 && before.Version != expectedVersion {
+		return errors.New("participant has been modified")
+	}
+
+	p.normalizeProfile()
+
 	// Check if email is already used by another participant
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM participants WHERE email = $1 AND id != $2", p.Email, p.ID).Scan(&count)
+	taken, err := emailTaken(tx, p.Email, p.ID)
 	if err != nil {
 		return err
 	}
-	if count > 0 {
+	if taken {
 		return errors.New("email already registered")
 	}
 
-	result, err := DB.Exec(`
+	// Check if the license number is already used by another participant
+	taken, err = licenseTaken(tx, p.Federation, p.LicenseNumber, p.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errors.New("license number already registered")
+	}
+
+	err = tx.QueryRow(`
+		UPDATE participants
+		SET name = $2, email = $3, locale = $4, date_of_birth = $5, gender = $6, club = $7, nationality = $8,
+			federation = $9, license_number = $10, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING version, created_at, updated_at
+	`, p.ID, p.Name, p.Email, p.Locale, p.DateOfBirth, p.Gender, p.Club, p.Nationality, p.Federation, p.LicenseNumber).Scan(&p.Version, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "participant", strconv.Itoa(p.ID), "update", before, p); err != nil {
+		return err
+	}
+
+	// A changed date of birth or gender can move the participant to other categories
+	if err := classifyRegistrations(tx, meta, "participant_id", p.ID); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// patchableParticipantColumns lists the columns PatchParticipant may change
+var patchableParticipantColumns = map[string]bool{
+	"name":           true,
+	"email":          true,
+	"locale":         true,
+	"date_of_birth":  true,
+	"gender":         true,
+	"club":           true,
+	"nationality":    true,
+	"federation":     true,
+	"license_number": true,
+}
+
+// PatchParticipant updates only the given columns of a participant. When
+// expectedVersion is non-zero the stored version must still match it.
+func PatchParticipant(meta ChangeMeta, id int, changes map[string]interface{}, expectedVersion int) (Participant, error) {
+	if len(changes) == 0 {
+		return GetParticipant(id)
+	}
+
+	var p Participant
+
+	if email, ok := changes["email"].(string); ok {
+		changes["email"] = NormalizeEmail(email)
+	}
+	for _, column := range []string{"nationality", "federation"} {
+		if value, ok := changes[column].(string); ok {
+			changes[column] = strings.ToUpper(strings.TrimSpace(value))
+		}
+	}
+
+	setClause, args, err := buildSetClause(changes, patchableParticipantColumns, 2)
+	if err != nil {
+		return p, err
+	}
+
+	tx, err := beginChange()
+	if err != nil {
+		return p, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockParticipant(tx, id)
+	if err == sql.ErrNoRows || (err == nil && before.DeletedAt != nil) {
+		return p, errors.New("participant not found")
+	}
+	if err != nil {
+		return p, err
+	}
+	if expectedVersion != 0 && before.Version != expectedVersion {
+		return p, errors.New("participant has been modified")
+	}
+
+	// Check if email is already used by another participant
+	if email, ok := changes["email"].(string); ok {
+		taken, err := emailTaken(tx, email, id)
+		if err != nil {
+			return p, err
+		}
+		if taken {
+			return p, errors.New("email already registered")
+		}
+	}
+
+	// Check if the resulting license number is already used by another participant
+	federation, licenseNumber := before.Federation, before.LicenseNumber
+	if value, ok := changes["federation"].(string); ok {
+		federation = value
+	}
+	if value, ok := changes["license_number"].(string); ok {
+		licenseNumber = value
+	}
+	if federation != before.Federation || licenseNumber != before.LicenseNumber {
+		taken, err := licenseTaken(tx, federation, licenseNumber, id)
+		if err != nil {
+			return p, err
+		}
+		if taken {
+			return p, errors.New("license number already registered")
+		}
+	}
+
+	err = tx.QueryRow(`
 		UPDATE participants
-		SET name = $2, email = $3, updated_at = CURRENT_TIMESTAMP
+		SET `+setClause+`, version = version + 1, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1
-	`, p.ID, p.Name, p.Email)
+		RETURNING id, name, email, locale, date_of_birth, gender, club, nationality, federation, license_number, version, created_at, updated_at
+	`, append([]interface{}{id}, args...)...).Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return p, err
+	}
+
+	if err := recordAudit(tx, meta, "participant", strconv.Itoa(id), "update", before, p); err != nil {
+		return p, err
+	}
+
+	// A changed date of birth or gender can move the participant to other categories
+	if err := classifyRegistrations(tx, meta, "participant_id", id); err != nil {
+		return p, err
+	}
+
+	return p, tx.commit()
+}
 
+// RemoveParticipantFromCompetition moves a participant's registration for a competition to the trash
+func RemoveParticipantFromCompetition(meta ChangeMeta, participantID, competitionID int) error {
+	tx, err := beginChange()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	before, err := lockRegistration(tx, participantID, competitionID)
+	if err == sql.ErrNoRows || (err == nil && before.DeletedAt != nil) {
+		return errors.New("participant not found in competition")
+	}
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return errors.New("participant not found")
+	after := before
+	err = tx.QueryRow(`
+		UPDATE competition_participants 
+		SET deleted_at = CURRENT_TIMESTAMP, withdrawals = withdrawals + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE participant_id = $1 AND competition_id = $2
+		RETURNING updated_at, deleted_at
+	`, participantID, competitionID).Scan(&after.UpdatedAt, &after.DeletedAt)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if err := recordAudit(tx, meta, "registration", registrationEntityID(competitionID, participantID), "delete", before, after); err != nil {
+		return err
+	}
+
+	if err := recordEvent(tx, EventParticipantUnregistered, competitionID, after); err != nil {
+		return err
+	}
+
+	if err := queueParticipantNotification(tx, participantID, competitionID, NotificationRegistrationRemoved); err != nil {
+		return err
+	}
+
+	return tx.commit()
 }
 
-// RemoveParticipantFromCompetition removes a participant from a competition
-func RemoveParticipantFromCompetition(participantID, competitionID int) error {
-	result, err := DB.Exec(`
-		DELETE FROM competition_participants 
-		WHERE participant_id = $1 AND competition_id = $2
-	`, participantID, competitionID)
+// DeleteParticipant moves a participant to the trash. Their registrations are
+// kept so they come back when the participant is restored.
+func DeleteParticipant(meta ChangeMeta, id int) error {
+	tx, err := beginChange()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	before, err := lockParticipant(tx, id)
+	if err == sql.ErrNoRows || (err == nil && before.DeletedAt != nil) {
+		return errors.New("participant not found")
+	}
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return errors.New("participant not found in competition")
+	after := before
+	err = tx.QueryRow(`
+		UPDATE participants
+		SET deleted_at = CURRENT_TIMESTAMP, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING version, updated_at, deleted_at
+	`, id).Scan(&after.Version, &after.UpdatedAt, &after.DeletedAt)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	// Trashed participants give up their personal bests and records
+	if err := updateParticipantMarks(tx, id); err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "participant", strconv.Itoa(id), "delete", before, after); err != nil {
+		return err
+	}
+
+	// Deleting a participant withdraws them from all their competitions
+	if err := recordRegistrationEvents(tx, EventParticipantUnregistered, id); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// ParticipantExists checks if a participant with the given ID exists
+func ParticipantExists(id int) bool {
+	return participantExists(DB, id)
+}
+
+// participantExists checks if a participant exists using the given querier
+func participantExists(q querier, id int) bool {
+	var exists bool
+	err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM participants WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exists)
+	if err != nil {
+		return false
+	}
+	return exists
+}
+
+// RestoreParticipant takes a participant out of the trash
+func RestoreParticipant(meta ChangeMeta, id int) (Participant, error) {
+	var p Participant
+
+	tx, err := beginChange()
+	if err != nil {
+		return p, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockParticipant(tx, id)
+	if err == sql.ErrNoRows || (err == nil && before.DeletedAt == nil) {
+		return p, errors.New("deleted participant not found")
+	}
+	if err != nil {
+		return p, err
+	}
+
+	// The email may have been taken by another participant in the meantime
+	taken, err := emailTaken(tx, before.Email, id)
+	if err != nil {
+		return p, err
+	}
+	if taken {
+		return p, errors.New("email already registered")
+	}
+
+	// So may the license number
+	taken, err = licenseTaken(tx, before.Federation, before.LicenseNumber, id)
+	if err != nil {
+		return p, err
+	}
+	if taken {
+		return p, errors.New("license number already registered")
+	}
+
+	err = tx.QueryRow(`
+		UPDATE participants
+		SET deleted_at = NULL, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING id, name, email, locale, date_of_birth, gender, club, nationality, federation, license_number, version, created_at, updated_at
+	`, id).Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return p, err
+	}
+
+	if err := updateParticipantMarks(tx, id); err != nil {
+		return p, err
+	}
+
+	if err := recordAudit(tx, meta, "participant", strconv.Itoa(id), "restore", before, p); err != nil {
+		return p, err
+	}
+
+	// Restoring a participant brings back all their registrations
+	if err := recordRegistrationEvents(tx, EventParticipantRegistered, id); err != nil {
+		return p, err
+	}
+
+	return p, tx.commit()
 }
 
-// DeleteParticipant removes a participant from the database
-func DeleteParticipant(id int) error {
-	result, err := DB.Exec("DELETE FROM participants WHERE id = $1", id)
+// RestoreParticipantToCompetition takes a participant's registration for a competition out of the trash
+func RestoreParticipantToCompetition(meta ChangeMeta, participantID, competitionID int) error {
+	tx, err := beginChange()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	before, err := lockRegistration(tx, participantID, competitionID)
+	if err == sql.ErrNoRows || (err == nil && before.DeletedAt == nil) {
+		return errors.New("removed registration not found")
+	}
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return errors.New("participant not found")
+	after := before
+	after.DeletedAt = nil
+	err = tx.QueryRow(`
+		UPDATE competition_participants
+		SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE participant_id = $1 AND competition_id = $2
+		RETURNING updated_at
+	`, participantID, competitionID).Scan(&after.UpdatedAt)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if err := recordAudit(tx, meta, "registration", registrationEntityID(competitionID, participantID), "restore", before, after); err != nil {
+		return err
+	}
+
+	// The categories may have changed while the registration was in the trash
+	if err := classifyRegistrations(tx, meta, "participant_id", participantID); err != nil {
+		return err
+	}
+
+	if err := recordEvent(tx, EventParticipantRegistered, competitionID, after); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// GetDeletedParticipants retrieves all participants in the trash
+func GetDeletedParticipants() ([]Participant, error) {
+	rows, err := DB.Query(`
+		SELECT id, name, email, locale, date_of_birth, gender, club, nationality, federation, license_number, version, created_at, updated_at, deleted_at
+		FROM participants
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []Participant
+	for rows.Next() {
+		var p Participant
+		err := rows.Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, p)
+	}
+
+	return participants, nil
+}
+
+// GetDeletedRegistrations retrieves all competition registrations in the trash
+func GetDeletedRegistrations() ([]CompetitionParticipant, error) {
+	rows, err := DB.Query(`
+		SELECT competition_id, participant_id, registration_date, category_id, skill_level, seed, created_at, updated_at, deleted_at
+		FROM competition_participants
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var registrations []CompetitionParticipant
+	for rows.Next() {
+		var cp CompetitionParticipant
+		err := rows.Scan(&cp.CompetitionID, &cp.ParticipantID, &cp.RegistrationDate, &cp.CategoryID, &cp.SkillLevel, &cp.Seed, &cp.CreatedAt, &cp.UpdatedAt, &cp.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		registrations = append(registrations, cp)
+	}
+
+	return registrations, nil
 }
@@ -0,0 +1,695 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultDiscipline is the discipline of competitions that do not name one
+const DefaultDiscipline = "general"
+
+// Rating systems
+const (
+	RatingElo     = "elo"
+	RatingGlicko2 = "glicko2"
+)
+
+// Elo settings: new participants move faster until their rating settles
+const (
+	eloInitial         = 1500
+	eloProvisionalK    = 40
+	eloEstablishedK    = 20
+	eloProvisionalGame = 30
+)
+
+// Glicko-2 settings, from Glickman's description of the system. Every game is
+// rated as its own rating period.
+const (
+	glickoInitialRating     = 1500
+	glickoInitialDeviation  = 350
+	glickoInitialVolatility = 0.06
+	glickoTau               = 0.5
+	glickoScale             = 173.7178
+	glickoEpsilon           = 0.000001
+)
+
+// ratingSystem is the system ratings are computed with
+var ratingSystem = RatingElo
+
+// Rating is a participant's current rating in a discipline, with the games
+// that led to it
+type Rating struct {
+	ParticipantID int            `json:"participant_id"`
+	Discipline    string         `json:"discipline"`
+	System        string         `json:"system"`
+	Rating        float64        `json:"rating"`
+	Deviation     *float64       `json:"deviation,omitempty"`
+	Volatility    *float64       `json:"volatility,omitempty"`
+	Games         int            `json:"games"`
+	Wins          int            `json:"wins"`
+	Draws         int            `json:"draws"`
+	Losses        int            `json:"losses"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	History       []RatingChange `json:"history"`
+}
+
+// RatingChange is the change of a participant's rating from one game
+type RatingChange struct {
+	MatchID         int       `json:"match_id"`
+	CompetitionID   int       `json:"competition_id"`
+	CompetitionName string    `json:"competition_name"`
+	PlayedOn        time.Time `json:"played_on"`
+	OpponentID      int       `json:"opponent_id"`
+	OpponentName    string    `json:"opponent_name"`
+	Score           float64   `json:"score"`
+	RatingBefore    float64   `json:"rating_before"`
+	RatingAfter     float64   `json:"rating_after"`
+	DeviationBefore *float64  `json:"deviation_before,omitempty"`
+	DeviationAfter  *float64  `json:"deviation_after,omitempty"`
+}
+
+// ratingState is a participant's rating while games are replayed
+type ratingState struct {
+	rating     float64
+	deviation  float64
+	volatility float64
+	games      int
+	wins       int
+	draws      int
+	losses     int
+}
+
+// newRatingState returns the rating of a participant without games
+func newRatingState(system string) *ratingState {
+	if system == RatingGlicko2 {
+		return &ratingState{rating: glickoInitialRating, deviation: glickoInitialDeviation, volatility: glickoInitialVolatility}
+	}
+	return &ratingState{rating: eloInitial}
+}
+
+// rateGame updates the ratings of two participants after a game in which a
+// scored scoreA (1 for a win, 0.5 for a draw, 0 for a loss). Both updates use
+// the ratings from before the game.
+func rateGame(system string, a, b *ratingState, scoreA float64) {
+	if system == RatingGlicko2 {
+		newA := glicko2Update(*a, *b, scoreA)
+		newB := glicko2Update(*b, *a, 1-scoreA)
+		a.rating, a.deviation, a.volatility = newA.rating, newA.deviation, newA.volatility
+		b.rating, b.deviation, b.volatility = newB.rating, newB.deviation, newB.volatility
+	} else {
+		expectedA := 1 / (1 + math.Pow(10, (b.rating-a.rating)/400))
+		changeA := eloK(a) * (scoreA - expectedA)
+		changeB := eloK(b) * ((1 - scoreA) - (1 - expectedA))
+		a.rating += changeA
+		b.rating += changeB
+	}
+
+	for _, side := range []struct {
+		s     *ratingState
+		score float64
+	}{{a, scoreA}, {b, 1 - scoreA}} {
+		side.s.games++
+		switch side.score {
+		case 1:
+			side.s.wins++
+		case 0:
+			side.s.losses++
+		default:
+			side.s.draws++
+		}
+	}
+}
+
+// eloK returns the Elo development coefficient of a participant
+func eloK(s *ratingState) float64 {
+	if s.games < eloProvisionalGame {
+		return eloProvisionalK
+	}
+	return eloEstablishedK
+}
+
+// glickoGame is a game rated in a Glicko-2 rating period
+type glickoGame struct {
+	opponent ratingState
+	score    float64
+}
+
+// glicko2Update returns a player's Glicko-2 rating after a single game
+// against an opponent
+func glicko2Update(player, opponent ratingState, score float64) ratingState {
+	return glicko2Period(player, []glickoGame{{opponent, score}})
+}
+
+// glicko2Period returns a player's Glicko-2 rating after a rating period with
+// the given games
+func glicko2Period(player ratingState, games []glickoGame) ratingState {
+	mu := (player.rating - glickoInitialRating) / glickoScale
+	phi := player.deviation / glickoScale
+
+	// The estimated variance v comes from vInverse; improvement sums
+	// g(φj)(sj - Ej) over the games
+	var vInverse, improvement float64
+	for _, game := range games {
+		muJ := (game.opponent.rating - glickoInitialRating) / glickoScale
+		phiJ := game.opponent.deviation / glickoScale
+
+		g := 1 / math.Sqrt(1+3*phiJ*phiJ/(math.Pi*math.Pi))
+		expected := 1 / (1 + math.Exp(-g*(mu-muJ)))
+		vInverse += g * g * expected * (1 - expected)
+		improvement += g * (game.score - expected)
+	}
+	v := 1 / vInverse
+	delta := v * improvement
+
+	// The new volatility, found with the Illinois algorithm
+	a := math.Log(player.volatility * player.volatility)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(glickoTau*glickoTau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEpsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	volatility := math.Exp(A / 2)
+
+	phiStar := math.Sqrt(phi*phi + volatility*volatility)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*improvement
+
+	player.rating = glickoScale*newMu + glickoInitialRating
+	player.deviation = math.Min(glickoScale*newPhi, glickoInitialDeviation)
+	player.volatility = volatility
+	return player
+}
+
+// InitRatings sets the rating system and recomputes the ratings that were
+// computed with another one
+func InitRatings(system string) error {
+	if system != RatingElo && system != RatingGlicko2 {
+		return errors.New("unknown rating system: " + system)
+	}
+	ratingSystem = system
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT DISTINCT discipline FROM ratings WHERE system <> $1", system)
+	if err != nil {
+		return err
+	}
+	var disciplines []string
+	for rows.Next() {
+		var discipline string
+		if err := rows.Scan(&discipline); err != nil {
+			rows.Close()
+			return err
+		}
+		disciplines = append(disciplines, discipline)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, discipline := range disciplines {
+		if err := recomputeRatings(tx, discipline); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// competitionPosition is where a competition's games and marks fall in the
+// replay order of its discipline: by date, then competition ID
+type competitionPosition struct {
+	date          time.Time
+	competitionID int
+}
+
+// before reports whether p comes earlier in the replay order than other
+func (p competitionPosition) before(other competitionPosition) bool {
+	if !p.date.Equal(other.date) {
+		return p.date.Before(other.date)
+	}
+	return p.competitionID < other.competitionID
+}
+
+// getCompetitionPosition loads a competition's discipline and position in the
+// replay order, trashed or not
+func getCompetitionPosition(q querier, competitionID int) (string, competitionPosition, error) {
+	var discipline string
+	position := competitionPosition{competitionID: competitionID}
+	err := q.QueryRow("SELECT discipline, date FROM competitions WHERE id = $1", competitionID).Scan(&discipline, &position.date)
+	return discipline, position, err
+}
+
+// recomputeRatings rebuilds the ratings and rating history of a discipline
+// from its first game
+func recomputeRatings(q querier, discipline string) error {
+	if _, err := q.Exec("DELETE FROM ratings WHERE discipline = $1", discipline); err != nil {
+		return err
+	}
+	return replayRatings(q, discipline, competitionPosition{})
+}
+
+// replayRatings recomputes the ratings of a discipline from a position on by
+// replaying the completed matches of its competitions in a fixed order: by
+// competition date, then round and position. Ratings before the position
+// are taken from the stored history, so the same results always give the
+// same ratings, however and whenever they were entered. Trashed competitions
+// do not count.
+func replayRatings(q querier, discipline string, from competitionPosition) error {
+	// Changes to the same discipline wait for each other, so every replay
+	// sees the results committed before it
+	if _, err := q.Exec("SELECT pg_advisory_xact_lock(hashtext('ratings:' || $1))", discipline); err != nil {
+		return err
+	}
+
+	// Participants who lose games from their history need their rating
+	// rewound even if they have no games left to replay
+	affected := map[int]bool{}
+	rows, err := q.Query(`
+		DELETE FROM rating_history
+		WHERE discipline = $1 AND (played_on, competition_id) >= ($2::date, $3::integer)
+		RETURNING participant_id
+	`, discipline, from.date, from.competitionID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var participantID int
+		if err := rows.Scan(&participantID); err != nil {
+			rows.Close()
+			return err
+		}
+		affected[participantID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = q.Query(`
+		SELECT m.id, m.competition_id, c.date, m.participant1_id, m.participant2_id, m.score1::float8, m.score2::float8
+		FROM matches m
+		JOIN competitions c ON c.id = m.competition_id
+		WHERE c.discipline = $1 AND c.deleted_at IS NULL AND (c.date, c.id) >= ($2::date, $3::integer)
+			AND m.status = 'completed'
+			AND m.participant1_id IS NOT NULL AND m.participant2_id IS NOT NULL
+			AND m.score1 IS NOT NULL AND m.score2 IS NOT NULL
+		ORDER BY c.date, c.id, m.round, m.stage, m.position, m.id
+	`, discipline, from.date, from.competitionID)
+	if err != nil {
+		return err
+	}
+
+	type game struct {
+		matchID, competitionID int
+		playedOn               time.Time
+		player1, player2       int
+		score1, score2         float64
+	}
+	var games []game
+	for rows.Next() {
+		var g game
+		if err := rows.Scan(&g.matchID, &g.competitionID, &g.playedOn, &g.player1, &g.player2, &g.score1, &g.score2); err != nil {
+			rows.Close()
+			return err
+		}
+		games = append(games, g)
+		affected[g.player1] = true
+		affected[g.player2] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(affected) == 0 {
+		return nil
+	}
+
+	states, sequence, err := ratingStatesBefore(q, discipline, affected)
+	if err != nil {
+		return err
+	}
+	state := func(id int) *ratingState {
+		if states[id] == nil {
+			states[id] = newRatingState(ratingSystem)
+		}
+		return states[id]
+	}
+
+	for _, g := range games {
+		p1, p2 := state(g.player1), state(g.player2)
+		before1, before2 := *p1, *p2
+
+		score := 0.5
+		if g.score1 > g.score2 {
+			score = 1
+		} else if g.score1 < g.score2 {
+			score = 0
+		}
+		rateGame(ratingSystem, p1, p2, score)
+
+		sequence++
+		sides := []struct {
+			player, opponent int
+			score            float64
+			before, after    ratingState
+		}{
+			{g.player1, g.player2, score, before1, *p1},
+			{g.player2, g.player1, 1 - score, before2, *p2},
+		}
+		for _, side := range sides {
+			_, err := q.Exec(`
+				INSERT INTO rating_history (participant_id, discipline, sequence, match_id, competition_id, opponent_id, score,
+					rating_before, rating_after, deviation_before, deviation_after, volatility_after, played_on)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			`, side.player, discipline, sequence, g.matchID, g.competitionID, side.opponent, side.score,
+				side.before.rating, side.after.rating, ratingDeviation(side.before), ratingDeviation(side.after),
+				ratingVolatility(side.after), g.playedOn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for participantID := range affected {
+		s := states[participantID]
+		if s == nil {
+			// Every game of the participant was taken back
+			if _, err := q.Exec("DELETE FROM ratings WHERE participant_id = $1 AND discipline = $2", participantID, discipline); err != nil {
+				return err
+			}
+			continue
+		}
+		_, err := q.Exec(`
+			INSERT INTO ratings (participant_id, discipline, system, rating, deviation, volatility, games, wins, draws, losses)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (participant_id, discipline) DO UPDATE
+			SET system = EXCLUDED.system, rating = EXCLUDED.rating, deviation = EXCLUDED.deviation, volatility = EXCLUDED.volatility,
+				games = EXCLUDED.games, wins = EXCLUDED.wins, draws = EXCLUDED.draws, losses = EXCLUDED.losses,
+				updated_at = CURRENT_TIMESTAMP
+		`, participantID, discipline, ratingSystem, s.rating, ratingDeviation(*s), ratingVolatility(*s), s.games, s.wins, s.draws, s.losses)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ratingStatesBefore loads the ratings the given participants had after the
+// games left in the history of a discipline, and the last sequence number
+// used in it. Participants without games are left out.
+func ratingStatesBefore(q querier, discipline string, participants map[int]bool) (map[int]*ratingState, int, error) {
+	var sequence int
+	err := q.QueryRow("SELECT COALESCE(MAX(sequence), 0) FROM rating_history WHERE discipline = $1", discipline).Scan(&sequence)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]int64, 0, len(participants))
+	for id := range participants {
+		ids = append(ids, int64(id))
+	}
+
+	rows, err := q.Query(`
+		SELECT participant_id, COUNT(*),
+			COUNT(*) FILTER (WHERE score = 1), COUNT(*) FILTER (WHERE score = 0.5), COUNT(*) FILTER (WHERE score = 0),
+			(ARRAY_AGG(rating_after ORDER BY sequence DESC))[1],
+			(ARRAY_AGG(deviation_after ORDER BY sequence DESC))[1],
+			(ARRAY_AGG(volatility_after ORDER BY sequence DESC))[1]
+		FROM rating_history
+		WHERE discipline = $1 AND participant_id = ANY($2)
+		GROUP BY participant_id
+	`, discipline, pq.Array(ids))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	states := map[int]*ratingState{}
+	for rows.Next() {
+		var participantID int
+		var deviation, volatility *float64
+		s := newRatingState(ratingSystem)
+		err := rows.Scan(&participantID, &s.games, &s.wins, &s.draws, &s.losses, &s.rating, &deviation, &volatility)
+		if err != nil {
+			return nil, 0, err
+		}
+		if deviation != nil {
+			s.deviation = *deviation
+		}
+		if volatility != nil {
+			s.volatility = *volatility
+		}
+		states[participantID] = s
+	}
+
+	return states, sequence, rows.Err()
+}
+
+// ratingDeviation returns the rating deviation of systems that have one
+func ratingDeviation(s ratingState) *float64 {
+	if ratingSystem != RatingGlicko2 {
+		return nil
+	}
+	return &s.deviation
+}
+
+// ratingVolatility returns the rating volatility of systems that have one
+func ratingVolatility(s ratingState) *float64 {
+	if ratingSystem != RatingGlicko2 {
+		return nil
+	}
+	return &s.volatility
+}
+
+// updateCompetitionRatings replays the ratings of a competition's discipline
+// from the competition on, after its match results changed or it was moved
+// to or out of the trash
+func updateCompetitionRatings(q querier, competitionID int) error {
+	discipline, position, err := getCompetitionPosition(q, competitionID)
+	if err != nil {
+		return err
+	}
+	return replayRatings(q, discipline, position)
+}
+
+// updateParticipantRatings replays the ratings of every discipline a
+// participant has played matches in, from their first competition in it
+func updateParticipantRatings(q querier, participantID int) error {
+	rows, err := q.Query(`
+		SELECT DISTINCT ON (c.discipline) c.discipline, c.date, c.id
+		FROM matches m
+		JOIN competitions c ON c.id = m.competition_id
+		WHERE m.participant1_id = $1 OR m.participant2_id = $1
+		ORDER BY c.discipline, c.date, c.id
+	`, participantID)
+	if err != nil {
+		return err
+	}
+
+	type start struct {
+		discipline string
+		position   competitionPosition
+	}
+	var starts []start
+	for rows.Next() {
+		var s start
+		if err := rows.Scan(&s.discipline, &s.position.date, &s.position.competitionID); err != nil {
+			rows.Close()
+			return err
+		}
+		starts = append(starts, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range starts {
+		if err := replayRatings(q, s.discipline, s.position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveCompetitionRatings replays the ratings affected by a change to a
+// competition's discipline or date
+func moveCompetitionRatings(q querier, before, after Competition) error {
+	from := competitionPosition{before.Date, before.ID}
+	to := competitionPosition{after.Date, after.ID}
+
+	if before.Discipline != after.Discipline {
+		if err := replayRatings(q, before.Discipline, from); err != nil {
+			return err
+		}
+		return replayRatings(q, after.Discipline, to)
+	}
+	if !from.before(to) && !to.before(from) {
+		return nil
+	}
+	if to.before(from) {
+		from = to
+	}
+	return replayRatings(q, after.Discipline, from)
+}
+
+// RecomputeRatings rebuilds the ratings of one discipline, or of all of them
+// when discipline is empty, and returns the disciplines that were rebuilt
+func RecomputeRatings(discipline string) ([]string, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	disciplines := []string{discipline}
+	if discipline == "" {
+		disciplines, err = ratingDisciplines(tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, d := range disciplines {
+		if err := recomputeRatings(tx, d); err != nil {
+			return nil, err
+		}
+	}
+
+	return disciplines, tx.Commit()
+}
+
+// ratingDisciplines lists the disciplines of all competitions and stored ratings
+func ratingDisciplines(q querier) ([]string, error) {
+	rows, err := q.Query(`
+		SELECT discipline FROM competitions
+		UNION
+		SELECT discipline FROM ratings
+		ORDER BY discipline
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	disciplines := []string{}
+	for rows.Next() {
+		var discipline string
+		if err := rows.Scan(&discipline); err != nil {
+			return nil, err
+		}
+		disciplines = append(disciplines, discipline)
+	}
+
+	return disciplines, rows.Err()
+}
+
+// GetParticipantRatings retrieves a participant's ratings with their history,
+// newest game first, optionally for a single discipline
+func GetParticipantRatings(participantID int, discipline string) ([]Rating, error) {
+	if !participantExists(DB, participantID) {
+		return nil, errors.New("participant not found")
+	}
+
+	rows, err := DB.Query(`
+		SELECT participant_id, discipline, system, rating::float8, deviation::float8, volatility::float8,
+			games, wins, draws, losses, updated_at
+		FROM ratings
+		WHERE participant_id = $1 AND ($2 = '' OR discipline = $2)
+		ORDER BY discipline
+	`, participantID, discipline)
+	if err != nil {
+		return nil, err
+	}
+
+	ratings := []Rating{}
+	for rows.Next() {
+		r := Rating{History: []RatingChange{}}
+		err := rows.Scan(&r.ParticipantID, &r.Discipline, &r.System, &r.Rating, &r.Deviation, &r.Volatility,
+			&r.Games, &r.Wins, &r.Draws, &r.Losses, &r.UpdatedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ratings = append(ratings, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range ratings {
+		history, err := getRatingHistory(participantID, ratings[i].Discipline)
+		if err != nil {
+			return nil, err
+		}
+		ratings[i].History = history
+	}
+
+	return ratings, nil
+}
+
+// getRatingHistory loads the rating changes of a participant in a discipline,
+// newest first
+func getRatingHistory(participantID int, discipline string) ([]RatingChange, error) {
+	rows, err := DB.Query(`
+		SELECT h.match_id, h.competition_id, c.name, h.played_on, h.opponent_id, o.name, h.score::float8,
+			h.rating_before::float8, h.rating_after::float8, h.deviation_before::float8, h.deviation_after::float8
+		FROM rating_history h
+		JOIN competitions c ON c.id = h.competition_id
+		JOIN participants o ON o.id = h.opponent_id
+		WHERE h.participant_id = $1 AND h.discipline = $2
+		ORDER BY h.sequence DESC
+	`, participantID, discipline)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []RatingChange{}
+	for rows.Next() {
+		var h RatingChange
+		err := rows.Scan(&h.MatchID, &h.CompetitionID, &h.CompetitionName, &h.PlayedOn, &h.OpponentID, &h.OpponentName, &h.Score,
+			&h.RatingBefore, &h.RatingAfter, &h.DeviationBefore, &h.DeviationAfter)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
+
+	return history, rows.Err()
+}
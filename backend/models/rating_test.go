@@ -0,0 +1,97 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGlicko2Period(t *testing.T) {
+	// The worked example from Glickman's "Example of the Glicko-2 system"
+	player := ratingState{rating: 1500, deviation: 200, volatility: 0.06}
+	games := []glickoGame{
+		{ratingState{rating: 1400, deviation: 30}, 1},
+		{ratingState{rating: 1550, deviation: 100}, 0},
+		{ratingState{rating: 1700, deviation: 300}, 0},
+	}
+
+	got := glicko2Period(player, games)
+	if math.Abs(got.rating-1464.06) > 0.05 {
+		t.Errorf("got rating %.4f, want 1464.06", got.rating)
+	}
+	if math.Abs(got.deviation-151.52) > 0.05 {
+		t.Errorf("got deviation %.4f, want 151.52", got.deviation)
+	}
+	if math.Abs(got.volatility-0.05999) > 0.00001 {
+		t.Errorf("got volatility %.6f, want 0.05999", got.volatility)
+	}
+}
+
+func TestGlicko2Update(t *testing.T) {
+	tests := []struct {
+		name      string
+		score     float64
+		wantRise  bool
+		wantEqual bool
+	}{
+		{"win", 1, true, false},
+		{"draw against an equal", 0.5, false, true},
+		{"loss", 0, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player := *newRatingState(RatingGlicko2)
+			got := glicko2Update(player, player, tt.score)
+
+			switch {
+			case tt.wantEqual && math.Abs(got.rating-player.rating) > 1e-9:
+				t.Errorf("got rating %.4f, want it unchanged", got.rating)
+			case !tt.wantEqual && tt.wantRise != (got.rating > player.rating):
+				t.Errorf("got rating %.4f from %.4f", got.rating, player.rating)
+			}
+			if got.deviation >= player.deviation {
+				t.Errorf("got deviation %.4f, want less than %.4f after a game", got.deviation, player.deviation)
+			}
+		})
+	}
+}
+
+func TestRateGameElo(t *testing.T) {
+	tests := []struct {
+		name         string
+		a, b         ratingState
+		score        float64
+		wantA, wantB float64
+		wantZeroSum  bool
+	}{
+		{"equal ratings, win", ratingState{rating: 1500}, ratingState{rating: 1500}, 1, 1520, 1480, true},
+		{"equal ratings, draw", ratingState{rating: 1500}, ratingState{rating: 1500}, 0.5, 1500, 1500, true},
+		{"favourite wins", ratingState{rating: 1600}, ratingState{rating: 1400}, 1, 1609.6101, 1390.3899, true},
+		{"upset", ratingState{rating: 1600}, ratingState{rating: 1400}, 0, 1569.6101, 1430.3899, true},
+		{"established against provisional", ratingState{rating: 1500, games: eloProvisionalGame}, ratingState{rating: 1500}, 1, 1510, 1480, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := tt.a, tt.b
+			rateGame(RatingElo, &a, &b, tt.score)
+			if math.Abs(a.rating-tt.wantA) > 0.0001 || math.Abs(b.rating-tt.wantB) > 0.0001 {
+				t.Errorf("got %.4f and %.4f, want %.4f and %.4f", a.rating, b.rating, tt.wantA, tt.wantB)
+			}
+			if tt.wantZeroSum && math.Abs(a.rating+b.rating-tt.a.rating-tt.b.rating) > 1e-9 {
+				t.Errorf("ratings changed by %.4f in total, want 0", a.rating+b.rating-tt.a.rating-tt.b.rating)
+			}
+
+			// Rating the game from the other side gives the same result
+			b2, a2 := tt.b, tt.a
+			rateGame(RatingElo, &b2, &a2, 1-tt.score)
+			if math.Abs(a2.rating-a.rating) > 1e-9 || math.Abs(b2.rating-b.rating) > 1e-9 {
+				t.Errorf("got %.4f and %.4f from the other side, want %.4f and %.4f", a2.rating, b2.rating, a.rating, b.rating)
+			}
+
+			if a.games != tt.a.games+1 || b.games != tt.b.games+1 {
+				t.Errorf("got %d and %d games", a.games, b.games)
+			}
+		})
+	}
+}
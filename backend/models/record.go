@@ -0,0 +1,678 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Record scopes
+const (
+	RecordVenue  = "venue"
+	RecordSeries = "series"
+)
+
+// MarkFlags tells whether a mark was a personal best or a record when it was
+// achieved
+type MarkFlags struct {
+	PersonalBest bool `json:"personal_best,omitempty"`
+	VenueRecord  bool `json:"venue_record,omitempty"`
+	SeriesRecord bool `json:"series_record,omitempty"`
+}
+
+// Performance is a mark in a participant's progression. Competition scores
+// have an empty event; times swum in heats are lower-is-better marks of the
+// event they were swum in.
+type Performance struct {
+	CompetitionID   int       `json:"competition_id"`
+	CompetitionName string    `json:"competition_name"`
+	Venue           string    `json:"venue"`
+	Series          string    `json:"series"`
+	Discipline      string    `json:"discipline"`
+	Event           string    `json:"event"`
+	EventID         *int      `json:"event_id"`
+	Round           string    `json:"round,omitempty"`
+	Mark            float64   `json:"mark"`
+	LowerIsBetter   bool      `json:"lower_is_better"`
+	AchievedOn      time.Time `json:"achieved_on"`
+	PersonalBest    bool      `json:"personal_best"`
+	VenueRecord     bool      `json:"venue_record"`
+	SeriesRecord    bool      `json:"series_record"`
+}
+
+// PersonalBest is a participant's best mark in an event of a discipline
+type PersonalBest struct {
+	ParticipantID   int       `json:"participant_id"`
+	Discipline      string    `json:"discipline"`
+	Event           string    `json:"event"`
+	Mark            float64   `json:"mark"`
+	LowerIsBetter   bool      `json:"lower_is_better"`
+	CompetitionID   int       `json:"competition_id"`
+	CompetitionName string    `json:"competition_name"`
+	AchievedOn      time.Time `json:"achieved_on"`
+}
+
+// Record is the best mark in an event of a discipline at a venue or in a
+// competition series
+type Record struct {
+	Scope           string    `json:"scope"`
+	Name            string    `json:"name"`
+	Discipline      string    `json:"discipline"`
+	Event           string    `json:"event"`
+	Mark            float64   `json:"mark"`
+	LowerIsBetter   bool      `json:"lower_is_better"`
+	ParticipantID   int       `json:"participant_id"`
+	ParticipantName string    `json:"participant_name"`
+	CompetitionID   int       `json:"competition_id"`
+	CompetitionName string    `json:"competition_name"`
+	AchievedOn      time.Time `json:"achieved_on"`
+}
+
+// mark is a performance while the marks of a discipline are replayed
+type mark struct {
+	participantID int
+	competitionID int
+	achievedOn    time.Time
+	venue         string
+	series        string
+	event         string
+	eventID       *int
+	round         string
+	value         float64
+	lowerIsBetter bool
+	venueKey      string
+	seriesKey     string
+}
+
+// beats reports whether m is strictly better than other
+func (m *mark) beats(other *mark) bool {
+	if m.lowerIsBetter {
+		return m.value < other.value
+	}
+	return m.value > other.value
+}
+
+// recordKey returns how a venue or series name is matched: trimmed and in
+// lower case
+func recordKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// recomputeMarks rebuilds the performances, personal bests and records of a
+// discipline from its first mark
+func recomputeMarks(q querier, discipline string) error {
+	if _, err := q.Exec("DELETE FROM personal_bests WHERE discipline = $1", discipline); err != nil {
+		return err
+	}
+	if _, err := q.Exec("DELETE FROM records WHERE discipline = $1", discipline); err != nil {
+		return err
+	}
+	return replayMarks(q, discipline, competitionPosition{})
+}
+
+// personalBestKey identifies a personal best within a discipline
+type personalBestKey struct {
+	participantID int
+	event         string
+}
+
+// recordBestKey identifies a record within a discipline
+type recordBestKey struct {
+	scope, key, event string
+}
+
+// replayMarks recomputes the performances, personal bests and records of a
+// discipline from a position on by replaying its marks in a fixed order: by
+// competition date, preliminaries before finals, then best mark first. A mark
+// is a personal best or record if it beats every earlier one, so the first
+// mark in an event sets both. The bests before the position are taken from
+// the stored performances. Trashed competitions and participants do not
+// count.
+func replayMarks(q querier, discipline string, from competitionPosition) error {
+	// Changes to the same discipline wait for each other, so every replay
+	// sees the marks committed before it
+	if _, err := q.Exec("SELECT pg_advisory_xact_lock(hashtext('marks:' || $1))", discipline); err != nil {
+		return err
+	}
+
+	// Bests that lose marks need to be rewound even if no marks are left to
+	// replay for them
+	personalBests := map[personalBestKey]*mark{}
+	records := map[recordBestKey]*mark{}
+	affect := func(participantID int, event, venueKey, seriesKey string) {
+		personalBests[personalBestKey{participantID, event}] = nil
+		if venueKey != "" {
+			records[recordBestKey{RecordVenue, venueKey, event}] = nil
+		}
+		if seriesKey != "" {
+			records[recordBestKey{RecordSeries, seriesKey, event}] = nil
+		}
+	}
+
+	rows, err := q.Query(`
+		DELETE FROM performances
+		WHERE discipline = $1 AND (achieved_on, competition_id) >= ($2::date, $3::integer)
+		RETURNING participant_id, event, venue_key, series_key
+	`, discipline, from.date, from.competitionID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var participantID int
+		var event, venueKey, seriesKey string
+		if err := rows.Scan(&participantID, &event, &venueKey, &seriesKey); err != nil {
+			rows.Close()
+			return err
+		}
+		affect(participantID, event, venueKey, seriesKey)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = q.Query(`
+		SELECT r.participant_id, c.id, c.date, c.location, c.series, '' AS event, NULL::integer AS event_id, '' AS round,
+			r.score::float8 AS value, FALSE AS lower_is_better, 0 AS round_order, -r.score::float8 AS rank_value
+		FROM results r
+		JOIN competitions c ON c.id = r.competition_id
+		JOIN participants p ON p.id = r.participant_id
+		WHERE c.discipline = $1 AND c.deleted_at IS NULL AND p.deleted_at IS NULL
+			AND (c.date, c.id) >= ($2::date, $3::integer)
+		UNION ALL
+		SELECT l.participant_id, c.id, c.date, c.location, c.series, LOWER(TRIM(e.name)), e.id, l.round,
+			l.time::float8, TRUE, CASE l.round WHEN 'prelim' THEN 0 ELSE 1 END, l.time::float8
+		FROM heat_lanes l
+		JOIN events e ON e.id = l.event_id
+		JOIN competitions c ON c.id = l.competition_id
+		JOIN participants p ON p.id = l.participant_id
+		WHERE c.discipline = $1 AND c.deleted_at IS NULL AND p.deleted_at IS NULL
+			AND (c.date, c.id) >= ($2::date, $3::integer) AND l.time IS NOT NULL
+		ORDER BY date, id, round_order, event, rank_value, participant_id
+	`, discipline, from.date, from.competitionID)
+	if err != nil {
+		return err
+	}
+
+	var marks []mark
+	for rows.Next() {
+		var m mark
+		var roundOrder int
+		var rankValue float64
+		err := rows.Scan(&m.participantID, &m.competitionID, &m.achievedOn, &m.venue, &m.series, &m.event, &m.eventID, &m.round,
+			&m.value, &m.lowerIsBetter, &roundOrder, &rankValue)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		m.venueKey, m.seriesKey = recordKey(m.venue), recordKey(m.series)
+		marks = append(marks, m)
+		affect(m.participantID, m.event, m.venueKey, m.seriesKey)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(personalBests) == 0 {
+		return nil
+	}
+
+	sequence, err := loadBestsBefore(q, discipline, personalBests, records)
+	if err != nil {
+		return err
+	}
+
+	// setRecord makes m the record of a scope if it beats the current one
+	setRecord := func(scope, key string, m *mark) bool {
+		if key == "" {
+			return false
+		}
+		recordKey := recordBestKey{scope, key, m.event}
+		if current := records[recordKey]; current != nil && !m.beats(current) {
+			return false
+		}
+		records[recordKey] = m
+		return true
+	}
+
+	for i := range marks {
+		m := &marks[i]
+
+		var flags MarkFlags
+		key := personalBestKey{m.participantID, m.event}
+		if current := personalBests[key]; current == nil || m.beats(current) {
+			personalBests[key] = m
+			flags.PersonalBest = true
+		}
+		flags.VenueRecord = setRecord(RecordVenue, m.venueKey, m)
+		flags.SeriesRecord = setRecord(RecordSeries, m.seriesKey, m)
+
+		sequence++
+		_, err := q.Exec(`
+			INSERT INTO performances (participant_id, discipline, event, sequence, competition_id, event_id, round, mark,
+				lower_is_better, achieved_on, venue_key, series_key, personal_best, venue_record, series_record)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		`, m.participantID, discipline, m.event, sequence, m.competitionID, m.eventID, m.round, m.value,
+			m.lowerIsBetter, m.achievedOn, m.venueKey, m.seriesKey, flags.PersonalBest, flags.VenueRecord, flags.SeriesRecord)
+		if err != nil {
+			return err
+		}
+	}
+
+	for key, m := range personalBests {
+		if m == nil {
+			_, err := q.Exec("DELETE FROM personal_bests WHERE participant_id = $1 AND discipline = $2 AND event = $3",
+				key.participantID, discipline, key.event)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		_, err := q.Exec(`
+			INSERT INTO personal_bests (participant_id, discipline, event, mark, lower_is_better, competition_id, achieved_on)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (participant_id, discipline, event) DO UPDATE
+			SET mark = EXCLUDED.mark, lower_is_better = EXCLUDED.lower_is_better, competition_id = EXCLUDED.competition_id,
+				achieved_on = EXCLUDED.achieved_on
+		`, key.participantID, discipline, key.event, m.value, m.lowerIsBetter, m.competitionID, m.achievedOn)
+		if err != nil {
+			return err
+		}
+	}
+
+	for key, m := range records {
+		if m == nil {
+			_, err := q.Exec("DELETE FROM records WHERE scope = $1 AND scope_key = $2 AND discipline = $3 AND event = $4",
+				key.scope, key.key, discipline, key.event)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		name := m.venue
+		if key.scope == RecordSeries {
+			name = m.series
+		}
+		_, err := q.Exec(`
+			INSERT INTO records (scope, scope_key, name, discipline, event, mark, lower_is_better, participant_id, competition_id, achieved_on)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (scope, scope_key, discipline, event) DO UPDATE
+			SET name = EXCLUDED.name, mark = EXCLUDED.mark, lower_is_better = EXCLUDED.lower_is_better,
+				participant_id = EXCLUDED.participant_id, competition_id = EXCLUDED.competition_id, achieved_on = EXCLUDED.achieved_on
+		`, key.scope, key.key, strings.TrimSpace(name), discipline, key.event, m.value, m.lowerIsBetter, m.participantID,
+			m.competitionID, m.achievedOn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadBestsBefore fills in the personal bests and records the stored
+// performances of a discipline hold for the given keys, and returns the last
+// sequence number used. Of equal marks the earliest holds the best.
+func loadBestsBefore(q querier, discipline string, personalBests map[personalBestKey]*mark, records map[recordBestKey]*mark) (int, error) {
+	var sequence int
+	err := q.QueryRow("SELECT COALESCE(MAX(sequence), 0) FROM performances WHERE discipline = $1", discipline).Scan(&sequence)
+	if err != nil {
+		return 0, err
+	}
+
+	participants := map[int64]bool{}
+	for key := range personalBests {
+		participants[int64(key.participantID)] = true
+	}
+	keys := map[string][]string{RecordVenue: {}, RecordSeries: {}}
+	for key := range records {
+		keys[key.scope] = append(keys[key.scope], key.key)
+	}
+	ids := make([]int64, 0, len(participants))
+	for id := range participants {
+		ids = append(ids, id)
+	}
+
+	type best struct {
+		query string
+		arg   interface{}
+		set   func(m *mark)
+	}
+	bests := []best{
+		{`
+			SELECT DISTINCT ON (p.participant_id, p.event) p.participant_id, p.event, p.mark::float8, p.lower_is_better,
+				p.competition_id, p.achieved_on, p.venue_key, p.series_key, c.location, c.series
+			FROM performances p
+			JOIN competitions c ON c.id = p.competition_id
+			WHERE p.discipline = $1 AND p.participant_id = ANY($2)
+			ORDER BY p.participant_id, p.event, CASE WHEN p.lower_is_better THEN p.mark ELSE -p.mark END, p.sequence
+		`, pq.Array(ids), func(m *mark) {
+			key := personalBestKey{m.participantID, m.event}
+			if _, ok := personalBests[key]; ok {
+				personalBests[key] = m
+			}
+		}},
+	}
+	for _, scope := range []string{RecordVenue, RecordSeries} {
+		scope := scope
+		column := "p.venue_key"
+		if scope == RecordSeries {
+			column = "p.series_key"
+		}
+		bests = append(bests, best{`
+			SELECT DISTINCT ON (` + column + `, p.event) p.participant_id, p.event, p.mark::float8, p.lower_is_better,
+				p.competition_id, p.achieved_on, p.venue_key, p.series_key, c.location, c.series
+			FROM performances p
+			JOIN competitions c ON c.id = p.competition_id
+			WHERE p.discipline = $1 AND ` + column + ` = ANY($2)
+			ORDER BY ` + column + `, p.event, CASE WHEN p.lower_is_better THEN p.mark ELSE -p.mark END, p.sequence
+		`, pq.Array(keys[scope]), func(m *mark) {
+			key := recordBestKey{scope, m.venueKey, m.event}
+			if scope == RecordSeries {
+				key.key = m.seriesKey
+			}
+			if _, ok := records[key]; ok {
+				records[key] = m
+			}
+		}})
+	}
+
+	for _, b := range bests {
+		rows, err := q.Query(b.query, discipline, b.arg)
+		if err != nil {
+			return 0, err
+		}
+		for rows.Next() {
+			m := &mark{}
+			err := rows.Scan(&m.participantID, &m.event, &m.value, &m.lowerIsBetter, &m.competitionID, &m.achievedOn,
+				&m.venueKey, &m.seriesKey, &m.venue, &m.series)
+			if err != nil {
+				rows.Close()
+				return 0, err
+			}
+			b.set(m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	return sequence, nil
+}
+
+// updateCompetitionMarks replays the personal bests and records of a
+// competition's discipline from the competition on, after its results or
+// times changed or it was moved to or out of the trash
+func updateCompetitionMarks(q querier, competitionID int) error {
+	discipline, position, err := getCompetitionPosition(q, competitionID)
+	if err != nil {
+		return err
+	}
+	return replayMarks(q, discipline, position)
+}
+
+// updateParticipantMarks replays the personal bests and records of every
+// discipline a participant has marks in, from their first competition in it
+func updateParticipantMarks(q querier, participantID int) error {
+	rows, err := q.Query(`
+		SELECT DISTINCT ON (c.discipline) c.discipline, c.date, c.id
+		FROM competitions c
+		WHERE c.id IN (
+			SELECT competition_id FROM results WHERE participant_id = $1
+			UNION
+			SELECT competition_id FROM heat_lanes WHERE participant_id = $1
+		)
+		ORDER BY c.discipline, c.date, c.id
+	`, participantID)
+	if err != nil {
+		return err
+	}
+
+	type start struct {
+		discipline string
+		position   competitionPosition
+	}
+	var starts []start
+	for rows.Next() {
+		var s start
+		if err := rows.Scan(&s.discipline, &s.position.date, &s.position.competitionID); err != nil {
+			rows.Close()
+			return err
+		}
+		starts = append(starts, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range starts {
+		if err := replayMarks(q, s.discipline, s.position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveCompetitionMarks replays the personal bests and records affected by a
+// change to a competition's discipline, date, venue or series
+func moveCompetitionMarks(q querier, before, after Competition) error {
+	from := competitionPosition{before.Date, before.ID}
+	to := competitionPosition{after.Date, after.ID}
+
+	if before.Discipline != after.Discipline {
+		if err := replayMarks(q, before.Discipline, from); err != nil {
+			return err
+		}
+		return replayMarks(q, after.Discipline, to)
+	}
+	if !from.before(to) && !to.before(from) && before.Location == after.Location && before.Series == after.Series {
+		return nil
+	}
+	if to.before(from) {
+		from = to
+	}
+	return replayMarks(q, after.Discipline, from)
+}
+
+// getMarkFlags loads whether a participant's mark in a competition, or in a
+// round of one of its events, was a personal best or record
+func getMarkFlags(q querier, competitionID int, eventID *int, round string, participantID int) (MarkFlags, error) {
+	var flags MarkFlags
+	err := q.QueryRow(`
+		SELECT personal_best, venue_record, series_record
+		FROM performances
+		WHERE competition_id = $1 AND participant_id = $2 AND event_id IS NOT DISTINCT FROM $3 AND round = $4
+	`, competitionID, participantID, eventID, round).Scan(&flags.PersonalBest, &flags.VenueRecord, &flags.SeriesRecord)
+	if err == sql.ErrNoRows {
+		return flags, nil
+	}
+	return flags, err
+}
+
+// RecomputeRecords rebuilds the personal bests and records of one discipline,
+// or of all of them when discipline is empty, and returns the disciplines
+// that were rebuilt
+func RecomputeRecords(discipline string) ([]string, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	disciplines := []string{discipline}
+	if discipline == "" {
+		disciplines, err = markDisciplines(tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, d := range disciplines {
+		if err := recomputeMarks(tx, d); err != nil {
+			return nil, err
+		}
+	}
+
+	return disciplines, tx.Commit()
+}
+
+// markDisciplines lists the disciplines of all competitions and stored marks
+func markDisciplines(q querier) ([]string, error) {
+	rows, err := q.Query(`
+		SELECT discipline FROM competitions
+		UNION
+		SELECT discipline FROM performances
+		ORDER BY discipline
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	disciplines := []string{}
+	for rows.Next() {
+		var discipline string
+		if err := rows.Scan(&discipline); err != nil {
+			return nil, err
+		}
+		disciplines = append(disciplines, discipline)
+	}
+
+	return disciplines, rows.Err()
+}
+
+// GetPersonalBests retrieves a participant's personal bests, optionally for
+// a single discipline
+func GetPersonalBests(participantID int, discipline string) ([]PersonalBest, error) {
+	if !participantExists(DB, participantID) {
+		return nil, errors.New("participant not found")
+	}
+
+	rows, err := DB.Query(`
+		SELECT pb.participant_id, pb.discipline, pb.event, pb.mark::float8, pb.lower_is_better, pb.competition_id, c.name, pb.achieved_on
+		FROM personal_bests pb
+		JOIN competitions c ON c.id = pb.competition_id
+		WHERE pb.participant_id = $1 AND ($2 = '' OR pb.discipline = $2)
+		ORDER BY pb.discipline, pb.event
+	`, participantID, discipline)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bests := []PersonalBest{}
+	for rows.Next() {
+		var pb PersonalBest
+		err := rows.Scan(&pb.ParticipantID, &pb.Discipline, &pb.Event, &pb.Mark, &pb.LowerIsBetter, &pb.CompetitionID,
+			&pb.CompetitionName, &pb.AchievedOn)
+		if err != nil {
+			return nil, err
+		}
+		bests = append(bests, pb)
+	}
+
+	return bests, rows.Err()
+}
+
+// GetProgression retrieves every mark of a participant, oldest first,
+// optionally for a single discipline and event
+func GetProgression(participantID int, discipline string, event *string) ([]Performance, error) {
+	if !participantExists(DB, participantID) {
+		return nil, errors.New("participant not found")
+	}
+
+	var eventKey *string
+	if event != nil {
+		key := recordKey(*event)
+		eventKey = &key
+	}
+
+	rows, err := DB.Query(`
+		SELECT p.competition_id, c.name, c.location, c.series, p.discipline, p.event, p.event_id, p.round, p.mark::float8,
+			p.lower_is_better, p.achieved_on, p.personal_best, p.venue_record, p.series_record
+		FROM performances p
+		JOIN competitions c ON c.id = p.competition_id
+		WHERE p.participant_id = $1 AND ($2 = '' OR p.discipline = $2) AND ($3::text IS NULL OR p.event = $3)
+		ORDER BY p.discipline, p.event, p.sequence
+	`, participantID, discipline, eventKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progression := []Performance{}
+	for rows.Next() {
+		var p Performance
+		err := rows.Scan(&p.CompetitionID, &p.CompetitionName, &p.Venue, &p.Series, &p.Discipline, &p.Event, &p.EventID, &p.Round,
+			&p.Mark, &p.LowerIsBetter, &p.AchievedOn, &p.PersonalBest, &p.VenueRecord, &p.SeriesRecord)
+		if err != nil {
+			return nil, err
+		}
+		progression = append(progression, p)
+	}
+
+	return progression, rows.Err()
+}
+
+// GetRecords retrieves the records of a scope, optionally only those of one
+// venue or series, discipline and event. Empty filters match everything.
+func GetRecords(scope, name, discipline string, event *string) ([]Record, error) {
+	var eventKey *string
+	if event != nil {
+		key := recordKey(*event)
+		eventKey = &key
+	}
+
+	return queryRecords(`
+		($1 = '' OR r.scope = $1) AND ($2 = '' OR r.scope_key = $2) AND ($3 = '' OR r.discipline = $3)
+			AND ($4::text IS NULL OR r.event = $4)
+	`, scope, recordKey(name), discipline, eventKey)
+}
+
+// GetCompetitionRecords retrieves the records of a competition's discipline
+// at its venue and in its series, such as the meet records to beat
+func GetCompetitionRecords(competitionID int) ([]Record, error) {
+	competition, err := GetCompetition(competitionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryRecords(`
+		r.discipline = $1 AND ((r.scope = 'venue' AND r.scope_key = $2) OR (r.scope = 'series' AND r.scope_key = $3))
+	`, competition.Discipline, recordKey(competition.Location), recordKey(competition.Series))
+}
+
+// queryRecords loads the records matching a condition on records r
+func queryRecords(condition string, args ...interface{}) ([]Record, error) {
+	rows, err := DB.Query(`
+		SELECT r.scope, r.name, r.discipline, r.event, r.mark::float8, r.lower_is_better, r.participant_id, p.name,
+			r.competition_id, c.name, r.achieved_on
+		FROM records r
+		JOIN participants p ON p.id = r.participant_id
+		JOIN competitions c ON c.id = r.competition_id
+		WHERE `+condition+`
+		ORDER BY r.scope, r.scope_key, r.discipline, r.event
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		var r Record
+		err := rows.Scan(&r.Scope, &r.Name, &r.Discipline, &r.Event, &r.Mark, &r.LowerIsBetter, &r.ParticipantID, &r.ParticipantName,
+			&r.CompetitionID, &r.CompetitionName, &r.AchievedOn)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
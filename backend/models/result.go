@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+type Result struct {
+	ID            int       `json:"id"`
+	CompetitionID int       `json:"competition_id"`
+	ParticipantID int       `json:"participant_id"`
+	Score         float64   `json:"score"`
+	Notes         string    `json:"notes"`
+	RecordedBy    string    `json:"recorded_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	MarkFlags
+}
+
+// Standing is a participant's position in a competition's results table
+type Standing struct {
+	Rank          int     `json:"rank"`
+	ParticipantID int     `json:"participant_id"`
+	Name          string  `json:"name"`
+	Score         float64 `json:"score"`
+}
+
+// RecordResult stores a participant's score in a competition, replacing any
+// earlier score, and emits a result event for live subscribers
+func RecordResult(meta ChangeMeta, r *Result) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Only registered participants can be scored
+	var registered bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM competition_participants cp
+			JOIN competitions c ON c.id = cp.competition_id
+			JOIN participants p ON p.id = cp.participant_id
+			WHERE cp.competition_id = $1 AND cp.participant_id = $2
+				AND cp.deleted_at IS NULL AND c.deleted_at IS NULL AND p.deleted_at IS NULL
+		)
+	`, r.CompetitionID, r.ParticipantID).Scan(&registered)
+	if err != nil {
+		return err
+	}
+	if !registered {
+		return errors.New("participant not registered for this competition")
+	}
+
+	var before interface{}
+	var previous Result
+	err = tx.QueryRow(`
+		SELECT id, competition_id, participant_id, score, notes, recorded_by, created_at, updated_at
+		FROM results
+		WHERE competition_id = $1 AND participant_id = $2
+		FOR UPDATE
+	`, r.CompetitionID, r.ParticipantID).Scan(&previous.ID, &previous.CompetitionID, &previous.ParticipantID,
+		&previous.Score, &previous.Notes, &previous.RecordedBy, &previous.CreatedAt, &previous.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	action := "create"
+	if err == nil {
+		before = previous
+		action = "update"
+	}
+
+	r.RecordedBy = meta.Actor
+	err = tx.QueryRow(`
+		INSERT INTO results (competition_id, participant_id, score, notes, recorded_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (competition_id, participant_id) DO UPDATE
+		SET score = EXCLUDED.score, notes = EXCLUDED.notes, recorded_by = EXCLUDED.recorded_by, updated_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at, updated_at
+	`, r.CompetitionID, r.ParticipantID, r.Score, r.Notes, r.RecordedBy).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := updateCompetitionMarks(tx, r.CompetitionID); err != nil {
+		return err
+	}
+	r.MarkFlags, err = getMarkFlags(tx, r.CompetitionID, nil, "", r.ParticipantID)
+	if err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "result", strconv.Itoa(r.ID), action, before, r); err != nil {
+		return err
+	}
+
+	if err := recordEvent(tx, EventResultRecorded, r.CompetitionID, r); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// GetStandings ranks the scored participants of a competition, highest score
+// first. Participants with equal scores share a rank.
+func GetStandings(competitionID int) ([]Standing, error) {
+	rows, err := DB.Query(`
+		SELECT RANK() OVER (ORDER BY r.score DESC), p.id, p.name, r.score
+		FROM results r
+		JOIN participants p ON p.id = r.participant_id
+		JOIN competition_participants cp ON cp.competition_id = r.competition_id AND cp.participant_id = r.participant_id
+		WHERE r.competition_id = $1 AND p.deleted_at IS NULL AND cp.deleted_at IS NULL
+		ORDER BY r.score DESC, p.name ASC
+	`, competitionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	standings := []Standing{}
+	for rows.Next() {
+		var s Standing
+		if err := rows.Scan(&s.Rank, &s.ParticipantID, &s.Name, &s.Score); err != nil {
+			return nil, err
+		}
+		standings = append(standings, s)
+	}
+
+	return standings, rows.Err()
+}
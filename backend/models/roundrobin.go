@@ -0,0 +1,511 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// StageRoundRobin is the stage of the matches of a round-robin league. The
+// participant in slot 1 plays at home.
+const StageRoundRobin = "round_robin"
+
+// roundRobinStageFilter selects the matches of a round-robin league
+const roundRobinStageFilter = "m.stage = 'round_robin'"
+
+// Tie-breakers for round-robin standings
+const (
+	TieBreakHeadToHead      = "head_to_head"
+	TieBreakScoreDifference = "score_difference"
+	TieBreakScoreFor        = "score_for"
+)
+
+// RoundRobin holds the settings of a competition's round-robin league
+type RoundRobin struct {
+	CompetitionID int       `json:"competition_id"`
+	Legs          int       `json:"legs"`
+	PointsWin     float64   `json:"points_win"`
+	PointsDraw    float64   `json:"points_draw"`
+	PointsLoss    float64   `json:"points_loss"`
+	TieBreakers   []string  `json:"tie_breakers"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// RoundRobinRound is one matchday of a round-robin league
+type RoundRobinRound struct {
+	Round   int      `json:"round"`
+	Matches []*Match `json:"matches"`
+}
+
+// RoundRobinSchedule is a round-robin league with its matches
+type RoundRobinSchedule struct {
+	RoundRobin
+	Rounds []RoundRobinRound `json:"rounds"`
+}
+
+// LeagueStanding is a participant's line in a round-robin standings table
+type LeagueStanding struct {
+	Rank            int     `json:"rank"`
+	ParticipantID   int     `json:"participant_id"`
+	Name            string  `json:"name"`
+	Played          int     `json:"played"`
+	Wins            int     `json:"wins"`
+	Draws           int     `json:"draws"`
+	Losses          int     `json:"losses"`
+	ScoreFor        float64 `json:"score_for"`
+	ScoreAgainst    float64 `json:"score_against"`
+	ScoreDifference float64 `json:"score_difference"`
+	Points          float64 `json:"points"`
+}
+
+// circleSchedule pairs every participant with every other using the circle
+// method: the first participant stays in place while the others rotate. With
+// an odd number one participant sits out each round. Pairs are home first;
+// a second leg repeats the first with home and away swapped.
+func circleSchedule(participants []int, legs int) [][][2]int {
+	circle := append([]int{}, participants...)
+	if len(circle)%2 == 1 {
+		circle = append(circle, 0)
+	}
+	n := len(circle)
+
+	var rounds [][][2]int
+	for r := 0; r < n-1; r++ {
+		var round [][2]int
+		for i := 0; i < n/2; i++ {
+			home, away := circle[i], circle[n-1-i]
+			// The fixed participant alternates between home and away
+			if i == 0 && r%2 == 1 {
+				home, away = away, home
+			}
+			if home != 0 && away != 0 {
+				round = append(round, [2]int{home, away})
+			}
+		}
+		rounds = append(rounds, round)
+
+		// Rotate everyone but the first participant one place
+		last := circle[n-1]
+		copy(circle[2:], circle[1:n-1])
+		circle[1] = last
+	}
+
+	if legs == 2 {
+		firstLeg := len(rounds)
+		for r := 0; r < firstLeg; r++ {
+			var round [][2]int
+			for _, pair := range rounds[r] {
+				round = append(round, [2]int{pair[1], pair[0]})
+			}
+			rounds = append(rounds, round)
+		}
+	}
+
+	return rounds
+}
+
+// leagueStandings builds the standings table from the played matches. Ties on
+// points are broken by the tie-breakers in order, then by name.
+func leagueStandings(settings RoundRobin, matches []*Match) []LeagueStanding {
+	lines := map[int]*LeagueStanding{}
+	line := func(id int, name string) *LeagueStanding {
+		l, ok := lines[id]
+		if !ok {
+			l = &LeagueStanding{ParticipantID: id, Name: name}
+			lines[id] = l
+		}
+		return l
+	}
+
+	var played []*Match
+	for _, m := range matches {
+		if m.Participant1ID == nil || m.Participant2ID == nil {
+			continue
+		}
+		home := line(*m.Participant1ID, m.Participant1Name)
+		away := line(*m.Participant2ID, m.Participant2Name)
+		if m.Status != MatchCompleted || m.Score1 == nil || m.Score2 == nil {
+			continue
+		}
+		played = append(played, m)
+		addLeagueResult(settings, home, away, *m.Score1, *m.Score2)
+	}
+
+	group := make([]*LeagueStanding, 0, len(lines))
+	for _, l := range lines {
+		group = append(group, l)
+	}
+	sort.Slice(group, func(i, j int) bool {
+		if group[i].Points != group[j].Points {
+			return group[i].Points > group[j].Points
+		}
+		return group[i].Name < group[j].Name
+	})
+
+	var standings []LeagueStanding
+	for _, tied := range splitLeagueGroup(group, func(l *LeagueStanding) float64 { return l.Points }) {
+		for _, ranked := range breakLeagueTie(settings, tied, settings.TieBreakers, played) {
+			rank := len(standings) + 1
+			for _, l := range ranked {
+				l.Rank = rank
+				standings = append(standings, *l)
+			}
+		}
+	}
+
+	if standings == nil {
+		standings = []LeagueStanding{}
+	}
+	return standings
+}
+
+// addLeagueResult adds the result of a match to the lines of both participants
+func addLeagueResult(settings RoundRobin, home, away *LeagueStanding, homeScore, awayScore float64) {
+	home.Played++
+	away.Played++
+	home.ScoreFor += homeScore
+	home.ScoreAgainst += awayScore
+	away.ScoreFor += awayScore
+	away.ScoreAgainst += homeScore
+	home.ScoreDifference = home.ScoreFor - home.ScoreAgainst
+	away.ScoreDifference = away.ScoreFor - away.ScoreAgainst
+
+	switch {
+	case homeScore > awayScore:
+		home.Wins++
+		away.Losses++
+		home.Points += settings.PointsWin
+		away.Points += settings.PointsLoss
+	case awayScore > homeScore:
+		away.Wins++
+		home.Losses++
+		away.Points += settings.PointsWin
+		home.Points += settings.PointsLoss
+	default:
+		home.Draws++
+		away.Draws++
+		home.Points += settings.PointsDraw
+		away.Points += settings.PointsDraw
+	}
+}
+
+// splitLeagueGroup splits a group into runs with equal values, keeping the
+// group's order, after sorting it by value from high to low
+func splitLeagueGroup(group []*LeagueStanding, value func(*LeagueStanding) float64) [][]*LeagueStanding {
+	sort.SliceStable(group, func(i, j int) bool {
+		return value(group[i]) > value(group[j])
+	})
+
+	var runs [][]*LeagueStanding
+	for i, l := range group {
+		if i > 0 && value(l) == value(group[i-1]) {
+			runs[len(runs)-1] = append(runs[len(runs)-1], l)
+		} else {
+			runs = append(runs, []*LeagueStanding{l})
+		}
+	}
+	return runs
+}
+
+// breakLeagueTie orders participants tied on points using the tie-breakers
+// in turn. The result lists groups that are still tied after all of them.
+func breakLeagueTie(settings RoundRobin, tied []*LeagueStanding, tieBreakers []string, played []*Match) [][]*LeagueStanding {
+	if len(tied) == 1 || len(tieBreakers) == 0 {
+		return [][]*LeagueStanding{tied}
+	}
+
+	var value func(*LeagueStanding) float64
+	switch tieBreakers[0] {
+	case TieBreakHeadToHead:
+		// A mini table of the matches among the tied participants only
+		inGroup := map[int]*LeagueStanding{}
+		for _, l := range tied {
+			inGroup[l.ParticipantID] = &LeagueStanding{ParticipantID: l.ParticipantID}
+		}
+		for _, m := range played {
+			home, homeOK := inGroup[*m.Participant1ID]
+			away, awayOK := inGroup[*m.Participant2ID]
+			if homeOK && awayOK {
+				addLeagueResult(settings, home, away, *m.Score1, *m.Score2)
+			}
+		}
+		value = func(l *LeagueStanding) float64 { return inGroup[l.ParticipantID].Points }
+	case TieBreakScoreDifference:
+		value = func(l *LeagueStanding) float64 { return l.ScoreDifference }
+	case TieBreakScoreFor:
+		value = func(l *LeagueStanding) float64 { return l.ScoreFor }
+	default:
+		return breakLeagueTie(settings, tied, tieBreakers[1:], played)
+	}
+
+	var result [][]*LeagueStanding
+	for _, run := range splitLeagueGroup(tied, value) {
+		result = append(result, breakLeagueTie(settings, run, tieBreakers[1:], played)...)
+	}
+	return result
+}
+
+// getRoundRobin loads the settings of a competition's round-robin league
+func getRoundRobin(q querier, competitionID int, lock bool) (RoundRobin, error) {
+	query := `
+		SELECT r.competition_id, r.legs, r.points_win, r.points_draw, r.points_loss, r.tie_breakers, r.created_at, r.updated_at
+		FROM round_robins r
+		JOIN competitions c ON c.id = r.competition_id
+		WHERE r.competition_id = $1 AND c.deleted_at IS NULL`
+	if lock {
+		query += " FOR UPDATE OF r"
+	}
+
+	var r RoundRobin
+	err := q.QueryRow(query, competitionID).Scan(&r.CompetitionID, &r.Legs, &r.PointsWin, &r.PointsDraw, &r.PointsLoss,
+		pq.Array(&r.TieBreakers), &r.CreatedAt, &r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return r, errors.New("round robin not found")
+	}
+	return r, err
+}
+
+// GenerateRoundRobin schedules a round-robin league among the participants
+// registered for a competition
+func GenerateRoundRobin(meta ChangeMeta, settings RoundRobin) (RoundRobinSchedule, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return RoundRobinSchedule{}, err
+	}
+	defer tx.Rollback()
+
+	competition, err := lockCompetition(tx, settings.CompetitionID)
+	if err == sql.ErrNoRows || (err == nil && competition.DeletedAt != nil) {
+		return RoundRobinSchedule{}, errors.New("competition does not exist")
+	}
+	if err != nil {
+		return RoundRobinSchedule{}, err
+	}
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM round_robins WHERE competition_id = $1)", settings.CompetitionID).Scan(&exists); err != nil {
+		return RoundRobinSchedule{}, err
+	}
+	if exists {
+		return RoundRobinSchedule{}, errors.New("round robin already exists")
+	}
+
+	participants, err := bracketEntrants(tx, settings.CompetitionID, nil)
+	if err != nil {
+		return RoundRobinSchedule{}, err
+	}
+	if len(participants) < 2 {
+		return RoundRobinSchedule{}, errors.New("a round robin needs at least two participants")
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO round_robins (competition_id, legs, points_win, points_draw, points_loss, tie_breakers)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at
+	`, settings.CompetitionID, settings.Legs, settings.PointsWin, settings.PointsDraw, settings.PointsLoss,
+		pq.Array(settings.TieBreakers)).Scan(&settings.CreatedAt, &settings.UpdatedAt)
+	if err != nil {
+		return RoundRobinSchedule{}, err
+	}
+
+	for r, round := range circleSchedule(participants, settings.Legs) {
+		for p, pair := range round {
+			home, away := pair[0], pair[1]
+			m := &Match{CompetitionID: settings.CompetitionID, Stage: StageRoundRobin, Round: r + 1, Position: p + 1,
+				Participant1ID: &home, Participant2ID: &away, Status: MatchReady}
+			if err := insertMatch(tx, m); err != nil {
+				return RoundRobinSchedule{}, err
+			}
+		}
+	}
+
+	if err := recordAudit(tx, meta, "round_robin", strconv.Itoa(settings.CompetitionID), "create", nil, settings); err != nil {
+		return RoundRobinSchedule{}, err
+	}
+
+	if err := tx.commit(); err != nil {
+		return RoundRobinSchedule{}, err
+	}
+
+	return GetRoundRobin(settings.CompetitionID)
+}
+
+// GetRoundRobin retrieves a competition's round-robin league with its
+// matches by round
+func GetRoundRobin(competitionID int) (RoundRobinSchedule, error) {
+	schedule := RoundRobinSchedule{Rounds: []RoundRobinRound{}}
+
+	settings, err := getRoundRobin(DB, competitionID, false)
+	if err != nil {
+		return schedule, err
+	}
+	schedule.RoundRobin = settings
+
+	matches, err := getMatches(DB, competitionID, roundRobinStageFilter, false)
+	if err != nil {
+		return schedule, err
+	}
+	for _, m := range matches {
+		if len(schedule.Rounds) == 0 || schedule.Rounds[len(schedule.Rounds)-1].Round != m.Round {
+			schedule.Rounds = append(schedule.Rounds, RoundRobinRound{Round: m.Round})
+		}
+		last := &schedule.Rounds[len(schedule.Rounds)-1]
+		last.Matches = append(last.Matches, m)
+	}
+
+	return schedule, nil
+}
+
+// UpdateRoundRobinSettings changes the points and tie-breakers of a
+// round-robin league; the standings follow immediately
+func UpdateRoundRobinSettings(meta ChangeMeta, settings *RoundRobin) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := getRoundRobin(tx, settings.CompetitionID, true)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(`
+		UPDATE round_robins
+		SET points_win = $2, points_draw = $3, points_loss = $4, tie_breakers = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE competition_id = $1
+		RETURNING legs, created_at, updated_at
+	`, settings.CompetitionID, settings.PointsWin, settings.PointsDraw, settings.PointsLoss,
+		pq.Array(settings.TieBreakers)).Scan(&settings.Legs, &settings.CreatedAt, &settings.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "round_robin", strconv.Itoa(settings.CompetitionID), "update", before, settings); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// DeleteRoundRobin discards a competition's round-robin league with all its matches
+func DeleteRoundRobin(meta ChangeMeta, competitionID int) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := getRoundRobin(tx, competitionID, true)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM round_robins WHERE competition_id = $1", competitionID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM matches m WHERE m.competition_id = $1 AND "+roundRobinStageFilter, competitionID); err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "round_robin", strconv.Itoa(competitionID), "delete", before, nil); err != nil {
+		return err
+	}
+
+	if err := updateCompetitionRatings(tx, competitionID); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// RecordRoundRobinResult stores or corrects the score of a round-robin match
+func RecordRoundRobinResult(meta ChangeMeta, competitionID, matchID int, score1, score2 float64) (Match, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return Match{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := getRoundRobin(tx, competitionID, false); err != nil {
+		if err.Error() == "round robin not found" {
+			return Match{}, errors.New("match not found")
+		}
+		return Match{}, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT `+matchColumns+`
+		FROM matches m
+		`+matchJoins+`
+		WHERE m.id = $1 AND m.competition_id = $2 AND `+roundRobinStageFilter+`
+		FOR UPDATE OF m
+	`, matchID, competitionID)
+	if err != nil {
+		return Match{}, err
+	}
+	var m *Match
+	if rows.Next() {
+		m, err = scanMatch(rows)
+	}
+	rows.Close()
+	if err != nil {
+		return Match{}, err
+	}
+	if m == nil {
+		return Match{}, errors.New("match not found")
+	}
+	before := *m
+
+	m.Score1, m.Score2 = &score1, &score2
+	m.WinnerID = nil
+	if score1 > score2 {
+		m.WinnerID = m.Participant1ID
+	} else if score2 > score1 {
+		m.WinnerID = m.Participant2ID
+	}
+	m.Status = MatchCompleted
+
+	if err := saveMatch(tx, m); err != nil {
+		return Match{}, err
+	}
+
+	action := "create"
+	if before.Status == MatchCompleted {
+		action = "update"
+	}
+	if err := recordAudit(tx, meta, "match", strconv.Itoa(m.ID), action, before, m); err != nil {
+		return Match{}, err
+	}
+
+	if err := recordEvent(tx, EventMatchCompleted, competitionID, m); err != nil {
+		return Match{}, err
+	}
+
+	if err := updateCompetitionRatings(tx, competitionID); err != nil {
+		return Match{}, err
+	}
+
+	return *m, tx.commit()
+}
+
+// GetRoundRobinStandings computes the standings table of a competition's
+// round-robin league from its current results
+func GetRoundRobinStandings(competitionID int) ([]LeagueStanding, error) {
+	settings, err := getRoundRobin(DB, competitionID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := getMatches(DB, competitionID, roundRobinStageFilter, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return leagueStandings(settings, matches), nil
+}
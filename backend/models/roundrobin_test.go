@@ -0,0 +1,180 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCircleSchedule(t *testing.T) {
+	tests := []struct {
+		name         string
+		participants int
+		legs         int
+		wantRounds   int
+	}{
+		{"two participants", 2, 1, 1},
+		{"three participants", 3, 1, 3},
+		{"four participants", 4, 1, 3},
+		{"five participants", 5, 1, 5},
+		{"six participants", 6, 1, 5},
+		{"four participants, two legs", 4, 2, 6},
+		{"seven participants, two legs", 7, 2, 14},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			participants := make([]int, tt.participants)
+			for i := range participants {
+				participants[i] = i + 1
+			}
+
+			rounds := circleSchedule(participants, tt.legs)
+			if len(rounds) != tt.wantRounds {
+				t.Fatalf("got %d rounds, want %d", len(rounds), tt.wantRounds)
+			}
+
+			perLeg := len(rounds) / tt.legs
+			for leg := 0; leg < tt.legs; leg++ {
+				met := map[[2]int]int{}
+				for r, round := range rounds[leg*perLeg : (leg+1)*perLeg] {
+					playing := map[int]bool{}
+					for _, pair := range round {
+						for _, id := range pair {
+							if playing[id] {
+								t.Fatalf("leg %d round %d: participant %d plays twice", leg+1, r+1, id)
+							}
+							playing[id] = true
+						}
+						key := pair
+						if key[0] > key[1] {
+							key[0], key[1] = key[1], key[0]
+						}
+						met[key]++
+					}
+				}
+
+				for i := 1; i <= tt.participants; i++ {
+					for j := i + 1; j <= tt.participants; j++ {
+						if n := met[[2]int{i, j}]; n != 1 {
+							t.Errorf("leg %d: %d and %d meet %d times", leg+1, i, j, n)
+						}
+					}
+				}
+			}
+
+			// The second leg swaps home and away
+			if tt.legs == 2 {
+				for r := 0; r < perLeg; r++ {
+					for i, pair := range rounds[r] {
+						if swapped := rounds[perLeg+r][i]; swapped != [2]int{pair[1], pair[0]} {
+							t.Errorf("round %d: got %v in the second leg for %v", r+1, swapped, pair)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// leagueMatch builds a round-robin match between two named participants,
+// completed when both scores are given
+func leagueMatch(home, away int, scores ...float64) *Match {
+	names := map[int]string{1: "A", 2: "B", 3: "C", 4: "D"}
+	m := &Match{Stage: StageRoundRobin, Participant1ID: &home, Participant1Name: names[home], Participant2ID: &away, Participant2Name: names[away], Status: MatchReady}
+	if len(scores) == 2 {
+		m.Score1, m.Score2 = &scores[0], &scores[1]
+		m.Status = MatchCompleted
+	}
+	return m
+}
+
+func TestLeagueStandings(t *testing.T) {
+	// C wins both its games; A and B tie on points, A winning their game
+	// while B has the better score difference
+	tied := []*Match{
+		leagueMatch(1, 2, 1, 0),
+		leagueMatch(3, 1, 1, 0),
+		leagueMatch(2, 4, 9, 0),
+		leagueMatch(3, 4, 1, 0),
+		leagueMatch(4, 1),
+	}
+
+	tests := []struct {
+		name        string
+		tieBreakers []string
+		matches     []*Match
+		wantOrder   []string
+		wantRanks   []int
+	}{
+		{
+			name:        "head to head first",
+			tieBreakers: []string{TieBreakHeadToHead, TieBreakScoreDifference},
+			matches:     tied,
+			wantOrder:   []string{"C", "A", "B", "D"},
+			wantRanks:   []int{1, 2, 3, 4},
+		},
+		{
+			name:        "score difference first",
+			tieBreakers: []string{TieBreakScoreDifference, TieBreakHeadToHead},
+			matches:     tied,
+			wantOrder:   []string{"C", "B", "A", "D"},
+			wantRanks:   []int{1, 2, 3, 4},
+		},
+		{
+			name:        "no tie-breakers share the rank",
+			tieBreakers: nil,
+			matches:     tied,
+			wantOrder:   []string{"C", "A", "B", "D"},
+			wantRanks:   []int{1, 2, 2, 4},
+		},
+		{
+			name:        "draws",
+			tieBreakers: []string{TieBreakScoreFor},
+			matches:     []*Match{leagueMatch(1, 2, 2, 2), leagueMatch(3, 1, 0, 1), leagueMatch(2, 3, 0, 0)},
+			wantOrder:   []string{"A", "B", "C"},
+			wantRanks:   []int{1, 2, 3},
+		},
+		{
+			name:        "nothing played",
+			tieBreakers: []string{TieBreakHeadToHead},
+			matches:     []*Match{leagueMatch(2, 1)},
+			wantOrder:   []string{"A", "B"},
+			wantRanks:   []int{1, 1},
+		},
+	}
+
+	settings := RoundRobin{PointsWin: 3, PointsDraw: 1, PointsLoss: 0}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings.TieBreakers = tt.tieBreakers
+			standings := leagueStandings(settings, tt.matches)
+
+			var order []string
+			var ranks []int
+			for _, s := range standings {
+				order = append(order, s.Name)
+				ranks = append(ranks, s.Rank)
+			}
+			if !reflect.DeepEqual(order, tt.wantOrder) || !reflect.DeepEqual(ranks, tt.wantRanks) {
+				t.Errorf("got %v ranked %v, want %v ranked %v", order, ranks, tt.wantOrder, tt.wantRanks)
+			}
+		})
+	}
+}
+
+func TestLeagueStandingsTotals(t *testing.T) {
+	settings := RoundRobin{PointsWin: 2, PointsDraw: 1, PointsLoss: 0}
+	standings := leagueStandings(settings, []*Match{
+		leagueMatch(1, 2, 3, 1),
+		leagueMatch(2, 1, 2, 2),
+		leagueMatch(1, 2),
+	})
+
+	want := []LeagueStanding{
+		{Rank: 1, ParticipantID: 1, Name: "A", Played: 2, Wins: 1, Draws: 1, ScoreFor: 5, ScoreAgainst: 3, ScoreDifference: 2, Points: 3},
+		{Rank: 2, ParticipantID: 2, Name: "B", Played: 2, Draws: 1, Losses: 1, ScoreFor: 3, ScoreAgainst: 5, ScoreDifference: -2, Points: 1},
+	}
+	if !reflect.DeepEqual(standings, want) {
+		t.Errorf("got %+v, want %+v", standings, want)
+	}
+}
@@ -0,0 +1,331 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Seeding sources, applied in the order a seeding lists them
+const (
+	SeedManual         = "manual"
+	SeedRating         = "rating"
+	SeedEntryTime      = "entry_time"
+	SeedPreviousResult = "previous_result"
+)
+
+// Orders of previous results
+const (
+	HighestFirst = "highest_first"
+	LowestFirst  = "lowest_first"
+)
+
+// Seeding holds how a competition's participants are seeded, with the
+// participants in seed order
+type Seeding struct {
+	CompetitionID         int                 `json:"competition_id"`
+	Sources               []string            `json:"sources"`
+	Manual                []int               `json:"manual"`
+	EventID               *int                `json:"event_id"`
+	PreviousCompetitionID *int                `json:"previous_competition_id"`
+	PreviousResultOrder   string              `json:"previous_result_order"`
+	Locked                bool                `json:"locked"`
+	ComputedAt            time.Time           `json:"computed_at"`
+	UpdatedAt             time.Time           `json:"updated_at"`
+	Participants          []SeededParticipant `json:"participants"`
+}
+
+// SeededParticipant is a participant's place in the seeding, with the values
+// of the seeding sources it was based on. Participants registered after the
+// seeding was computed have no seed yet.
+type SeededParticipant struct {
+	Seed           *int     `json:"seed"`
+	ParticipantID  int      `json:"participant_id"`
+	Name           string   `json:"name"`
+	Club           string   `json:"club"`
+	ManualPosition *int     `json:"manual_position,omitempty"`
+	Rating         *float64 `json:"rating,omitempty"`
+	EntryTime      *float64 `json:"entry_time,omitempty"`
+	PreviousResult *float64 `json:"previous_result,omitempty"`
+
+	registrationDate time.Time
+}
+
+// seedValue returns a participant's value for a seeding source, lower values
+// seeding higher, and false if the participant has none
+func (p *SeededParticipant) seedValue(s *Seeding, source string) (float64, bool) {
+	switch source {
+	case SeedManual:
+		if p.ManualPosition != nil {
+			return float64(*p.ManualPosition), true
+		}
+	case SeedRating:
+		if p.Rating != nil {
+			return -*p.Rating, true
+		}
+	case SeedEntryTime:
+		if p.EntryTime != nil {
+			return *p.EntryTime, true
+		}
+	case SeedPreviousResult:
+		if p.PreviousResult != nil {
+			if s.PreviousResultOrder == LowestFirst {
+				return *p.PreviousResult, true
+			}
+			return -*p.PreviousResult, true
+		}
+	}
+	return 0, false
+}
+
+// orderSeeding sorts participants by the seeding sources in turn. Participants
+// without a value for a source come after those with one and are ordered by
+// the next source; registration date decides what the sources leave open.
+func orderSeeding(s *Seeding, participants []SeededParticipant) {
+	sort.SliceStable(participants, func(i, j int) bool {
+		a, b := &participants[i], &participants[j]
+		for _, source := range s.Sources {
+			va, okA := a.seedValue(s, source)
+			vb, okB := b.seedValue(s, source)
+			if okA != okB {
+				return okA
+			}
+			if okA && va != vb {
+				return va < vb
+			}
+		}
+		if !a.registrationDate.Equal(b.registrationDate) {
+			return a.registrationDate.Before(b.registrationDate)
+		}
+		return a.ParticipantID < b.ParticipantID
+	})
+}
+
+// getSeedingSettings loads how a competition is seeded. With lock set the
+// row is locked for the rest of the transaction.
+func getSeedingSettings(q querier, competitionID int, lock bool) (Seeding, error) {
+	query := `
+		SELECT competition_id, sources, manual, event_id, previous_competition_id, previous_result_order, locked, computed_at, updated_at
+		FROM seedings
+		WHERE competition_id = $1`
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	var s Seeding
+	var manual []int64
+	err := q.QueryRow(query, competitionID).Scan(&s.CompetitionID, pq.Array(&s.Sources), pq.Array(&manual), &s.EventID,
+		&s.PreviousCompetitionID, &s.PreviousResultOrder, &s.Locked, &s.ComputedAt, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return s, errors.New("seeding not found")
+	}
+	s.Manual = make([]int, len(manual))
+	for i, id := range manual {
+		s.Manual[i] = int(id)
+	}
+	return s, err
+}
+
+// seedingParticipants loads the active registrations of a competition with
+// their values for every seeding source, in stored seed order
+func seedingParticipants(q querier, s *Seeding) ([]SeededParticipant, error) {
+	rows, err := q.Query(`
+		SELECT cp.seed, cp.participant_id, p.name, p.club, cp.registration_date,
+			r.rating::float8, ep.entry_time::float8, res.score::float8
+		FROM competition_participants cp
+		JOIN participants p ON p.id = cp.participant_id
+		JOIN competitions c ON c.id = cp.competition_id
+		LEFT JOIN ratings r ON r.participant_id = cp.participant_id AND r.discipline = c.discipline
+		LEFT JOIN event_participants ep ON ep.event_id = $2 AND ep.participant_id = cp.participant_id
+		LEFT JOIN results res ON res.competition_id = $3 AND res.participant_id = cp.participant_id
+		WHERE cp.competition_id = $1 AND cp.deleted_at IS NULL AND p.deleted_at IS NULL
+		ORDER BY cp.seed ASC NULLS LAST, cp.registration_date ASC, cp.participant_id ASC
+	`, s.CompetitionID, s.EventID, s.PreviousCompetitionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	positions := map[int]int{}
+	for i, id := range s.Manual {
+		positions[id] = i + 1
+	}
+
+	participants := []SeededParticipant{}
+	for rows.Next() {
+		var p SeededParticipant
+		err := rows.Scan(&p.Seed, &p.ParticipantID, &p.Name, &p.Club, &p.registrationDate, &p.Rating, &p.EntryTime, &p.PreviousResult)
+		if err != nil {
+			return nil, err
+		}
+		if position, ok := positions[p.ParticipantID]; ok {
+			p.ManualPosition = &position
+		}
+		participants = append(participants, p)
+	}
+
+	return participants, rows.Err()
+}
+
+// GetSeeding retrieves the seeding of a competition with its participants in
+// seed order
+func GetSeeding(competitionID int) (Seeding, error) {
+	if !CompetitionExists(competitionID) {
+		return Seeding{}, errors.New("seeding not found")
+	}
+
+	s, err := getSeedingSettings(DB, competitionID, false)
+	if err != nil {
+		return s, err
+	}
+
+	s.Participants, err = seedingParticipants(DB, &s)
+	return s, err
+}
+
+// ComputeSeeding (re)computes the seeds of a competition's participants from
+// the given sources and stores them on the registrations. A locked seeding
+// is kept as it is; lock set locks the new one.
+func ComputeSeeding(meta ChangeMeta, s Seeding, lock bool) (Seeding, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return Seeding{}, err
+	}
+	defer tx.Rollback()
+
+	competition, err := lockCompetition(tx, s.CompetitionID)
+	if err == sql.ErrNoRows || (err == nil && competition.DeletedAt != nil) {
+		return Seeding{}, errors.New("competition does not exist")
+	}
+	if err != nil {
+		return Seeding{}, err
+	}
+
+	before, err := getSeedingSettings(tx, s.CompetitionID, true)
+	exists := err == nil
+	if err != nil && err.Error() != "seeding not found" {
+		return Seeding{}, err
+	}
+	if exists && before.Locked {
+		return Seeding{}, errors.New("seeding is locked")
+	}
+
+	if s.EventID != nil {
+		if _, err := lockEvent(tx, s.CompetitionID, *s.EventID); err != nil {
+			return Seeding{}, err
+		}
+	}
+	if s.PreviousCompetitionID != nil && (*s.PreviousCompetitionID == s.CompetitionID || !competitionExists(tx, *s.PreviousCompetitionID)) {
+		return Seeding{}, errors.New("previous competition not found")
+	}
+
+	// Lock the registrations so the seeds match the roster they were computed from
+	if _, err := tx.Exec("SELECT 1 FROM competition_participants WHERE competition_id = $1 FOR UPDATE", s.CompetitionID); err != nil {
+		return Seeding{}, err
+	}
+
+	participants, err := seedingParticipants(tx, &s)
+	if err != nil {
+		return Seeding{}, err
+	}
+
+	registered := map[int]bool{}
+	for _, p := range participants {
+		registered[p.ParticipantID] = true
+	}
+	for _, id := range s.Manual {
+		if !registered[id] {
+			return Seeding{}, errors.New("seeded participant is not registered for this competition")
+		}
+	}
+
+	orderSeeding(&s, participants)
+
+	if _, err := tx.Exec("UPDATE competition_participants SET seed = NULL WHERE competition_id = $1 AND seed IS NOT NULL", s.CompetitionID); err != nil {
+		return Seeding{}, err
+	}
+	for i := range participants {
+		seed := i + 1
+		participants[i].Seed = &seed
+		_, err := tx.Exec(`
+			UPDATE competition_participants SET seed = $3, updated_at = CURRENT_TIMESTAMP
+			WHERE competition_id = $1 AND participant_id = $2
+		`, s.CompetitionID, participants[i].ParticipantID, seed)
+		if err != nil {
+			return Seeding{}, err
+		}
+	}
+
+	s.Locked = lock
+	err = tx.QueryRow(`
+		INSERT INTO seedings (competition_id, sources, manual, event_id, previous_competition_id, previous_result_order, locked)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (competition_id) DO UPDATE
+		SET sources = EXCLUDED.sources, manual = EXCLUDED.manual, event_id = EXCLUDED.event_id,
+			previous_competition_id = EXCLUDED.previous_competition_id, previous_result_order = EXCLUDED.previous_result_order,
+			locked = EXCLUDED.locked, computed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		RETURNING computed_at, updated_at
+	`, s.CompetitionID, pq.Array(s.Sources), pq.Array(s.Manual), s.EventID, s.PreviousCompetitionID, s.PreviousResultOrder,
+		s.Locked).Scan(&s.ComputedAt, &s.UpdatedAt)
+	if err != nil {
+		return Seeding{}, err
+	}
+	s.Participants = participants
+
+	var auditBefore interface{}
+	action := "create"
+	if exists {
+		auditBefore = before
+		action = "update"
+	}
+	if err := recordAudit(tx, meta, "seeding", strconv.Itoa(s.CompetitionID), action, auditBefore, s); err != nil {
+		return Seeding{}, err
+	}
+
+	return s, tx.commit()
+}
+
+// SetSeedingLocked locks or unlocks the seeding of a competition. A locked
+// seeding cannot be recomputed.
+func SetSeedingLocked(meta ChangeMeta, competitionID int, locked bool) (Seeding, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return Seeding{}, err
+	}
+	defer tx.Rollback()
+
+	if !competitionExists(tx, competitionID) {
+		return Seeding{}, errors.New("seeding not found")
+	}
+
+	before, err := getSeedingSettings(tx, competitionID, true)
+	if err != nil {
+		return Seeding{}, err
+	}
+
+	s := before
+	s.Locked = locked
+	err = tx.QueryRow(`
+		UPDATE seedings SET locked = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE competition_id = $1
+		RETURNING updated_at
+	`, competitionID, locked).Scan(&s.UpdatedAt)
+	if err != nil {
+		return Seeding{}, err
+	}
+
+	if err := recordAudit(tx, meta, "seeding", strconv.Itoa(competitionID), "update", before, s); err != nil {
+		return Seeding{}, err
+	}
+
+	s.Participants, err = seedingParticipants(tx, &s)
+	if err != nil {
+		return Seeding{}, err
+	}
+
+	return s, tx.commit()
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Signup is a self-registration waiting for its email address to be confirmed
+type Signup struct {
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	Locale        string `json:"locale"`
+	CompetitionID int    `json:"competition_id"`
+}
+
+// useToken marks a one-time token as used. The nonce is remembered until the
+// token would have expired anyway.
+func useToken(q querier, nonce string, expiresAt time.Time) error {
+	result, err := q.Exec(`
+		INSERT INTO used_tokens (nonce, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (nonce) DO NOTHING
+	`, nonce, expiresAt.UTC())
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("token already used")
+	}
+
+	return nil
+}
+
+// UseToken marks a one-time token as used
+func UseToken(nonce string, expiresAt time.Time) error {
+	return useToken(DB, nonce, expiresAt)
+}
+
+// PurgeUsedTokens forgets used tokens that have expired and can no longer be replayed
+func PurgeUsedTokens() (int64, error) {
+	result, err := DB.Exec("DELETE FROM used_tokens WHERE expires_at < CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetParticipantByEmail retrieves the active participant with the given email
+func GetParticipantByEmail(email string) (Participant, error) {
+	var p Participant
+	err := DB.QueryRow(`
+		SELECT id, name, email, locale, date_of_birth, gender, club, nationality, federation, license_number, version, created_at, updated_at
+		FROM participants
+		WHERE LOWER(email) = $1 AND deleted_at IS NULL
+	`, NormalizeEmail(email)).Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return p, errors.New("participant not found")
+	}
+
+	return p, err
+}
+
+// ConfirmSignup completes a self-registration once its email address has been
+// confirmed: the participant is created, or found if the address is already in
+// use, and registered for the chosen competition. The confirmation token is
+// used up in the same transaction.
+func ConfirmSignup(meta ChangeMeta, nonce string, expiresAt time.Time, s Signup) (Participant, error) {
+	var p Participant
+
+	tx, err := beginChange()
+	if err != nil {
+		return p, err
+	}
+	defer tx.Rollback()
+
+	if err := useToken(tx, nonce, expiresAt); err != nil {
+		return p, err
+	}
+
+	// Confirming the address proves ownership of an existing participant too
+	err = tx.QueryRow(`
+		SELECT id, name, email, locale, date_of_birth, gender, club, nationality, federation, license_number, version, created_at, updated_at
+		FROM participants
+		WHERE LOWER(email) = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, NormalizeEmail(s.Email)).Scan(&p.ID, &p.Name, &p.Email, &p.Locale, &p.DateOfBirth, &p.Gender, &p.Club, &p.Nationality, &p.Federation, &p.LicenseNumber, &p.Version, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		p = Participant{Name: s.Name, Email: s.Email, Locale: s.Locale}
+		err = createParticipant(tx, meta, &p)
+	}
+	if err != nil {
+		return p, err
+	}
+
+	err = addParticipantToCompetition(tx, meta, p.ID, s.CompetitionID, time.Now(), "")
+	if err != nil && err.Error() != "participant already registered for this competition" {
+		return p, err
+	}
+
+	return p, tx.commit()
+}
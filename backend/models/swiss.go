@@ -0,0 +1,517 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// StageSwiss is the stage of the games of a Swiss-system tournament. The
+// player in slot 1 has the white pieces.
+const StageSwiss = "swiss"
+
+// swissStageFilter selects the games of a Swiss-system tournament
+const swissStageFilter = "m.stage = 'swiss'"
+
+// swissPairingBudget bounds the search for a pairing without repeat opponents
+const swissPairingBudget = 1000000
+
+// SwissRound is one round of a Swiss-system tournament
+type SwissRound struct {
+	Round int      `json:"round"`
+	Games []*Match `json:"games"`
+}
+
+// SwissStanding is a player's position in a Swiss-system tournament
+type SwissStanding struct {
+	Rank             int     `json:"rank"`
+	ParticipantID    int     `json:"participant_id"`
+	Name             string  `json:"name"`
+	Points           float64 `json:"points"`
+	Buchholz         float64 `json:"buchholz"`
+	SonnebornBerger  float64 `json:"sonneborn_berger"`
+	Games            int     `json:"games"`
+	Wins             int     `json:"wins"`
+	Draws            int     `json:"draws"`
+	Losses           int     `json:"losses"`
+	ColorBalance     int     `json:"color_balance"`
+	HadBye           bool    `json:"had_bye"`
+	opponents        map[int]bool
+	lastColor        int
+	initialRank      int
+	defeatedOpponent []int
+	drawnOpponent    []int
+}
+
+// swissRecords sums up the games played so far for each player. Players
+// appear in the order of players, followed by anyone who only appears in games.
+func swissRecords(players []int, games []*Match) map[int]*SwissStanding {
+	records := map[int]*SwissStanding{}
+	record := func(id int) *SwissStanding {
+		r, ok := records[id]
+		if !ok {
+			r = &SwissStanding{ParticipantID: id, opponents: map[int]bool{}, initialRank: len(players) + len(records)}
+			records[id] = r
+		}
+		return r
+	}
+	for i, id := range players {
+		record(id).initialRank = i
+	}
+
+	for _, g := range games {
+		if g.Participant1ID == nil {
+			continue
+		}
+		white := record(*g.Participant1ID)
+
+		if g.Participant2ID == nil {
+			white.HadBye = true
+			if g.Status == MatchBye {
+				white.Points++
+			}
+			continue
+		}
+		black := record(*g.Participant2ID)
+		white.opponents[black.ParticipantID] = true
+		black.opponents[white.ParticipantID] = true
+		white.ColorBalance++
+		black.ColorBalance--
+		white.lastColor, black.lastColor = 1, -1
+
+		if g.Status != MatchCompleted || g.Score1 == nil || g.Score2 == nil {
+			continue
+		}
+		white.Games++
+		black.Games++
+		white.Points += *g.Score1
+		black.Points += *g.Score2
+		switch {
+		case *g.Score1 > *g.Score2:
+			white.Wins++
+			black.Losses++
+			white.defeatedOpponent = append(white.defeatedOpponent, black.ParticipantID)
+		case *g.Score2 > *g.Score1:
+			black.Wins++
+			white.Losses++
+			black.defeatedOpponent = append(black.defeatedOpponent, white.ParticipantID)
+		default:
+			white.Draws++
+			black.Draws++
+			white.drawnOpponent = append(white.drawnOpponent, black.ParticipantID)
+			black.drawnOpponent = append(black.drawnOpponent, white.ParticipantID)
+		}
+	}
+
+	return records
+}
+
+// swissStandings ranks the players by points, then Buchholz (the sum of their
+// opponents' points) and Sonneborn-Berger (the points of the opponents they
+// beat plus half of those they drew with)
+func swissStandings(players []int, games []*Match) []SwissStanding {
+	records := swissRecords(players, games)
+
+	standings := make([]SwissStanding, 0, len(records))
+	for _, r := range records {
+		for opponent := range r.opponents {
+			r.Buchholz += records[opponent].Points
+		}
+		for _, opponent := range r.defeatedOpponent {
+			r.SonnebornBerger += records[opponent].Points
+		}
+		for _, opponent := range r.drawnOpponent {
+			r.SonnebornBerger += records[opponent].Points / 2
+		}
+		standings = append(standings, *r)
+	}
+
+	sort.Slice(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Buchholz != b.Buchholz {
+			return a.Buchholz > b.Buchholz
+		}
+		if a.SonnebornBerger != b.SonnebornBerger {
+			return a.SonnebornBerger > b.SonnebornBerger
+		}
+		return a.initialRank < b.initialRank
+	})
+
+	for i := range standings {
+		s := &standings[i]
+		s.Rank = i + 1
+		if i > 0 {
+			prev := standings[i-1]
+			if prev.Points == s.Points && prev.Buchholz == s.Buchholz && prev.SonnebornBerger == s.SonnebornBerger {
+				s.Rank = prev.Rank
+			}
+		}
+	}
+
+	return standings
+}
+
+// pairSwissRound pairs the players for the next round. Players are sorted by
+// points and paired within their score group, top half against bottom half,
+// dropping to lower groups where needed so that nobody meets the same
+// opponent twice. With an odd number of players the lowest ranked player who
+// has not had a bye yet gets one. The returned pairs are white first; a pair
+// with a zero second player is the bye.
+func pairSwissRound(players []int, games []*Match) ([][2]int, error) {
+	records := swissRecords(players, games)
+
+	ranked := make([]*SwissStanding, len(players))
+	for i, id := range players {
+		ranked[i] = records[id]
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Points != ranked[j].Points {
+			return ranked[i].Points > ranked[j].Points
+		}
+		return ranked[i].initialRank < ranked[j].initialRank
+	})
+
+	budget := swissPairingBudget
+	var pairs [][2]*SwissStanding
+	found := false
+
+	if len(ranked)%2 == 0 {
+		pairs, found = pairSwissGroup(ranked, nil, &budget)
+	} else {
+		// Try byes from the bottom up, preferring players who have not had one
+		for _, allowRepeatBye := range []bool{false, true} {
+			for i := len(ranked) - 1; i >= 0 && !found; i-- {
+				if ranked[i].HadBye && !allowRepeatBye {
+					continue
+				}
+				rest := append(append([]*SwissStanding{}, ranked[:i]...), ranked[i+1:]...)
+				pairs, found = pairSwissGroup(rest, nil, &budget)
+				if found {
+					pairs = append(pairs, [2]*SwissStanding{ranked[i], nil})
+				}
+			}
+			if found {
+				break
+			}
+		}
+	}
+
+	if !found {
+		return nil, errors.New("no pairing possible without repeat opponents")
+	}
+
+	result := make([][2]int, len(pairs))
+	for i, pair := range pairs {
+		a, b := pair[0], pair[1]
+		if b == nil {
+			result[i] = [2]int{a.ParticipantID, 0}
+			continue
+		}
+		if swissGetsWhite(b, a, i) {
+			a, b = b, a
+		}
+		result[i] = [2]int{a.ParticipantID, b.ParticipantID}
+	}
+
+	return result, nil
+}
+
+// pairSwissGroup pairs the remaining players by backtracking. The first
+// player is paired with the middle of their own score group first, then with
+// the rest of the group, then with lower groups.
+func pairSwissGroup(remaining []*SwissStanding, pairs [][2]*SwissStanding, budget *int) ([][2]*SwissStanding, bool) {
+	if len(remaining) == 0 {
+		return pairs, true
+	}
+	if *budget <= 0 {
+		return nil, false
+	}
+	*budget--
+
+	top := remaining[0]
+	others := remaining[1:]
+
+	sameGroup := 0
+	for sameGroup < len(others) && others[sameGroup].Points == top.Points {
+		sameGroup++
+	}
+
+	order := make([]int, 0, len(others))
+	middle := (sameGroup+1)/2 - 1
+	if middle < 0 {
+		middle = 0
+	}
+	for i := middle; i < sameGroup; i++ {
+		order = append(order, i)
+	}
+	for i := middle - 1; i >= 0; i-- {
+		order = append(order, i)
+	}
+	for i := sameGroup; i < len(others); i++ {
+		order = append(order, i)
+	}
+
+	for _, i := range order {
+		opponent := others[i]
+		if top.opponents[opponent.ParticipantID] {
+			continue
+		}
+		rest := append(append([]*SwissStanding{}, others[:i]...), others[i+1:]...)
+		if result, ok := pairSwissGroup(rest, append(pairs, [2]*SwissStanding{top, opponent}), budget); ok {
+			return result, true
+		}
+	}
+
+	return nil, false
+}
+
+// swissGetsWhite decides whether b rather than the higher ranked a gets the
+// white pieces on the given board: the player who has had white less often
+// gets it, then the one who had black last. Otherwise the colors alternate
+// from board to board.
+func swissGetsWhite(b, a *SwissStanding, board int) bool {
+	if a.ColorBalance != b.ColorBalance {
+		return b.ColorBalance < a.ColorBalance
+	}
+	if a.lastColor != b.lastColor {
+		return b.lastColor < a.lastColor
+	}
+	return board%2 == 1
+}
+
+// swissPlayers loads the participants taking part in a competition's next
+// round, by stored seed first and then in order of registration
+func swissPlayers(q querier, competitionID int) ([]int, error) {
+	return bracketEntrants(q, competitionID, nil)
+}
+
+// GetSwissRounds retrieves the rounds of a competition's Swiss-system
+// tournament with their games, board by board
+func GetSwissRounds(competitionID int) ([]SwissRound, error) {
+	games, err := getMatches(DB, competitionID, swissStageFilter, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rounds := []SwissRound{}
+	for _, g := range games {
+		if len(rounds) == 0 || rounds[len(rounds)-1].Round != g.Round {
+			rounds = append(rounds, SwissRound{Round: g.Round})
+		}
+		last := &rounds[len(rounds)-1]
+		last.Games = append(last.Games, g)
+	}
+
+	return rounds, nil
+}
+
+// GenerateSwissRound pairs the next round of a competition's Swiss-system
+// tournament once every game of the current round has a result
+func GenerateSwissRound(meta ChangeMeta, competitionID int) (SwissRound, error) {
+	round := SwissRound{}
+
+	tx, err := beginChange()
+	if err != nil {
+		return round, err
+	}
+	defer tx.Rollback()
+
+	// Locking the competition keeps two rounds from being generated at once
+	competition, err := lockCompetition(tx, competitionID)
+	if err == sql.ErrNoRows || (err == nil && competition.DeletedAt != nil) {
+		return round, errors.New("competition does not exist")
+	}
+	if err != nil {
+		return round, err
+	}
+
+	games, err := getMatches(tx, competitionID, swissStageFilter, false)
+	if err != nil {
+		return round, err
+	}
+	for _, g := range games {
+		if !g.done() {
+			return round, errors.New("current round is not finished")
+		}
+		if g.Round > round.Round {
+			round.Round = g.Round
+		}
+	}
+	round.Round++
+
+	players, err := swissPlayers(tx, competitionID)
+	if err != nil {
+		return round, err
+	}
+	if len(players) < 2 {
+		return round, errors.New("a round needs at least two participants")
+	}
+
+	pairs, err := pairSwissRound(players, games)
+	if err != nil {
+		return round, err
+	}
+
+	for board, pair := range pairs {
+		white := pair[0]
+		g := &Match{CompetitionID: competitionID, Stage: StageSwiss, Round: round.Round, Position: board + 1, Participant1ID: &white, Status: MatchReady}
+		if pair[1] == 0 {
+			point, none := 1.0, 0.0
+			g.Status, g.WinnerID, g.Score1, g.Score2 = MatchBye, &white, &point, &none
+		} else {
+			black := pair[1]
+			g.Participant2ID = &black
+		}
+		if err := insertMatch(tx, g); err != nil {
+			return round, err
+		}
+		round.Games = append(round.Games, g)
+	}
+
+	audit := map[string]interface{}{"round": round.Round, "pairings": pairs}
+	if err := recordAudit(tx, meta, "swiss_round", strconv.Itoa(competitionID)+":"+strconv.Itoa(round.Round), "create", nil, audit); err != nil {
+		return round, err
+	}
+
+	if err := tx.commit(); err != nil {
+		return round, err
+	}
+
+	// Reload the round for the player names
+	rounds, err := GetSwissRounds(competitionID)
+	if err != nil || len(rounds) == 0 {
+		return round, err
+	}
+	return rounds[len(rounds)-1], nil
+}
+
+// RecordSwissResult stores the result of a Swiss-system game as the points of
+// white and black: 1 and 0, 0 and 1, or half a point each for a draw. Results
+// can be corrected at any time; later pairings are not redone.
+func RecordSwissResult(meta ChangeMeta, competitionID, matchID int, white, black float64) (Match, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return Match{}, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT `+matchColumns+`
+		FROM matches m
+		`+matchJoins+`
+		JOIN competitions c ON c.id = m.competition_id
+		WHERE m.id = $1 AND m.competition_id = $2 AND `+swissStageFilter+` AND c.deleted_at IS NULL
+		FOR UPDATE OF m
+	`, matchID, competitionID)
+	if err != nil {
+		return Match{}, err
+	}
+	var m *Match
+	if rows.Next() {
+		m, err = scanMatch(rows)
+	}
+	rows.Close()
+	if err != nil {
+		return Match{}, err
+	}
+	if m == nil {
+		return Match{}, errors.New("game not found")
+	}
+	if m.Status == MatchBye {
+		return Match{}, errors.New("a bye has no result to record")
+	}
+	before := *m
+
+	m.Score1, m.Score2 = &white, &black
+	m.WinnerID = nil
+	if white > black {
+		m.WinnerID = m.Participant1ID
+	} else if black > white {
+		m.WinnerID = m.Participant2ID
+	}
+	m.Status = MatchCompleted
+
+	if err := saveMatch(tx, m); err != nil {
+		return Match{}, err
+	}
+
+	action := "create"
+	if before.Status == MatchCompleted {
+		action = "update"
+	}
+	if err := recordAudit(tx, meta, "match", strconv.Itoa(m.ID), action, before, m); err != nil {
+		return Match{}, err
+	}
+
+	if err := recordEvent(tx, EventMatchCompleted, competitionID, m); err != nil {
+		return Match{}, err
+	}
+
+	if err := updateCompetitionRatings(tx, competitionID); err != nil {
+		return Match{}, err
+	}
+
+	return *m, tx.commit()
+}
+
+// GetSwissStandings ranks the players of a competition's Swiss-system
+// tournament with their tie-breaks
+func GetSwissStandings(competitionID int) ([]SwissStanding, error) {
+	games, err := getMatches(DB, competitionID, swissStageFilter, false)
+	if err != nil {
+		return nil, err
+	}
+
+	players, err := swissPlayers(DB, competitionID)
+	if err != nil {
+		return nil, err
+	}
+
+	standings := swissStandings(players, games)
+
+	names := map[int]string{}
+	for _, g := range games {
+		if g.Participant1ID != nil {
+			names[*g.Participant1ID] = g.Participant1Name
+		}
+		if g.Participant2ID != nil {
+			names[*g.Participant2ID] = g.Participant2Name
+		}
+	}
+	missing := []int{}
+	for _, s := range standings {
+		if _, ok := names[s.ParticipantID]; !ok {
+			missing = append(missing, s.ParticipantID)
+		}
+	}
+	if len(missing) > 0 {
+		rows, err := DB.Query("SELECT id, name FROM participants WHERE id = ANY($1)", pq.Array(missing))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				return nil, err
+			}
+			names[id] = name
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range standings {
+		standings[i].Name = names[standings[i].ParticipantID]
+	}
+
+	return standings, nil
+}
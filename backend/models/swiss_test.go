@@ -0,0 +1,248 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+// playSwissRounds pairs the given number of rounds, letting white win every
+// game, and returns the pairings of each round
+func playSwissRounds(t *testing.T, players []int, rounds int) [][][2]int {
+	t.Helper()
+
+	var games []*Match
+	var pairings [][][2]int
+	for r := 1; r <= rounds; r++ {
+		pairs, err := pairSwissRound(players, games)
+		if err != nil {
+			t.Fatalf("round %d: %v", r, err)
+		}
+		pairings = append(pairings, pairs)
+
+		for board, pair := range pairs {
+			white, black := pair[0], pair[1]
+			m := &Match{Stage: StageSwiss, Round: r, Position: board + 1, Participant1ID: &white}
+			if black == 0 {
+				m.Status = MatchBye
+			} else {
+				one, zero := 1.0, 0.0
+				m.Participant2ID = &black
+				m.Score1, m.Score2 = &one, &zero
+				m.Status = MatchCompleted
+			}
+			games = append(games, m)
+		}
+	}
+	return pairings
+}
+
+func TestPairSwissRound(t *testing.T) {
+	tests := []struct {
+		name    string
+		players int
+		rounds  int
+	}{
+		{"two players", 2, 1},
+		{"three players", 3, 3},
+		{"six players", 6, 3},
+		{"seven players", 7, 4},
+		{"eight players", 8, 4},
+		{"nine players", 9, 5},
+		{"sixteen players", 16, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			players := make([]int, tt.players)
+			for i := range players {
+				players[i] = i + 1
+			}
+
+			met := map[[2]int]bool{}
+			byes := map[int]int{}
+			for r, pairs := range playSwissRounds(t, players, tt.rounds) {
+				seen := map[int]bool{}
+				roundByes := 0
+				for _, pair := range pairs {
+					for _, id := range pair {
+						if id == 0 {
+							continue
+						}
+						if seen[id] {
+							t.Fatalf("round %d: player %d paired twice", r+1, id)
+						}
+						seen[id] = true
+					}
+					if pair[1] == 0 {
+						roundByes++
+						byes[pair[0]]++
+						continue
+					}
+					key := [2]int{pair[0], pair[1]}
+					if key[0] > key[1] {
+						key[0], key[1] = key[1], key[0]
+					}
+					if met[key] {
+						t.Fatalf("round %d: players %d and %d meet again", r+1, key[0], key[1])
+					}
+					met[key] = true
+				}
+				if len(seen) != tt.players {
+					t.Fatalf("round %d: %d of %d players paired", r+1, len(seen), tt.players)
+				}
+				if want := tt.players % 2; roundByes != want {
+					t.Fatalf("round %d: got %d byes, want %d", r+1, roundByes, want)
+				}
+			}
+			for id, n := range byes {
+				if n > 1 {
+					t.Errorf("player %d got %d byes", id, n)
+				}
+			}
+		})
+	}
+}
+
+func TestPairSwissRoundFirstRound(t *testing.T) {
+	tests := []struct {
+		name    string
+		players []int
+		want    [][2]int
+	}{
+		{"top half against bottom half", []int{1, 2, 3, 4}, [][2]int{{1, 3}, {4, 2}}},
+		{"bye for the lowest ranked", []int{1, 2, 3}, [][2]int{{1, 2}, {3, 0}}},
+		{"single player", []int{1}, [][2]int{{1, 0}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pairSwissRound(tt.players, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPairSwissRoundNoPairing(t *testing.T) {
+	one, zero := 1.0, 0.0
+	a, b := 1, 2
+	games := []*Match{{Stage: StageSwiss, Round: 1, Participant1ID: &a, Participant2ID: &b, Score1: &one, Score2: &zero, Status: MatchCompleted}}
+
+	if _, err := pairSwissRound([]int{1, 2}, games); err == nil {
+		t.Error("expected an error when the only pairing is a repeat")
+	}
+}
+
+// swissStanding builds a standing with the given points and earlier opponents
+func swissStanding(id int, points float64, opponents ...int) *SwissStanding {
+	s := &SwissStanding{ParticipantID: id, Points: points, opponents: map[int]bool{}}
+	for _, opponent := range opponents {
+		s.opponents[opponent] = true
+	}
+	return s
+}
+
+func TestPairSwissGroup(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining []*SwissStanding
+		budget    int
+		want      [][2]int
+		wantOK    bool
+	}{
+		{
+			name:      "empty",
+			remaining: nil,
+			budget:    10,
+			want:      nil,
+			wantOK:    true,
+		},
+		{
+			name:      "top half against bottom half",
+			remaining: []*SwissStanding{swissStanding(1, 1), swissStanding(2, 1), swissStanding(3, 1), swissStanding(4, 1)},
+			budget:    10,
+			want:      [][2]int{{1, 3}, {2, 4}},
+			wantOK:    true,
+		},
+		{
+			name:      "avoids a repeat opponent",
+			remaining: []*SwissStanding{swissStanding(1, 1, 3), swissStanding(2, 1), swissStanding(3, 1, 1), swissStanding(4, 1)},
+			budget:    10,
+			want:      [][2]int{{1, 4}, {2, 3}},
+			wantOK:    true,
+		},
+		{
+			name:      "score groups first",
+			remaining: []*SwissStanding{swissStanding(1, 2), swissStanding(2, 2), swissStanding(3, 0), swissStanding(4, 0)},
+			budget:    10,
+			want:      [][2]int{{1, 2}, {3, 4}},
+			wantOK:    true,
+		},
+		{
+			name:      "drops to a lower group",
+			remaining: []*SwissStanding{swissStanding(1, 2, 2), swissStanding(2, 2, 1), swissStanding(3, 0), swissStanding(4, 0)},
+			budget:    10,
+			want:      [][2]int{{1, 3}, {2, 4}},
+			wantOK:    true,
+		},
+		{
+			name:      "only repeats left",
+			remaining: []*SwissStanding{swissStanding(1, 1, 2), swissStanding(2, 1, 1)},
+			budget:    10,
+			wantOK:    false,
+		},
+		{
+			name:      "budget exhausted",
+			remaining: []*SwissStanding{swissStanding(1, 1), swissStanding(2, 1)},
+			budget:    0,
+			wantOK:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := tt.budget
+			pairs, ok := pairSwissGroup(tt.remaining, nil, &budget)
+			if ok != tt.wantOK {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOK)
+			}
+
+			var got [][2]int
+			for _, pair := range pairs {
+				got = append(got, [2]int{pair[0].ParticipantID, pair[1].ParticipantID})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSwissGetsWhite(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  SwissStanding
+		board int
+		want  bool
+	}{
+		{"fewer whites gets white", SwissStanding{ColorBalance: 1}, SwissStanding{ColorBalance: -1}, 0, true},
+		{"more whites gets black", SwissStanding{ColorBalance: -1}, SwissStanding{ColorBalance: 1}, 0, false},
+		{"black last gets white", SwissStanding{lastColor: 1}, SwissStanding{lastColor: -1}, 0, true},
+		{"white last gets black", SwissStanding{lastColor: -1}, SwissStanding{lastColor: 1}, 0, false},
+		{"balance before last color", SwissStanding{ColorBalance: -1, lastColor: -1}, SwissStanding{ColorBalance: 0, lastColor: 1}, 0, false},
+		{"even board keeps white", SwissStanding{}, SwissStanding{}, 0, false},
+		{"odd board swaps", SwissStanding{}, SwissStanding{}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := swissGetsWhite(&tt.b, &tt.a, tt.board); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,449 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Team is a group of participants competing together in a competition, such
+// as a relay squad or a chess team
+type Team struct {
+	ID            int          `json:"id"`
+	CompetitionID int          `json:"competition_id"`
+	Name          string       `json:"name"`
+	Members       []TeamMember `json:"members"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// TeamMember is a participant on a team's roster
+type TeamMember struct {
+	ParticipantID int    `json:"participant_id"`
+	Name          string `json:"name"`
+	Role          string `json:"role"`
+}
+
+// TeamResult is a team's score in a competition
+type TeamResult struct {
+	ID            int       `json:"id"`
+	CompetitionID int       `json:"competition_id"`
+	TeamID        int       `json:"team_id"`
+	Score         float64   `json:"score"`
+	Notes         string    `json:"notes"`
+	RecordedBy    string    `json:"recorded_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TeamStanding is a team's position in a competition's team results table
+type TeamStanding struct {
+	Rank   int     `json:"rank"`
+	TeamID int     `json:"team_id"`
+	Name   string  `json:"name"`
+	Score  float64 `json:"score"`
+}
+
+// GetTeams retrieves the teams of a competition with their rosters
+func GetTeams(competitionID int) ([]Team, error) {
+	rows, err := DB.Query(`
+		SELECT id, competition_id, name, created_at, updated_at
+		FROM teams
+		WHERE competition_id = $1
+		ORDER BY name ASC
+	`, competitionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []Team{}
+	for rows.Next() {
+		var t Team
+		if err := rows.Scan(&t.ID, &t.CompetitionID, &t.Name, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range teams {
+		teams[i].Members, err = getTeamMembers(DB, teams[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return teams, nil
+}
+
+// GetTeam retrieves a team of a competition with its roster
+func GetTeam(competitionID, id int) (Team, error) {
+	var t Team
+	err := DB.QueryRow(`
+		SELECT id, competition_id, name, created_at, updated_at
+		FROM teams
+		WHERE id = $1 AND competition_id = $2
+	`, id, competitionID).Scan(&t.ID, &t.CompetitionID, &t.Name, &t.CreatedAt, &t.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return t, errors.New("team not found")
+	}
+	if err != nil {
+		return t, err
+	}
+
+	t.Members, err = getTeamMembers(DB, id)
+	return t, err
+}
+
+// getTeamMembers loads a team's roster, captain first. Members whose
+// registration or profile is in the trash are left out.
+func getTeamMembers(q querier, teamID int) ([]TeamMember, error) {
+	rows, err := q.Query(`
+		SELECT tm.participant_id, p.name, tm.role
+		FROM team_members tm
+		JOIN participants p ON p.id = tm.participant_id
+		JOIN competition_participants cp ON cp.competition_id = tm.competition_id AND cp.participant_id = tm.participant_id
+		WHERE tm.team_id = $1 AND p.deleted_at IS NULL AND cp.deleted_at IS NULL
+		ORDER BY tm.role = 'captain' DESC, tm.role = 'reserve' ASC, p.name ASC
+	`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []TeamMember{}
+	for rows.Next() {
+		var m TeamMember
+		if err := rows.Scan(&m.ParticipantID, &m.Name, &m.Role); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// lockTeam loads a team of a competition with its roster and locks it for the
+// rest of the transaction
+func lockTeam(q querier, competitionID, id int) (Team, error) {
+	var t Team
+	err := q.QueryRow(`
+		SELECT id, competition_id, name, created_at, updated_at
+		FROM teams
+		WHERE id = $1 AND competition_id = $2
+		FOR UPDATE
+	`, id, competitionID).Scan(&t.ID, &t.CompetitionID, &t.Name, &t.CreatedAt, &t.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return t, errors.New("team not found")
+	}
+	if err != nil {
+		return t, err
+	}
+
+	t.Members, err = getTeamMembers(q, id)
+	return t, err
+}
+
+// teamNameTaken checks if a competition has a team other than excludeID with the given name
+func teamNameTaken(q querier, competitionID int, name string, excludeID int) (bool, error) {
+	var count int
+	err := q.QueryRow("SELECT COUNT(*) FROM teams WHERE competition_id = $1 AND LOWER(name) = LOWER($2) AND id != $3", competitionID, name, excludeID).Scan(&count)
+	return count > 0, err
+}
+
+// CreateTeam adds a team with its roster to a competition. Members not yet
+// registered for the competition are registered with it, so a whole team
+// enters in one request. It reports whether any registration was created.
+func CreateTeam(meta ChangeMeta, t *Team) (bool, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	competition, err := lockCompetition(tx, t.CompetitionID)
+	if err == sql.ErrNoRows || (err == nil && competition.DeletedAt != nil) {
+		return false, errors.New("competition does not exist")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	taken, err := teamNameTaken(tx, t.CompetitionID, t.Name, 0)
+	if err != nil {
+		return false, err
+	}
+	if taken {
+		return false, errors.New("team name already used")
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO teams (competition_id, name)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at
+	`, t.CompetitionID, t.Name).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	registered, err := setTeamMembers(tx, meta, competition, t)
+	if err != nil {
+		return false, err
+	}
+
+	if err := recordAudit(tx, meta, "team", strconv.Itoa(t.ID), "create", nil, t); err != nil {
+		return false, err
+	}
+
+	return registered, tx.commit()
+}
+
+// UpdateTeam renames a team and replaces its roster, registering new members
+// for the competition where needed. It reports whether any registration was
+// created.
+func UpdateTeam(meta ChangeMeta, t *Team) (bool, error) {
+	tx, err := beginChange()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	competition, err := lockCompetition(tx, t.CompetitionID)
+	if err == sql.ErrNoRows || (err == nil && competition.DeletedAt != nil) {
+		return false, errors.New("team not found")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	before, err := lockTeam(tx, t.CompetitionID, t.ID)
+	if err != nil {
+		return false, err
+	}
+
+	taken, err := teamNameTaken(tx, t.CompetitionID, t.Name, t.ID)
+	if err != nil {
+		return false, err
+	}
+	if taken {
+		return false, errors.New("team name already used")
+	}
+
+	err = tx.QueryRow(`
+		UPDATE teams
+		SET name = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, t.ID, t.Name).Scan(&t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	registered, err := setTeamMembers(tx, meta, competition, t)
+	if err != nil {
+		return false, err
+	}
+
+	if err := recordAudit(tx, meta, "team", strconv.Itoa(t.ID), "update", before, t); err != nil {
+		return false, err
+	}
+
+	return registered, tx.commit()
+}
+
+// setTeamMembers replaces the roster of a team after checking it against the
+// competition's roster sizes and the other teams. Members are registered for
+// the competition if they are not yet.
+func setTeamMembers(tx *changeTx, meta ChangeMeta, competition Competition, t *Team) (bool, error) {
+	if competition.MinTeamSize != nil && len(t.Members) < *competition.MinTeamSize {
+		return false, errors.New("team has too few members")
+	}
+	if competition.MaxTeamSize != nil && len(t.Members) > *competition.MaxTeamSize {
+		return false, errors.New("team has too many members")
+	}
+
+	if _, err := tx.Exec("DELETE FROM team_members WHERE team_id = $1", t.ID); err != nil {
+		return false, err
+	}
+
+	registered := false
+	for _, member := range t.Members {
+		registration, err := lockRegistration(tx, member.ParticipantID, t.CompetitionID)
+		if err != nil && err != sql.ErrNoRows {
+			return false, err
+		}
+		if err == sql.ErrNoRows || registration.DeletedAt != nil {
+			if err := addParticipantToCompetition(tx, meta, member.ParticipantID, t.CompetitionID, time.Now(), ""); err != nil {
+				return false, err
+			}
+			registered = true
+		} else if !participantExists(tx, member.ParticipantID) {
+			return false, errors.New("participant does not exist")
+		}
+
+		// A participant can only compete for one team per competition
+		var onOtherTeam bool
+		err = tx.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM team_members WHERE competition_id = $1 AND participant_id = $2)
+		`, t.CompetitionID, member.ParticipantID).Scan(&onOtherTeam)
+		if err != nil {
+			return false, err
+		}
+		if onOtherTeam {
+			return false, errors.New("participant already on another team in this competition")
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO team_members (team_id, competition_id, participant_id, role)
+			VALUES ($1, $2, $3, $4)
+		`, t.ID, t.CompetitionID, member.ParticipantID, member.Role)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	var err error
+	t.Members, err = getTeamMembers(tx, t.ID)
+	return registered, err
+}
+
+// DeleteTeam removes a team and its results from a competition. The members
+// stay registered for the competition.
+func DeleteTeam(meta ChangeMeta, competitionID, id int) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if !competitionExists(tx, competitionID) {
+		return errors.New("team not found")
+	}
+
+	before, err := lockTeam(tx, competitionID, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM teams WHERE id = $1", id); err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "team", strconv.Itoa(id), "delete", before, nil); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// getTeamMembership looks up the team a participant belongs to in a
+// competition, returning a team ID of 0 if there is none
+func getTeamMembership(q querier, competitionID, participantID int) (int, string, error) {
+	var teamID int
+	var role string
+	err := q.QueryRow(`
+		SELECT team_id, role
+		FROM team_members
+		WHERE competition_id = $1 AND participant_id = $2
+	`, competitionID, participantID).Scan(&teamID, &role)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
+	return teamID, role, err
+}
+
+// RecordTeamResult stores a team's score in a competition, replacing any
+// earlier score, and emits a team result event for live subscribers
+func RecordTeamResult(meta ChangeMeta, r *TeamResult) error {
+	tx, err := beginChange()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM teams t
+			JOIN competitions c ON c.id = t.competition_id
+			WHERE t.id = $1 AND t.competition_id = $2 AND c.deleted_at IS NULL
+		)
+	`, r.TeamID, r.CompetitionID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("team not found in this competition")
+	}
+
+	var before interface{}
+	var previous TeamResult
+	err = tx.QueryRow(`
+		SELECT id, competition_id, team_id, score, notes, recorded_by, created_at, updated_at
+		FROM team_results
+		WHERE team_id = $1
+		FOR UPDATE
+	`, r.TeamID).Scan(&previous.ID, &previous.CompetitionID, &previous.TeamID,
+		&previous.Score, &previous.Notes, &previous.RecordedBy, &previous.CreatedAt, &previous.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	action := "create"
+	if err == nil {
+		before = previous
+		action = "update"
+	}
+
+	r.RecordedBy = meta.Actor
+	err = tx.QueryRow(`
+		INSERT INTO team_results (competition_id, team_id, score, notes, recorded_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (team_id) DO UPDATE
+		SET score = EXCLUDED.score, notes = EXCLUDED.notes, recorded_by = EXCLUDED.recorded_by, updated_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at, updated_at
+	`, r.CompetitionID, r.TeamID, r.Score, r.Notes, r.RecordedBy).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, meta, "team_result", strconv.Itoa(r.ID), action, before, r); err != nil {
+		return err
+	}
+
+	if err := recordEvent(tx, EventTeamResultRecorded, r.CompetitionID, r); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// GetTeamStandings ranks the scored teams of a competition, highest score
+// first. Teams with equal scores share a rank.
+func GetTeamStandings(competitionID int) ([]TeamStanding, error) {
+	rows, err := DB.Query(`
+		SELECT RANK() OVER (ORDER BY r.score DESC), t.id, t.name, r.score
+		FROM team_results r
+		JOIN teams t ON t.id = r.team_id
+		WHERE r.competition_id = $1
+		ORDER BY r.score DESC, t.name ASC
+	`, competitionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	standings := []TeamStanding{}
+	for rows.Next() {
+		var s TeamStanding
+		if err := rows.Scan(&s.Rank, &s.TeamID, &s.Name, &s.Score); err != nil {
+			return nil, err
+		}
+		standings = append(standings, s)
+	}
+
+	return standings, rows.Err()
+}
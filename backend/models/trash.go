@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// PurgeDeleted permanently removes rows that have been in the trash for longer
+// than the retention period
+func PurgeDeleted(retention time.Duration) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		"DELETE FROM competition_participants WHERE deleted_at <= CURRENT_TIMESTAMP - make_interval(secs => $1)",
+		"DELETE FROM participants WHERE deleted_at <= CURRENT_TIMESTAMP - make_interval(secs => $1)",
+		"DELETE FROM competitions WHERE deleted_at <= CURRENT_TIMESTAMP - make_interval(secs => $1)",
+	}
+
+	var purged int64
+	for _, query := range queries {
+		result, err := tx.Exec(query, int64(retention/time.Second))
+		if err != nil {
+			return 0, err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		purged += rowsAffected
+	}
+
+	// Purged competitions take their matches, results and times with them
+	if purged > 0 {
+		disciplines, err := ratingDisciplines(tx)
+		if err != nil {
+			return 0, err
+		}
+		for _, discipline := range disciplines {
+			if err := recomputeRatings(tx, discipline); err != nil {
+				return 0, err
+			}
+		}
+
+		disciplines, err = markDisciplines(tx)
+		if err != nil {
+			return 0, err
+		}
+		for _, discipline := range disciplines {
+			if err := recomputeMarks(tx, discipline); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,313 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+type WebhookSubscription struct {
+	ID         int       `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	SubscriptionID int        `json:"subscription_id"`
+	EventID        int64      `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int       `json:"last_status_code,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// DueDelivery is a claimed delivery together with everything needed to send it
+type DueDelivery struct {
+	ID       int64
+	Attempts int
+	Claim    int
+	URL      string
+	Secret   string
+	Event    OutboxEvent
+}
+
+// GetAllWebhookSubscriptions retrieves all webhook subscriptions
+func GetAllWebhookSubscriptions() ([]WebhookSubscription, error) {
+	rows, err := DB.Query(`
+		SELECT id, url, event_types, active, created_at, updated_at
+		FROM webhook_subscriptions
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []WebhookSubscription
+	for rows.Next() {
+		var s WebhookSubscription
+		err := rows.Scan(&s.ID, &s.URL, pq.Array(&s.EventTypes), &s.Active, &s.CreatedAt, &s.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, s)
+	}
+
+	return subscriptions, nil
+}
+
+// GetWebhookSubscription retrieves a single webhook subscription by ID
+func GetWebhookSubscription(id int) (WebhookSubscription, error) {
+	var s WebhookSubscription
+	err := DB.QueryRow(`
+		SELECT id, url, event_types, active, created_at, updated_at
+		FROM webhook_subscriptions
+		WHERE id = $1
+	`, id).Scan(&s.ID, &s.URL, pq.Array(&s.EventTypes), &s.Active, &s.CreatedAt, &s.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return s, errors.New("webhook subscription not found")
+	}
+
+	return s, err
+}
+
+// CreateWebhookSubscription adds a new webhook subscription
+func CreateWebhookSubscription(s *WebhookSubscription) error {
+	if s.EventTypes == nil {
+		s.EventTypes = []string{}
+	}
+
+	return DB.QueryRow(`
+		INSERT INTO webhook_subscriptions (url, secret, event_types, active)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`, s.URL, s.Secret, pq.Array(s.EventTypes), s.Active).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// UpdateWebhookSubscription updates the URL, event types and state of a subscription
+func UpdateWebhookSubscription(s *WebhookSubscription) error {
+	if s.EventTypes == nil {
+		s.EventTypes = []string{}
+	}
+
+	err := DB.QueryRow(`
+		UPDATE webhook_subscriptions
+		SET url = $2, event_types = $3, active = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, s.ID, s.URL, pq.Array(s.EventTypes), s.Active).Scan(&s.CreatedAt, &s.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return errors.New("webhook subscription not found")
+	}
+
+	return err
+}
+
+// DeleteWebhookSubscription removes a subscription and its delivery history
+func DeleteWebhookSubscription(id int) error {
+	result, err := DB.Exec("DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("webhook subscription not found")
+	}
+
+	return nil
+}
+
+// GetWebhookDeliveries retrieves the delivery history of a subscription,
+// optionally filtered by status
+func GetWebhookDeliveries(subscriptionID int, status string, limit int) ([]WebhookDelivery, error) {
+	rows, err := DB.Query(`
+		SELECT d.id, d.subscription_id, d.event_id, e.event_type, d.status, d.attempts, d.next_attempt_at,
+			d.last_status_code, d.last_error, d.delivered_at, d.created_at, d.updated_at
+		FROM webhook_deliveries d
+		JOIN outbox_events e ON e.id = d.event_id
+		WHERE d.subscription_id = $1 AND ($2 = '' OR d.status = $2)
+		ORDER BY d.id DESC
+		LIMIT $3
+	`, subscriptionID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+// RetryWebhookDelivery puts a dead-lettered delivery back in the queue
+func RetryWebhookDelivery(subscriptionID int, deliveryID int64) error {
+	result, err := DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $3, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND subscription_id = $2 AND status = $4
+	`, deliveryID, subscriptionID, DeliveryPending, DeliveryDead)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("dead delivery not found")
+	}
+
+	return nil
+}
+
+// PurgeFinishedDeliveries removes delivered and dead webhook deliveries that
+// last changed longer than the retention period ago
+func PurgeFinishedDeliveries(retention time.Duration) (int64, error) {
+	result, err := DB.Exec(`
+		DELETE FROM webhook_deliveries
+		WHERE status IN ($1, $2) AND updated_at <= CURRENT_TIMESTAMP - make_interval(secs => $3)
+	`, DeliveryDelivered, DeliveryDead, int64(retention/time.Second))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ClaimDueDeliveries picks pending deliveries whose next attempt is due and
+// pushes their next attempt back by the lease, so other dispatchers skip them
+// while they are being sent. Each claim is numbered, and the outcome of an
+// attempt is only recorded under the claim it was made with.
+func ClaimDueDeliveries(limit int, lease time.Duration) ([]DueDelivery, error) {
+	rows, err := DB.Query(`
+		WITH due AS (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY next_attempt_at ASC
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET claims = d.claims + 1, next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $3), updated_at = CURRENT_TIMESTAMP
+		FROM due, webhook_subscriptions s, outbox_events e
+		WHERE d.id = due.id AND s.id = d.subscription_id AND e.id = d.event_id
+		RETURNING d.id, d.attempts, d.claims, s.url, s.secret, e.id, e.event_type, e.competition_id, e.payload, e.created_at
+	`, DeliveryPending, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []DueDelivery
+	for rows.Next() {
+		var d DueDelivery
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.Attempts, &d.Claim, &d.URL, &d.Secret, &d.Event.ID, &d.Event.Type, &d.Event.CompetitionID, &payload, &d.Event.OccurredAt)
+		if err != nil {
+			return nil, err
+		}
+		d.Event.Data = json.RawMessage(payload)
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// MarkDeliverySucceeded records a successful delivery attempt made under the
+// given claim
+func MarkDeliverySucceeded(id int64, claim int, statusCode int) error {
+	result, err := DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $3, attempts = attempts + 1, last_status_code = $4, last_error = NULL,
+			next_attempt_at = NULL, delivered_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND claims = $2 AND status = $5
+	`, id, claim, DeliveryDelivered, statusCode, DeliveryPending)
+	if err != nil {
+		return err
+	}
+
+	return claimedRowUpdated(result)
+}
+
+// MarkDeliveryFailed records a failed delivery attempt made under the given
+// claim. A zero retryAfter moves the delivery to the dead-letter state instead
+// of scheduling another attempt.
+func MarkDeliveryFailed(id int64, claim int, statusCode int, message string, retryAfter time.Duration) error {
+	var lastStatusCode interface{}
+	if statusCode != 0 {
+		lastStatusCode = statusCode
+	}
+
+	status := DeliveryPending
+	var retrySeconds interface{} = retryAfter.Seconds()
+	if retryAfter == 0 {
+		status = DeliveryDead
+		retrySeconds = nil
+	}
+
+	result, err := DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $3, attempts = attempts + 1, last_status_code = $4, last_error = $5,
+			next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $6), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND claims = $2 AND status = $7
+	`, id, claim, status, lastStatusCode, message, retrySeconds, DeliveryPending)
+	if err != nil {
+		return err
+	}
+
+	return claimedRowUpdated(result)
+}
+
+// claimedRowUpdated reports an error when recording the outcome of a claimed
+// attempt changed nothing because the claim had been taken over
+func claimedRowUpdated(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("claim expired")
+	}
+
+	return nil
+}
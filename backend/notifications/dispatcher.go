@@ -0,0 +1,119 @@
+package notifications
+
+import (
+	"competition-app/config"
+	"competition-app/models"
+	"encoding/json"
+	"log"
+	"net/mail"
+	"time"
+)
+
+const batchSize = 50
+
+// Dispatcher sends queued notifications through the configured SMTP server
+type Dispatcher struct {
+	mailer       *Mailer
+	pollInterval time.Duration
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+}
+
+// NewDispatcher creates a dispatcher using the SMTP and notification settings
+// from the config. It returns nil when no SMTP server is configured.
+func NewDispatcher(cfg *config.Config) (*Dispatcher, error) {
+	if cfg.SMTPHost == "" {
+		return nil, nil
+	}
+
+	from, err := mail.ParseAddress(cfg.SMTPFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Dispatcher{
+		mailer: &Mailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     from,
+			Timeout:  cfg.SMTPTimeout,
+		},
+		pollInterval: cfg.NotificationPollInterval,
+		maxAttempts:  cfg.NotificationMaxAttempts,
+		baseBackoff:  cfg.NotificationBaseBackoff,
+		maxBackoff:   6 * time.Hour,
+	}, nil
+}
+
+// Run sends due notifications until the process exits
+func (d *Dispatcher) Run() {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Notifications are claimed one at a time and stay claimed for a little
+		// longer than sending can take, so the lease never runs out while
+		// earlier notifications are still being sent
+		for i := 0; i < batchSize; i++ {
+			notifications, err := models.ClaimDueNotifications(1, 2*d.mailer.Timeout)
+			if err != nil {
+				log.Printf("Warning: failed to claim notifications: %v", err)
+				break
+			}
+			if len(notifications) == 0 {
+				break
+			}
+			d.deliver(notifications[0])
+		}
+	}
+}
+
+// deliver renders and sends one notification and records the outcome
+func (d *Dispatcher) deliver(n models.Notification) {
+	err := d.send(n)
+	if err == nil {
+		if err := models.MarkNotificationSent(n.ID, n.Claim); err != nil {
+			log.Printf("Warning: failed to record notification %d: %v", n.ID, err)
+		}
+		return
+	}
+
+	var retryAfter time.Duration
+	if n.Attempts+1 < d.maxAttempts {
+		retryAfter = d.backoff(n.Attempts + 1)
+	}
+
+	if err := models.MarkNotificationFailed(n.ID, n.Claim, err.Error(), retryAfter); err != nil {
+		log.Printf("Warning: failed to record notification %d: %v", n.ID, err)
+	}
+}
+
+// send renders a notification in the recipient's locale and mails it
+func (d *Dispatcher) send(n models.Notification) error {
+	var data models.NotificationData
+	if err := json.Unmarshal(n.Data, &data); err != nil {
+		return err
+	}
+
+	msg, err := Render(n, data)
+	if err != nil {
+		return err
+	}
+
+	return d.mailer.Send(&mail.Address{Name: n.RecipientName, Address: n.Recipient}, msg)
+}
+
+// backoff returns the exponential delay before the given retry attempt
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.baseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= d.maxBackoff {
+			return d.maxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,113 @@
+package notifications
+
+import (
+	"bytes"
+	"competition-app/models"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// Message is a rendered email
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// templateData is what the email templates can refer to
+type templateData struct {
+	RecipientName string
+	models.NotificationData
+}
+
+// dateFormats are the long date formats per locale; month names are translated separately
+var dateFormats = map[string]string{
+	"en": "January 2, 2006",
+	"de": "2. January 2006",
+}
+
+var monthNames = map[string][]string{
+	"de": {"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+}
+
+// formatDate formats a YYYY-MM-DD date for the given locale
+func formatDate(locale, value string) string {
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return value
+	}
+
+	formatted := date.Format(dateFormats[locale])
+	if names, ok := monthNames[locale]; ok {
+		formatted = strings.Replace(formatted, date.Month().String(), names[date.Month()-1], 1)
+	}
+	return formatted
+}
+
+// resolveLocale picks the locale to render for, falling back from a regional
+// variant to its language and then to the default locale
+func resolveLocale(locale string) string {
+	if _, ok := dateFormats[locale]; ok {
+		return locale
+	}
+	if i := strings.Index(locale, "-"); i > 0 {
+		if _, ok := dateFormats[locale[:i]]; ok {
+			return locale[:i]
+		}
+	}
+	return models.DefaultLocale
+}
+
+// Render renders a notification in the recipient's locale
+func Render(n models.Notification, data models.NotificationData) (Message, error) {
+	var msg Message
+
+	locale := resolveLocale(n.Locale)
+	funcs := map[string]interface{}{
+		"date": func(value string) string { return formatDate(locale, value) },
+	}
+	values := templateData{RecipientName: n.RecipientName, NotificationData: data}
+	base := "templates/" + locale + "/" + n.Template
+
+	textSource, err := fs.ReadFile(templateFiles, base+".txt")
+	if err != nil {
+		return msg, fmt.Errorf("unknown notification template %q", n.Template)
+	}
+	textTmpl, err := texttemplate.New(n.Template).Funcs(funcs).Parse(string(textSource))
+	if err != nil {
+		return msg, err
+	}
+
+	htmlSource, err := fs.ReadFile(templateFiles, base+".html")
+	if err != nil {
+		return msg, fmt.Errorf("unknown notification template %q", n.Template)
+	}
+	htmlTmpl, err := htmltemplate.New(n.Template).Funcs(funcs).Parse(string(htmlSource))
+	if err != nil {
+		return msg, err
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", values); err != nil {
+		return msg, err
+	}
+	if err := textTmpl.Execute(&text, values); err != nil {
+		return msg, err
+	}
+	if err := htmlTmpl.Execute(&html, values); err != nil {
+		return msg, err
+	}
+
+	msg.Subject = strings.TrimSpace(subject.String())
+	msg.Text = text.String()
+	msg.HTML = html.String()
+	return msg, nil
+}
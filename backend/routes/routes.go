@@ -1,40 +1,161 @@
 package routes
 
 import (
+	"competition-app/config"
 	"competition-app/controllers"
 	"competition-app/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter() *gin.Engine {
+func SetupRouter(cfg *config.Config) *gin.Engine {
 	router := gin.Default()
 	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.ActorMiddleware(cfg.AdminToken))
+	router.Use(middleware.IdempotencyMiddleware(cfg.IdempotencyTTL))
+
+	requireIfMatch := middleware.RequireIfMatchMiddleware(cfg.RequireIfMatch)
 
 	// Healthcheck
 	router.GET("/api", controllers.HealthCheck)
 
+	// Calendar feeds
+	router.GET("/api/competitions.ics", controllers.GetCompetitionsCalendar)
+	router.GET("/api/calendars/:token", controllers.GetParticipantCalendar)
+
 	// Competitions API
 	competitions := router.Group("/api/competitions")
 	{
 		competitions.GET("", controllers.GetCompetitions)
 		competitions.GET("/:id", controllers.GetCompetition)
+		competitions.GET("/:id/stream", controllers.StreamCompetition)
+		competitions.GET("/:id/standings", controllers.GetStandings)
+		competitions.GET("/:id/records", controllers.GetCompetitionRecords)
+		competitions.GET("/:id/scoring", controllers.ScoringChannel)
+		competitions.GET("/:id/export", controllers.ExportCompetition)
+		competitions.GET("/:id/categories", controllers.GetCategories)
+		competitions.GET("/:id/categories/:category_id/participants", controllers.GetCategoryParticipants)
+		competitions.POST("/:id/categories", controllers.CreateCategory)
+		competitions.PUT("/:id/categories/:category_id", controllers.UpdateCategory)
+		competitions.DELETE("/:id/categories/:category_id", controllers.DeleteCategory)
+		competitions.GET("/:id/events", controllers.GetEvents)
+		competitions.GET("/:id/events/:event_id/participants", controllers.GetEventParticipants)
+		competitions.POST("/:id/events", controllers.CreateEvent)
+		competitions.PUT("/:id/events/:event_id", controllers.UpdateEvent)
+		competitions.DELETE("/:id/events/:event_id", controllers.DeleteEvent)
+		competitions.POST("/:id/events/:event_id/participants", controllers.AddParticipantToEvent)
+		competitions.PUT("/:id/events/:event_id/participants/:participant_id", controllers.UpdateEventEntry)
+		competitions.DELETE("/:id/events/:event_id/participants/:participant_id", controllers.RemoveParticipantFromEvent)
+		competitions.GET("/:id/events/:event_id/rounds/:round/heats", controllers.GetHeats)
+		competitions.GET("/:id/events/:event_id/rounds/:round/start-list", controllers.ExportStartList)
+		competitions.POST("/:id/events/:event_id/rounds/:round/heats", controllers.GenerateHeats)
+		competitions.DELETE("/:id/events/:event_id/rounds/:round/heats", controllers.DeleteHeats)
+		competitions.PUT("/:id/events/:event_id/rounds/:round/lanes/:participant_id", controllers.MoveHeatLane)
+		competitions.PUT("/:id/events/:event_id/rounds/:round/times/:participant_id", controllers.RecordHeatTime)
+		competitions.GET("/:id/teams", controllers.GetTeams)
+		competitions.GET("/:id/teams/:team_id", controllers.GetTeam)
+		competitions.GET("/:id/team-standings", controllers.GetTeamStandings)
+		competitions.POST("/:id/teams", controllers.CreateTeam)
+		competitions.PUT("/:id/teams/:team_id", controllers.UpdateTeam)
+		competitions.DELETE("/:id/teams/:team_id", controllers.DeleteTeam)
+		competitions.GET("/:id/bracket", controllers.GetBracket)
+		competitions.POST("/:id/bracket", controllers.GenerateBracket)
+		competitions.DELETE("/:id/bracket", controllers.DeleteBracket)
+		competitions.PUT("/:id/bracket/matches/:match_id", controllers.RecordMatchResult)
+		competitions.GET("/:id/swiss/rounds", controllers.GetSwissRounds)
+		competitions.POST("/:id/swiss/rounds", controllers.GenerateSwissRound)
+		competitions.PUT("/:id/swiss/games/:game_id", controllers.RecordSwissResult)
+		competitions.GET("/:id/swiss/standings", controllers.GetSwissStandings)
+		competitions.GET("/:id/round-robin", controllers.GetRoundRobin)
+		competitions.GET("/:id/round-robin/standings", controllers.GetRoundRobinStandings)
+		competitions.POST("/:id/round-robin", controllers.GenerateRoundRobin)
+		competitions.PUT("/:id/round-robin", controllers.UpdateRoundRobin)
+		competitions.DELETE("/:id/round-robin", controllers.DeleteRoundRobin)
+		competitions.PUT("/:id/round-robin/matches/:match_id", controllers.RecordRoundRobinResult)
+		competitions.GET("/:id/seed", controllers.GetSeeding)
+		competitions.POST("/:id/seed", controllers.ComputeSeeding)
+		competitions.POST("/:id/seed/lock", controllers.LockSeeding)
+		competitions.DELETE("/:id/seed/lock", controllers.UnlockSeeding)
 		competitions.POST("", controllers.CreateCompetition)
-		competitions.PUT("/:id", controllers.UpdateCompetition)
+		competitions.PUT("/:id", requireIfMatch, controllers.UpdateCompetition)
+		competitions.PATCH("/:id", requireIfMatch, controllers.PatchCompetition)
 		competitions.DELETE("/:id", controllers.DeleteCompetition)
+		competitions.POST("/:id/restore", controllers.RestoreCompetition)
 	}
 
 	// Participants API
 	participants := router.Group("/api/participants")
 	{
 		participants.GET("", controllers.GetParticipants)
+		participants.GET("/duplicates", controllers.FindDuplicateParticipants)
 		participants.GET("/:id", controllers.GetParticipant)
 		participants.GET("/:id/competitions", controllers.GetParticipantCompetitions)
+		participants.GET("/:id/ratings", controllers.GetParticipantRatings)
+		participants.GET("/:id/personal-bests", controllers.GetPersonalBests)
+		participants.GET("/:id/progression", controllers.GetProgression)
 		participants.POST("", controllers.CreateParticipant)
+		participants.POST("/import", controllers.ImportParticipants)
 		participants.POST("/:id/competitions", controllers.AddParticipantToCompetition)
-		participants.PUT("/:id", controllers.UpdateParticipant)
+		participants.POST("/:id/calendar-token", middleware.AdminAuthMiddleware(cfg.AdminToken), controllers.IssueCalendarToken)
+		participants.PUT("/:id", requireIfMatch, controllers.UpdateParticipant)
+		participants.PATCH("/:id", requireIfMatch, controllers.PatchParticipant)
 		participants.DELETE("/:id/competitions/:competition_id", controllers.RemoveParticipantFromCompetition)
 		participants.DELETE("/:id", controllers.DeleteParticipant)
+		participants.POST("/:id/restore", controllers.RestoreParticipant)
+		participants.POST("/:id/merge", controllers.MergeParticipant)
+		participants.POST("/:id/competitions/:competition_id/restore", controllers.RestoreParticipantToCompetition)
+	}
+
+	// Records API
+	router.GET("/api/records", controllers.GetRecords)
+
+	// Self-service API
+	router.POST("/api/signup", controllers.RequestSignup)
+	router.POST("/api/signup/confirm", controllers.ConfirmSignup)
+	router.POST("/api/auth/magic-link", controllers.RequestMagicLink)
+	router.POST("/api/auth/magic-link/verify", controllers.VerifyMagicLink)
+
+	me := router.Group("/api/me", middleware.ParticipantAuthMiddleware())
+	{
+		me.GET("", controllers.GetMe)
+		me.GET("/competitions", controllers.GetMyCompetitions)
+		me.POST("/competitions", controllers.RegisterMe)
+		me.DELETE("/competitions/:competition_id", controllers.UnregisterMe)
+	}
+
+	// Admin API
+	admin := router.Group("/api/admin", middleware.AdminAuthMiddleware(cfg.AdminToken))
+	{
+		admin.GET("/trash", controllers.GetTrash)
+		admin.GET("/export", controllers.ExportDatabase)
+		admin.POST("/ratings/recompute", controllers.RecomputeRatings)
+		admin.POST("/records/recompute", controllers.RecomputeRecords)
+		admin.GET("/notifications", controllers.GetNotifications)
+		admin.POST("/notifications/:id/retry", controllers.RetryNotification)
+	}
+
+	// Audit API
+	router.GET("/api/audit", middleware.AdminAuthMiddleware(cfg.AdminToken), controllers.GetAuditLog)
+
+	// Officials API
+	officials := router.Group("/api/officials", middleware.AdminAuthMiddleware(cfg.AdminToken))
+	{
+		officials.GET("", controllers.GetOfficials)
+		officials.POST("", controllers.CreateOfficial)
+		officials.DELETE("/:id", controllers.DeleteOfficial)
+	}
+
+	// Webhooks API
+	webhooks := router.Group("/api/webhooks", middleware.AdminAuthMiddleware(cfg.AdminToken))
+	{
+		webhooks.GET("", controllers.GetWebhooks)
+		webhooks.GET("/:id", controllers.GetWebhook)
+		webhooks.POST("", controllers.CreateWebhook)
+		webhooks.PUT("/:id", controllers.UpdateWebhook)
+		webhooks.DELETE("/:id", controllers.DeleteWebhook)
+		webhooks.GET("/:id/deliveries", controllers.GetWebhookDeliveries)
+		webhooks.POST("/:id/deliveries/:delivery_id/retry", controllers.RetryWebhookDelivery)
 	}
 
 	return router
@@ -0,0 +1,324 @@
+package scoring
+
+import (
+	"competition-app/models"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 4096
+
+	// sendBufferSize is how many messages may queue up for a client before it
+	// is considered too slow and disconnected
+	sendBufferSize = 256
+
+	// replayLimit caps how many missed updates are replayed individually on
+	// reconnect; clients further behind only receive the current standings
+	replayLimit = 100
+)
+
+// Client is a WebSocket connection to a competition's scoring channel. Only
+// connections authenticated as an official may submit results.
+type Client struct {
+	conn          *websocket.Conn
+	competitionID int
+	official      *models.Official
+	requestID     string
+	hub           *hub
+
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// incomingMessage is a message sent by a client
+type incomingMessage struct {
+	Type          string  `json:"type"`
+	ID            string  `json:"id"`
+	ParticipantID int     `json:"participant_id"`
+	TeamID        int     `json:"team_id"`
+	Score         float64 `json:"score"`
+	Notes         string  `json:"notes"`
+}
+
+// Serve runs a scoring connection until it is closed. lastSeq is the sequence
+// number of the last update the client received before reconnecting, or 0.
+func Serve(conn *websocket.Conn, competitionID int, official *models.Official, requestID string, lastSeq int64) {
+	client := &Client{
+		conn:          conn,
+		competitionID: competitionID,
+		official:      official,
+		requestID:     requestID,
+		send:          make(chan []byte, sendBufferSize),
+		done:          make(chan struct{}),
+	}
+
+	// Join before replaying so no update published in between is lost
+	h, err := join(client)
+	if err != nil {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "live scoring is unavailable"),
+			time.Now().Add(writeWait))
+		conn.Close()
+		return
+	}
+	client.hub = h
+	defer h.leave(client)
+
+	go client.writePump()
+
+	client.replay(lastSeq)
+	client.readPump()
+}
+
+// replay sends the updates missed since lastSeq followed by the current standings
+func (c *Client) replay(lastSeq int64) {
+	truncated := false
+	if lastSeq > 0 {
+		events, err := models.GetCompetitionEventsSince(c.competitionID, lastSeq, 1000)
+		if err == nil {
+			var missed []models.OutboxEvent
+			for _, event := range events {
+				if standingsEvents[event.Type] {
+					missed = append(missed, event)
+				}
+			}
+
+			if len(missed) > replayLimit {
+				truncated = true
+			} else {
+				for _, event := range missed {
+					c.queue(updateMessage(event, nil))
+				}
+			}
+		}
+	}
+
+	standings, err := models.GetStandings(c.competitionID)
+	if err != nil {
+		c.queue(errorMessage("", "Failed to load standings"))
+		return
+	}
+	teamStandings, err := models.GetTeamStandings(c.competitionID)
+	if err != nil {
+		c.queue(errorMessage("", "Failed to load standings"))
+		return
+	}
+	c.queue(map[string]interface{}{
+		"type":             "standings",
+		"standings":        standings,
+		"team_standings":   teamStandings,
+		"replay_truncated": truncated,
+	})
+}
+
+// readPump handles messages from the client until the connection fails
+func (c *Client) readPump() {
+	defer c.close()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var message incomingMessage
+		if err := c.conn.ReadJSON(&message); err != nil {
+			switch err.(type) {
+			case *json.SyntaxError, *json.UnmarshalTypeError:
+				c.queue(errorMessage("", "Invalid message format"))
+				continue
+			}
+			return
+		}
+
+		switch message.Type {
+		case "submit_result":
+			c.submitResult(message)
+		case "submit_team_result":
+			c.submitTeamResult(message)
+		default:
+			c.queue(errorMessage(message.ID, "Unknown message type"))
+		}
+	}
+}
+
+// submitResult records a result and acknowledges it to the submitting client.
+// The resulting standings reach every client through the hub.
+func (c *Client) submitResult(message incomingMessage) {
+	if c.official == nil || !c.official.CanScore(c.competitionID) {
+		c.queue(errorMessage(message.ID, "Only officials of this competition can submit results"))
+		return
+	}
+
+	if message.ParticipantID <= 0 {
+		c.queue(errorMessage(message.ID, "participant_id is required"))
+		return
+	}
+
+	if len(message.Notes) > 1000 {
+		c.queue(errorMessage(message.ID, "notes are too long (maximum 1000 characters)"))
+		return
+	}
+
+	result := models.Result{
+		CompetitionID: c.competitionID,
+		ParticipantID: message.ParticipantID,
+		Score:         message.Score,
+		Notes:         message.Notes,
+	}
+
+	meta := models.ChangeMeta{
+		Actor:     "official:" + strconv.Itoa(c.official.ID),
+		RequestID: c.requestID,
+	}
+
+	if err := models.RecordResult(meta, &result); err != nil {
+		c.queue(errorMessage(message.ID, err.Error()))
+		return
+	}
+
+	c.queue(map[string]interface{}{
+		"type":   "ack",
+		"id":     message.ID,
+		"result": result,
+	})
+}
+
+// submitTeamResult records a team's result and acknowledges it to the
+// submitting client
+func (c *Client) submitTeamResult(message incomingMessage) {
+	if c.official == nil || !c.official.CanScore(c.competitionID) {
+		c.queue(errorMessage(message.ID, "Only officials of this competition can submit results"))
+		return
+	}
+
+	if message.TeamID <= 0 {
+		c.queue(errorMessage(message.ID, "team_id is required"))
+		return
+	}
+
+	if len(message.Notes) > 1000 {
+		c.queue(errorMessage(message.ID, "notes are too long (maximum 1000 characters)"))
+		return
+	}
+
+	result := models.TeamResult{
+		CompetitionID: c.competitionID,
+		TeamID:        message.TeamID,
+		Score:         message.Score,
+		Notes:         message.Notes,
+	}
+
+	meta := models.ChangeMeta{
+		Actor:     "official:" + strconv.Itoa(c.official.ID),
+		RequestID: c.requestID,
+	}
+
+	if err := models.RecordTeamResult(meta, &result); err != nil {
+		c.queue(errorMessage(message.ID, err.Error()))
+		return
+	}
+
+	c.queue(map[string]interface{}{
+		"type":   "ack",
+		"id":     message.ID,
+		"result": result,
+	})
+}
+
+// writePump writes queued messages and keepalive pings to the connection
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close()
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// queue sends a message to this client from its own goroutine. Unlike hub
+// broadcasts it waits for room in the queue, since it only holds up this client.
+func (c *Client) queue(message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	select {
+	case c.send <- data:
+	case <-c.done:
+	}
+}
+
+// closeSlow disconnects a client that cannot keep up with broadcasts. The close
+// handshake runs in the background so the broadcaster is never held up.
+func (c *Client) closeSlow() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		go func() {
+			c.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "client too slow"),
+				time.Now().Add(writeWait))
+			c.conn.Close()
+		}()
+	})
+}
+
+// close shuts the connection down once
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// updateMessage builds the message announcing an event that affects standings
+func updateMessage(event models.OutboxEvent, standings []models.Standing) map[string]interface{} {
+	message := map[string]interface{}{
+		"type":  "update",
+		"seq":   event.ID,
+		"event": event.Type,
+		"data":  event.Data,
+	}
+	if standings != nil {
+		message["standings"] = standings
+	}
+	return message
+}
+
+// errorMessage builds an error reply, referencing the client message ID if any
+func errorMessage(id, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":  "error",
+		"id":    id,
+		"error": text,
+	}
+}
@@ -0,0 +1,134 @@
+package scoring
+
+import (
+	"competition-app/models"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Events that change a competition's standings
+var standingsEvents = map[string]bool{
+	models.EventResultRecorded:          true,
+	models.EventTeamResultRecorded:      true,
+	models.EventParticipantRegistered:   true,
+	models.EventParticipantUnregistered: true,
+}
+
+// hub fans out the scoring updates of one competition to the clients connected
+// to this backend instance. Updates from every instance arrive through Redis.
+type hub struct {
+	competitionID int
+	pubsub        *redis.PubSub
+
+	mu      sync.Mutex
+	clients map[*Client]bool
+}
+
+var (
+	hubsMu sync.Mutex
+	hubs   = map[int]*hub{}
+)
+
+// join adds a client to the hub of its competition, starting the hub if needed
+func join(client *Client) (*hub, error) {
+	hubsMu.Lock()
+	defer hubsMu.Unlock()
+
+	h, ok := hubs[client.competitionID]
+	if !ok {
+		pubsub, err := models.SubscribeCompetitionEvents(client.competitionID)
+		if err != nil {
+			return nil, err
+		}
+
+		h = &hub{
+			competitionID: client.competitionID,
+			pubsub:        pubsub,
+			clients:       map[*Client]bool{},
+		}
+		hubs[client.competitionID] = h
+		go h.run()
+	}
+
+	h.mu.Lock()
+	h.clients[client] = true
+	h.mu.Unlock()
+
+	return h, nil
+}
+
+// leave removes a client and stops the hub once its last client is gone
+func (h *hub) leave(client *Client) {
+	hubsMu.Lock()
+	defer hubsMu.Unlock()
+
+	h.mu.Lock()
+	delete(h.clients, client)
+	empty := len(h.clients) == 0
+	h.mu.Unlock()
+
+	if empty && hubs[h.competitionID] == h {
+		delete(hubs, h.competitionID)
+		h.pubsub.Close()
+	}
+}
+
+// run broadcasts standings updates until the hub's subscription is closed
+func (h *hub) run() {
+	for message := range h.pubsub.Channel() {
+		var event models.OutboxEvent
+		if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+			continue
+		}
+		if !standingsEvents[event.Type] {
+			continue
+		}
+
+		// Team results only change the team standings
+		if event.Type == models.EventTeamResultRecorded {
+			teamStandings, err := models.GetTeamStandings(h.competitionID)
+			if err != nil {
+				log.Printf("Warning: failed to compute team standings for competition %d: %v", h.competitionID, err)
+				continue
+			}
+
+			message := updateMessage(event, nil)
+			message["team_standings"] = teamStandings
+			if data, err := json.Marshal(message); err == nil {
+				h.broadcast(data)
+			}
+			continue
+		}
+
+		// Standings are computed once per instance, not once per client
+		standings, err := models.GetStandings(h.competitionID)
+		if err != nil {
+			log.Printf("Warning: failed to compute standings for competition %d: %v", h.competitionID, err)
+			continue
+		}
+
+		data, err := json.Marshal(updateMessage(event, standings))
+		if err != nil {
+			continue
+		}
+		h.broadcast(data)
+	}
+}
+
+// broadcast queues a message for every client without ever blocking on one.
+// Clients whose queue is full are disconnected and can catch up on reconnect.
+func (h *hub) broadcast(data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		select {
+		case client.send <- data:
+		default:
+			client.closeSlow()
+		}
+	}
+}
@@ -2,7 +2,10 @@ package validation
 
 import (
 	"errors"
+	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,12 +15,132 @@ type Competition struct {
 	Description string    
 	Date        time.Time 
 	Location    string    
+	MinTeamSize *int
+	MaxTeamSize *int
+	Discipline  string
+	Series      string
 }
 
 type Participant struct {
-	ID    int       
-	Name  string    
-	Email string    
+	ID            int       
+	Name          string    
+	Email         string    
+	Locale        string
+	DateOfBirth   *time.Time
+	Gender        string
+	Club          string
+	Nationality   string
+	Federation    string
+	LicenseNumber string
+}
+
+// Genders lists the gender categories a participant can compete in
+var Genders = []string{"female", "male", "other"}
+
+// licenseFormats maps each known federation to the format of its license numbers
+var licenseFormats = map[string]*regexp.Regexp{}
+
+// SetLicenseFormats configures the federations participants can hold a license
+// with, each with a regular expression its license numbers must match
+func SetLicenseFormats(formats map[string]string) error {
+	compiled := make(map[string]*regexp.Regexp, len(formats))
+	for federation, format := range formats {
+		re, err := regexp.Compile(format)
+		if err != nil {
+			return errors.New("invalid license format for federation " + federation + ": " + err.Error())
+		}
+		compiled[strings.ToUpper(federation)] = re
+	}
+	licenseFormats = compiled
+	return nil
+}
+
+type Category struct {
+	Name        string
+	Gender      string
+	MinAge      *int
+	MaxAge      *int
+	SkillLevels []string
+}
+
+type Event struct {
+	Name        string
+	StartsAt    *time.Time
+	EndsAt      *time.Time
+	Capacity    *int
+	ScoringType string
+}
+
+// ScoringTypes lists how an event's results are ranked: points and distances
+// rank the highest first, times the lowest first, and match events are decided
+// by head-to-head results
+var ScoringTypes = []string{"points", "time", "distance", "match"}
+
+type Team struct {
+	Name    string
+	Members []TeamMember
+}
+
+type TeamMember struct {
+	ParticipantID int
+	Role          string
+}
+
+// TeamRoles lists the roles a team member can have
+var TeamRoles = []string{"captain", "member", "reserve"}
+
+type Bracket struct {
+	Format string
+	Seeds  []int
+}
+
+// BracketFormats lists the supported knockout formats
+var BracketFormats = []string{"single_elimination", "double_elimination"}
+
+type RoundRobin struct {
+	Legs        int
+	PointsWin   float64
+	PointsDraw  float64
+	PointsLoss  float64
+	TieBreakers []string
+}
+
+// TieBreakers lists how participants level on points in a round robin are
+// separated: by the results among themselves, by score difference or by
+// score for
+var TieBreakers = []string{"head_to_head", "score_difference", "score_for"}
+
+type Seeding struct {
+	Sources               []string
+	Manual                []int
+	EventID               *int
+	PreviousCompetitionID *int
+	PreviousResultOrder   string
+}
+
+// SeedSources lists what participants can be seeded by: a manual order, their
+// rating, their entry time in an event or their result in a previous
+// competition
+var SeedSources = []string{"manual", "rating", "entry_time", "previous_result"}
+
+// SeedResultOrders lists whether higher or lower previous results seed higher
+var SeedResultOrders = []string{"highest_first", "lowest_first"}
+
+type Heats struct {
+	Round string
+	Lanes int
+	Top   *int
+}
+
+// HeatRounds lists the rounds a timed event can be seeded for
+var HeatRounds = []string{"prelim", "final"}
+
+// MaxLanes is the most lanes a pool or track can have
+const MaxLanes = 12
+
+type WebhookSubscription struct {
+	URL        string
+	EventTypes []string
 }
 
 // ParseDate parses a date string in format "YYYY-MM-DD"
@@ -47,6 +170,23 @@ func ValidateCompetition(c *Competition) error {
 		return errors.New("location is too long (maximum 255 characters)")
 	}
 
+	if (c.MinTeamSize != nil && *c.MinTeamSize < 1) || (c.MaxTeamSize != nil && *c.MaxTeamSize < 1) {
+		return errors.New("team sizes must be at least 1")
+	}
+
+	if c.MinTeamSize != nil && c.MaxTeamSize != nil && *c.MinTeamSize > *c.MaxTeamSize {
+		return errors.New("min_team_size cannot be greater than max_team_size")
+	}
+
+	disciplineRegex := regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+	if !disciplineRegex.MatchString(c.Discipline) {
+		return errors.New("discipline must be a lower-case name such as chess or table-tennis (maximum 50 characters)")
+	}
+
+	if len(c.Series) > 100 {
+		return errors.New("series is too long (maximum 100 characters)")
+	}
+
 	return nil
 }
 
@@ -70,5 +210,327 @@ func ValidateParticipant(p *Participant) error {
 		return errors.New("invalid email format")
 	}
 
+	// Locale is optional and defaults to English
+	localeRegex := regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+	if p.Locale != "" && !localeRegex.MatchString(p.Locale) {
+		return errors.New("invalid locale (expected a language code such as en or de-AT)")
+	}
+
+	// The remaining profile fields are optional
+	if p.DateOfBirth != nil {
+		if p.DateOfBirth.After(time.Now()) {
+			return errors.New("date of birth cannot be in the future")
+		}
+		if p.DateOfBirth.Year() < 1900 {
+			return errors.New("date of birth must not be before 1900")
+		}
+	}
+
+	if p.Gender != "" {
+		valid := false
+		for _, gender := range Genders {
+			valid = valid || p.Gender == gender
+		}
+		if !valid {
+			return errors.New("invalid gender (expected one of " + strings.Join(Genders, ", ") + ")")
+		}
+	}
+
+	if len(p.Club) > 255 {
+		return errors.New("club is too long (maximum 255 characters)")
+	}
+
+	if p.Nationality != "" && !countryCodes[strings.ToUpper(p.Nationality)] {
+		return errors.New("invalid nationality (expected an ISO 3166-1 alpha-2 country code such as DE)")
+	}
+
+	if p.LicenseNumber != "" && p.Federation == "" {
+		return errors.New("federation is required with a license number")
+	}
+
+	if p.Federation != "" {
+		format, ok := licenseFormats[strings.ToUpper(p.Federation)]
+		if !ok {
+			return errors.New("unknown federation: " + p.Federation)
+		}
+		if p.LicenseNumber != "" && !format.MatchString(p.LicenseNumber) {
+			return errors.New("invalid license number for federation " + strings.ToUpper(p.Federation))
+		}
+	}
+
+	return nil
+}
+
+// ValidateCategory validates competition category data
+func ValidateCategory(c *Category) error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if len(c.Name) > 100 {
+		return errors.New("name is too long (maximum 100 characters)")
+	}
+
+	// An empty gender makes the category open to everyone
+	if c.Gender != "" {
+		valid := false
+		for _, gender := range Genders {
+			valid = valid || c.Gender == gender
+		}
+		if !valid {
+			return errors.New("invalid gender (expected one of " + strings.Join(Genders, ", ") + ")")
+		}
+	}
+
+	if (c.MinAge != nil && *c.MinAge < 0) || (c.MaxAge != nil && *c.MaxAge < 0) {
+		return errors.New("ages cannot be negative")
+	}
+
+	if c.MinAge != nil && c.MaxAge != nil && *c.MinAge > *c.MaxAge {
+		return errors.New("min_age cannot be greater than max_age")
+	}
+
+	for _, level := range c.SkillLevels {
+		if level == "" || len(level) > 50 {
+			return errors.New("skill levels must be between 1 and 50 characters")
+		}
+	}
+
 	return nil
 }
+
+// ValidateEvent validates the data of an event within a competition
+func ValidateEvent(e *Event) error {
+	if e.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if len(e.Name) > 100 {
+		return errors.New("name is too long (maximum 100 characters)")
+	}
+
+	if e.EndsAt != nil && e.StartsAt == nil {
+		return errors.New("starts_at is required when ends_at is set")
+	}
+
+	if e.StartsAt != nil && e.EndsAt != nil && !e.EndsAt.After(*e.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+
+	if e.Capacity != nil && *e.Capacity < 1 {
+		return errors.New("capacity must be at least 1")
+	}
+
+	valid := false
+	for _, scoringType := range ScoringTypes {
+		valid = valid || e.ScoringType == scoringType
+	}
+	if !valid {
+		return errors.New("invalid scoring_type (expected one of " + strings.Join(ScoringTypes, ", ") + ")")
+	}
+
+	return nil
+}
+
+// ValidateTeam validates a team and its roster. Roster sizes depend on the
+// competition and are checked when the team is saved.
+func ValidateTeam(t *Team) error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if len(t.Name) > 100 {
+		return errors.New("name is too long (maximum 100 characters)")
+	}
+
+	seen := map[int]bool{}
+	captains := 0
+	for _, member := range t.Members {
+		if member.ParticipantID <= 0 {
+			return errors.New("members need a participant_id")
+		}
+		if seen[member.ParticipantID] {
+			return errors.New("participant listed more than once")
+		}
+		seen[member.ParticipantID] = true
+
+		valid := false
+		for _, role := range TeamRoles {
+			valid = valid || member.Role == role
+		}
+		if !valid {
+			return errors.New("invalid role (expected one of " + strings.Join(TeamRoles, ", ") + ")")
+		}
+		if member.Role == "captain" {
+			captains++
+		}
+	}
+
+	if captains > 1 {
+		return errors.New("a team can only have one captain")
+	}
+
+	return nil
+}
+
+// ValidateBracket validates a request to generate a knockout bracket
+func ValidateBracket(b *Bracket) error {
+	valid := false
+	for _, format := range BracketFormats {
+		valid = valid || b.Format == format
+	}
+	if !valid {
+		return errors.New("invalid format (expected one of " + strings.Join(BracketFormats, ", ") + ")")
+	}
+
+	for _, id := range b.Seeds {
+		if id <= 0 {
+			return errors.New("seeds must be participant IDs")
+		}
+	}
+
+	return nil
+}
+
+// ValidateRoundRobin validates the settings of a round-robin league
+func ValidateRoundRobin(r *RoundRobin) error {
+	if r.Legs != 1 && r.Legs != 2 {
+		return errors.New("legs must be 1 or 2")
+	}
+
+	if r.PointsWin < 0 || r.PointsDraw < 0 || r.PointsLoss < 0 {
+		return errors.New("points cannot be negative")
+	}
+	if r.PointsWin < r.PointsDraw || r.PointsDraw < r.PointsLoss {
+		return errors.New("points must not be lower for a win than a draw, or for a draw than a loss")
+	}
+
+	seen := map[string]bool{}
+	for _, tieBreaker := range r.TieBreakers {
+		valid := false
+		for _, known := range TieBreakers {
+			valid = valid || tieBreaker == known
+		}
+		if !valid {
+			return errors.New("invalid tie-breaker (expected one of " + strings.Join(TieBreakers, ", ") + ")")
+		}
+		if seen[tieBreaker] {
+			return errors.New("tie-breaker listed more than once")
+		}
+		seen[tieBreaker] = true
+	}
+
+	return nil
+}
+
+// ValidateSeeding validates a request to compute the seeds of a competition
+func ValidateSeeding(s *Seeding) error {
+	if len(s.Sources) == 0 {
+		return errors.New("at least one seeding source is required")
+	}
+
+	seen := map[string]bool{}
+	for _, source := range s.Sources {
+		valid := false
+		for _, known := range SeedSources {
+			valid = valid || source == known
+		}
+		if !valid {
+			return errors.New("invalid seeding source (expected one of " + strings.Join(SeedSources, ", ") + ")")
+		}
+		if seen[source] {
+			return errors.New("seeding source listed more than once")
+		}
+		seen[source] = true
+	}
+
+	if seen["manual"] && len(s.Manual) == 0 {
+		return errors.New("manual seeding requires a manual order")
+	}
+	if seen["entry_time"] && s.EventID == nil {
+		return errors.New("seeding by entry time requires an event_id")
+	}
+	if seen["previous_result"] && s.PreviousCompetitionID == nil {
+		return errors.New("seeding by previous result requires a previous_competition_id")
+	}
+
+	valid := false
+	for _, order := range SeedResultOrders {
+		valid = valid || s.PreviousResultOrder == order
+	}
+	if !valid {
+		return errors.New("invalid previous_result_order (expected one of " + strings.Join(SeedResultOrders, ", ") + ")")
+	}
+
+	manual := map[int]bool{}
+	for _, id := range s.Manual {
+		if id <= 0 {
+			return errors.New("manual order must be participant IDs")
+		}
+		if manual[id] {
+			return errors.New("participant seeded more than once")
+		}
+		manual[id] = true
+	}
+
+	return nil
+}
+
+// ValidateHeats validates a request to seed a round of a timed event
+func ValidateHeats(h *Heats) error {
+	if err := ValidateHeatRound(h.Round); err != nil {
+		return err
+	}
+
+	if h.Lanes < 1 || h.Lanes > MaxLanes {
+		return errors.New("lanes must be between 1 and " + strconv.Itoa(MaxLanes))
+	}
+
+	if h.Top != nil && *h.Top < 1 {
+		return errors.New("top must be at least 1")
+	}
+
+	return nil
+}
+
+// ValidateHeatRound checks the name of a round of a timed event
+func ValidateHeatRound(round string) error {
+	for _, known := range HeatRounds {
+		if round == known {
+			return nil
+		}
+	}
+	return errors.New("invalid round (expected one of " + strings.Join(HeatRounds, ", ") + ")")
+}
+
+// ValidateRaceTime checks an entry or race time in seconds, which may be absent
+func ValidateRaceTime(t *float64) error {
+	if t != nil && (*t <= 0 || *t >= 100000) {
+		return errors.New("times must be a positive number of seconds")
+	}
+	return nil
+}
+
+// ValidateWebhookSubscription validates webhook subscription data
+func ValidateWebhookSubscription(w *WebhookSubscription) error {
+	if w.URL == "" {
+		return errors.New("url is required")
+	}
+
+	if len(w.URL) > 2048 {
+		return errors.New("url is too long (maximum 2048 characters)")
+	}
+
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+
+	for _, eventType := range w.EventTypes {
+		if eventType == "" {
+			return errors.New("event types must not be empty")
+		}
+	}
+
+	return nil
+}
\ No newline at end of file
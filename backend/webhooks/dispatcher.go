@@ -0,0 +1,143 @@
+package webhooks
+
+import (
+	"bytes"
+	"competition-app/config"
+	"competition-app/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const batchSize = 100
+
+// Dispatcher moves domain events from the outbox to webhook subscribers
+type Dispatcher struct {
+	client       *http.Client
+	pollInterval time.Duration
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+}
+
+// NewDispatcher creates a dispatcher using the webhook settings from the config
+func NewDispatcher(cfg *config.Config) *Dispatcher {
+	return &Dispatcher{
+		client:       &http.Client{Timeout: cfg.WebhookTimeout},
+		pollInterval: cfg.WebhookPollInterval,
+		maxAttempts:  cfg.WebhookMaxAttempts,
+		baseBackoff:  cfg.WebhookBaseBackoff,
+		maxBackoff:   6 * time.Hour,
+	}
+}
+
+// Run polls the outbox and the delivery queue until the process exits
+func (d *Dispatcher) Run() {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := models.FanOutOutboxEvents(batchSize); err != nil {
+			log.Printf("Warning: failed to fan out outbox events: %v", err)
+		}
+
+		// Deliveries are claimed one at a time and stay claimed for a little
+		// longer than a request can take, so the lease never runs out while
+		// earlier deliveries are still being sent
+		for i := 0; i < batchSize; i++ {
+			deliveries, err := models.ClaimDueDeliveries(1, 2*d.client.Timeout)
+			if err != nil {
+				log.Printf("Warning: failed to claim webhook deliveries: %v", err)
+				break
+			}
+			if len(deliveries) == 0 {
+				break
+			}
+			d.deliver(deliveries[0])
+		}
+	}
+}
+
+// deliver sends one event to a subscriber and records the outcome
+func (d *Dispatcher) deliver(delivery models.DueDelivery) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		log.Printf("Warning: failed to encode event %d: %v", delivery.Event.ID, err)
+		return
+	}
+
+	statusCode, err := d.send(delivery, body)
+	if err == nil {
+		if err := models.MarkDeliverySucceeded(delivery.ID, delivery.Claim, statusCode); err != nil {
+			log.Printf("Warning: failed to record webhook delivery %d: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	var retryAfter time.Duration
+	if delivery.Attempts+1 < d.maxAttempts {
+		retryAfter = d.backoff(delivery.Attempts + 1)
+	}
+
+	if err := models.MarkDeliveryFailed(delivery.ID, delivery.Claim, statusCode, err.Error(), retryAfter); err != nil {
+		log.Printf("Warning: failed to record webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send posts the signed event body and treats any non-2xx response as a failure
+func (d *Dispatcher) send(delivery models.DueDelivery, body []byte) (int, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "competition-app-webhooks")
+	req.Header.Set("X-Webhook-Event", delivery.Event.Type)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the exponential delay before the given retry attempt
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.baseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= d.maxBackoff {
+			return d.maxBackoff
+		}
+	}
+	return delay
+}
+
+// Sign computes the hex HMAC-SHA256 signature of a webhook body. The timestamp
+// is signed along with the body so receivers can reject replayed requests.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -1,5 +1,31 @@
 -- Drop existing tables if they exist
+DROP TABLE IF EXISTS used_tokens;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS results;
+DROP TABLE IF EXISTS officials;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+DROP TABLE IF EXISTS outbox_events;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS records;
+DROP TABLE IF EXISTS personal_bests;
+DROP TABLE IF EXISTS performances;
+DROP TABLE IF EXISTS rating_history;
+DROP TABLE IF EXISTS ratings;
+DROP TABLE IF EXISTS matches;
+DROP TABLE IF EXISTS round_robins;
+DROP TABLE IF EXISTS brackets;
+DROP TABLE IF EXISTS team_results;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
+DROP TABLE IF EXISTS heat_lanes;
+DROP TABLE IF EXISTS heat_rounds;
+DROP TABLE IF EXISTS seedings;
+DROP TABLE IF EXISTS event_participants;
+DROP TABLE IF EXISTS events;
 DROP TABLE IF EXISTS competition_participants;
+DROP TABLE IF EXISTS categories;
 DROP TABLE IF EXISTS participants;
 DROP TABLE IF EXISTS competitions;
 
@@ -10,34 +36,497 @@ CREATE TABLE competitions (
     description TEXT,
     date DATE NOT NULL,
     location VARCHAR(255) NOT NULL,
+    min_team_size INTEGER,
+    max_team_size INTEGER,
+    discipline VARCHAR(50) NOT NULL DEFAULT 'general',
+    series VARCHAR(100) NOT NULL DEFAULT '',
+    version INTEGER NOT NULL DEFAULT 1,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
 );
 
 CREATE TABLE participants (
     id SERIAL PRIMARY KEY,
     name VARCHAR(255) NOT NULL,
-    email VARCHAR(255) NOT NULL UNIQUE,
+    email VARCHAR(255) NOT NULL,
+    locale VARCHAR(10) NOT NULL DEFAULT 'en',
+    date_of_birth DATE,
+    gender VARCHAR(10) NOT NULL DEFAULT '',
+    club VARCHAR(255) NOT NULL DEFAULT '',
+    nationality VARCHAR(2) NOT NULL DEFAULT '',
+    federation VARCHAR(50) NOT NULL DEFAULT '',
+    license_number VARCHAR(50) NOT NULL DEFAULT '',
+    calendar_token_hash CHAR(64) UNIQUE,
+    version INTEGER NOT NULL DEFAULT 1,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
 );
 
+-- Emails only need to be unique among participants that are not in the trash,
+-- regardless of case
+CREATE UNIQUE INDEX idx_participants_email_active ON participants (LOWER(email)) WHERE deleted_at IS NULL;
+
+-- A federation license belongs to a single active participant
+CREATE UNIQUE INDEX idx_participants_license_active ON participants (federation, license_number)
+    WHERE license_number != '' AND deleted_at IS NULL;
+CREATE INDEX idx_participants_club ON participants (LOWER(club));
+CREATE INDEX idx_participants_nationality ON participants (nationality);
+
+-- Categories divide a competition, e.g. into age groups. Empty rules match
+-- everyone; registrations go to the first matching category by position.
+CREATE TABLE categories (
+    id SERIAL PRIMARY KEY,
+    competition_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    gender VARCHAR(10) NOT NULL DEFAULT '',
+    min_age INTEGER,
+    max_age INTEGER,
+    age_reference_date DATE,
+    skill_levels TEXT[] NOT NULL DEFAULT '{}',
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_categories_name ON categories (competition_id, LOWER(name));
+
 CREATE TABLE competition_participants (
     competition_id INTEGER NOT NULL,
     participant_id INTEGER NOT NULL,
     registration_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    category_id INTEGER,
+    skill_level VARCHAR(50) NOT NULL DEFAULT '',
+    seed INTEGER,
+    -- Counts removals so calendar feeds can keep their SEQUENCE increasing
+    -- across withdrawals and re-registrations
+    withdrawals INTEGER NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
     PRIMARY KEY (competition_id, participant_id),
     FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE,
+    FOREIGN KEY (participant_id) REFERENCES participants(id) ON DELETE CASCADE,
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL
+);
+
+-- Events are the disciplines of a competition, e.g. the 100m and the long jump
+CREATE TABLE events (
+    id SERIAL PRIMARY KEY,
+    competition_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    capacity INTEGER,
+    scoring_type VARCHAR(20) NOT NULL DEFAULT 'points',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (id, competition_id),
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_events_name ON events (competition_id, LOWER(name));
+
+-- Event entries belong to the competition registration, which stays the parent entry
+CREATE TABLE event_participants (
+    event_id INTEGER NOT NULL,
+    competition_id INTEGER NOT NULL,
+    participant_id INTEGER NOT NULL,
+    entry_time NUMERIC(10, 2),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, participant_id),
+    FOREIGN KEY (event_id, competition_id) REFERENCES events(id, competition_id) ON DELETE CASCADE,
+    FOREIGN KEY (competition_id, participant_id) REFERENCES competition_participants(competition_id, participant_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_event_participants_registration ON event_participants (competition_id, participant_id);
+
+-- How the seeds on competition_participants were computed. Sources apply in
+-- order: 'manual', 'rating', 'entry_time' or 'previous_result'.
+CREATE TABLE seedings (
+    competition_id INTEGER PRIMARY KEY,
+    sources TEXT[] NOT NULL,
+    manual INTEGER[] NOT NULL DEFAULT '{}',
+    event_id INTEGER,
+    previous_competition_id INTEGER,
+    previous_result_order VARCHAR(20) NOT NULL DEFAULT 'highest_first',
+    locked BOOLEAN NOT NULL DEFAULT FALSE,
+    computed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE SET NULL,
+    FOREIGN KEY (previous_competition_id) REFERENCES competitions(id) ON DELETE SET NULL
+);
+
+-- Seeded rounds of timed events: 'prelim' or 'final'
+CREATE TABLE heat_rounds (
+    event_id INTEGER NOT NULL,
+    competition_id INTEGER NOT NULL,
+    round VARCHAR(20) NOT NULL,
+    lanes SMALLINT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, round),
+    FOREIGN KEY (event_id, competition_id) REFERENCES events(id, competition_id) ON DELETE CASCADE
+);
+
+-- Lanes are unique per heat at commit, so two participants can swap places
+CREATE TABLE heat_lanes (
+    event_id INTEGER NOT NULL,
+    competition_id INTEGER NOT NULL,
+    round VARCHAR(20) NOT NULL,
+    heat SMALLINT NOT NULL,
+    lane SMALLINT NOT NULL,
+    participant_id INTEGER NOT NULL,
+    seed_time NUMERIC(10, 2),
+    time NUMERIC(10, 2),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, round, participant_id),
+    UNIQUE (event_id, round, heat, lane) DEFERRABLE INITIALLY DEFERRED,
+    FOREIGN KEY (event_id, round) REFERENCES heat_rounds(event_id, round) ON DELETE CASCADE,
+    FOREIGN KEY (event_id, participant_id) REFERENCES event_participants(event_id, participant_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_heat_lanes_participant ON heat_lanes (competition_id, participant_id);
+
+CREATE TABLE teams (
+    id SERIAL PRIMARY KEY,
+    competition_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (id, competition_id),
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_teams_name ON teams (competition_id, LOWER(name));
+
+-- Team members must be registered for the competition and can only be on one of its teams
+CREATE TABLE team_members (
+    team_id INTEGER NOT NULL,
+    competition_id INTEGER NOT NULL,
+    participant_id INTEGER NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    PRIMARY KEY (team_id, participant_id),
+    UNIQUE (competition_id, participant_id),
+    FOREIGN KEY (team_id, competition_id) REFERENCES teams(id, competition_id) ON DELETE CASCADE,
+    FOREIGN KEY (competition_id, participant_id) REFERENCES competition_participants(competition_id, participant_id) ON DELETE CASCADE
+);
+
+CREATE TABLE team_results (
+    id SERIAL PRIMARY KEY,
+    competition_id INTEGER NOT NULL,
+    team_id INTEGER NOT NULL UNIQUE,
+    score NUMERIC(12, 3) NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    recorded_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (team_id, competition_id) REFERENCES teams(id, competition_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_team_results_competition ON team_results (competition_id);
+
+CREATE TABLE brackets (
+    competition_id INTEGER PRIMARY KEY,
+    format VARCHAR(20) NOT NULL,
+    size INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE
+);
+
+CREATE TABLE round_robins (
+    competition_id INTEGER PRIMARY KEY,
+    legs SMALLINT NOT NULL DEFAULT 1,
+    points_win NUMERIC(6, 2) NOT NULL DEFAULT 3,
+    points_draw NUMERIC(6, 2) NOT NULL DEFAULT 1,
+    points_loss NUMERIC(6, 2) NOT NULL DEFAULT 0,
+    tie_breakers TEXT[] NOT NULL DEFAULT '{head_to_head,score_difference}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE
+);
+
+-- Head-to-head matches. Bracket matches link to the matches their winner and
+-- loser move on to; slots are 1 or 2.
+CREATE TABLE matches (
+    id SERIAL PRIMARY KEY,
+    competition_id INTEGER NOT NULL,
+    stage VARCHAR(20) NOT NULL,
+    round INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    participant1_id INTEGER,
+    participant2_id INTEGER,
+    score1 NUMERIC(12, 3),
+    score2 NUMERIC(12, 3),
+    winner_id INTEGER,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    next_match_id INTEGER,
+    next_slot SMALLINT,
+    loser_match_id INTEGER,
+    loser_slot SMALLINT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (competition_id, stage, round, position),
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE,
+    FOREIGN KEY (participant1_id) REFERENCES participants(id) ON DELETE SET NULL,
+    FOREIGN KEY (participant2_id) REFERENCES participants(id) ON DELETE SET NULL,
+    FOREIGN KEY (winner_id) REFERENCES participants(id) ON DELETE SET NULL,
+    FOREIGN KEY (next_match_id) REFERENCES matches(id) ON DELETE SET NULL,
+    FOREIGN KEY (loser_match_id) REFERENCES matches(id) ON DELETE SET NULL
+);
+
+-- Ratings are rebuilt from the completed matches of a discipline's
+-- competitions whenever a result changes; system is "elo" or "glicko2"
+CREATE TABLE ratings (
+    participant_id INTEGER NOT NULL,
+    discipline VARCHAR(50) NOT NULL,
+    system VARCHAR(20) NOT NULL,
+    rating NUMERIC(8, 2) NOT NULL,
+    deviation NUMERIC(8, 2),
+    volatility NUMERIC(10, 6),
+    games INTEGER NOT NULL DEFAULT 0,
+    wins INTEGER NOT NULL DEFAULT 0,
+    draws INTEGER NOT NULL DEFAULT 0,
+    losses INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (participant_id, discipline),
     FOREIGN KEY (participant_id) REFERENCES participants(id) ON DELETE CASCADE
 );
 
+-- Kept at full precision, so replaying from any game continues exactly where
+-- the history leaves off. Rows of discarded matches stay until the replay that
+-- follows removes them, so it knows whose ratings to rewind.
+CREATE TABLE rating_history (
+    id BIGSERIAL PRIMARY KEY,
+    participant_id INTEGER NOT NULL,
+    discipline VARCHAR(50) NOT NULL,
+    sequence INTEGER NOT NULL,
+    match_id INTEGER,
+    competition_id INTEGER NOT NULL,
+    opponent_id INTEGER NOT NULL,
+    score NUMERIC(3, 2) NOT NULL,
+    rating_before DOUBLE PRECISION NOT NULL,
+    rating_after DOUBLE PRECISION NOT NULL,
+    deviation_before DOUBLE PRECISION,
+    deviation_after DOUBLE PRECISION,
+    volatility_after DOUBLE PRECISION,
+    played_on DATE NOT NULL,
+    FOREIGN KEY (participant_id) REFERENCES participants(id) ON DELETE CASCADE,
+    FOREIGN KEY (opponent_id) REFERENCES participants(id) ON DELETE CASCADE,
+    FOREIGN KEY (match_id) REFERENCES matches(id) ON DELETE SET NULL,
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_rating_history_participant ON rating_history (participant_id, discipline, sequence);
+CREATE INDEX idx_rating_history_discipline ON rating_history (discipline, played_on, competition_id);
+
+-- Every mark in a discipline: competition scores (event '') and times swum
+-- in heats (event is the lower-case event name), with the venue and series
+-- they count for. Replayed with the personal bests and records from the
+-- competition whose marks changed on. Rows of deleted events stay until that
+-- replay removes them, so it knows which bests to rewind.
+CREATE TABLE performances (
+    id BIGSERIAL PRIMARY KEY,
+    participant_id INTEGER NOT NULL,
+    discipline VARCHAR(50) NOT NULL,
+    event VARCHAR(100) NOT NULL DEFAULT '',
+    sequence INTEGER NOT NULL,
+    competition_id INTEGER NOT NULL,
+    event_id INTEGER,
+    round VARCHAR(20) NOT NULL DEFAULT '',
+    mark NUMERIC(12, 3) NOT NULL,
+    lower_is_better BOOLEAN NOT NULL,
+    achieved_on DATE NOT NULL,
+    venue_key VARCHAR(255) NOT NULL DEFAULT '',
+    series_key VARCHAR(100) NOT NULL DEFAULT '',
+    personal_best BOOLEAN NOT NULL DEFAULT FALSE,
+    venue_record BOOLEAN NOT NULL DEFAULT FALSE,
+    series_record BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (participant_id) REFERENCES participants(id) ON DELETE CASCADE,
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_performances_participant ON performances (participant_id, discipline, event, sequence);
+CREATE INDEX idx_performances_discipline ON performances (discipline, achieved_on, competition_id);
+CREATE INDEX idx_performances_venue ON performances (discipline, venue_key);
+CREATE INDEX idx_performances_series ON performances (discipline, series_key);
+CREATE INDEX idx_performances_competition ON performances (competition_id, participant_id);
+
+CREATE TABLE personal_bests (
+    participant_id INTEGER NOT NULL,
+    discipline VARCHAR(50) NOT NULL,
+    event VARCHAR(100) NOT NULL DEFAULT '',
+    mark NUMERIC(12, 3) NOT NULL,
+    lower_is_better BOOLEAN NOT NULL,
+    competition_id INTEGER NOT NULL,
+    achieved_on DATE NOT NULL,
+    PRIMARY KEY (participant_id, discipline, event),
+    FOREIGN KEY (participant_id) REFERENCES participants(id) ON DELETE CASCADE,
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_personal_bests_discipline ON personal_bests (discipline);
+
+-- Best marks per venue (the competition location) and per competition series,
+-- matched case-insensitively through scope_key
+CREATE TABLE records (
+    scope VARCHAR(20) NOT NULL,
+    scope_key VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    discipline VARCHAR(50) NOT NULL,
+    event VARCHAR(100) NOT NULL DEFAULT '',
+    mark NUMERIC(12, 3) NOT NULL,
+    lower_is_better BOOLEAN NOT NULL,
+    participant_id INTEGER NOT NULL,
+    competition_id INTEGER NOT NULL,
+    achieved_on DATE NOT NULL,
+    PRIMARY KEY (scope, scope_key, discipline, event),
+    FOREIGN KEY (participant_id) REFERENCES participants(id) ON DELETE CASCADE,
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_records_discipline ON records (discipline);
+
+CREATE TABLE idempotency_keys (
+    key VARCHAR(255) NOT NULL,
+    scope VARCHAR(255) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER,
+    content_type VARCHAR(255),
+    response_body BYTEA,
+    -- Responses carrying credentials are not stored, only that they were sent
+    replayable BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (key, scope, method, path)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(64) NOT NULL,
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id VARCHAR(64) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_log_entity ON audit_log (entity_type, entity_id);
+CREATE INDEX idx_audit_log_actor ON audit_log (actor);
+CREATE INDEX idx_audit_log_created_at ON audit_log (created_at);
+
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(100) NOT NULL,
+    competition_id INTEGER NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    dispatched_at TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_events_pending ON outbox_events (id) WHERE dispatched_at IS NULL;
+CREATE INDEX idx_outbox_events_competition ON outbox_events (competition_id, id);
+
+CREATE TABLE webhook_subscriptions (
+    id SERIAL PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL,
+    event_id BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    -- Counts claims, so a dispatcher whose lease ran out cannot record the
+    -- outcome of an attempt another dispatcher has taken over
+    claims INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_status_code INTEGER,
+    last_error TEXT,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (subscription_id, event_id),
+    FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    FOREIGN KEY (event_id) REFERENCES outbox_events(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE officials (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    competition_id INTEGER,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE
+);
+
+CREATE TABLE results (
+    id SERIAL PRIMARY KEY,
+    competition_id INTEGER NOT NULL,
+    participant_id INTEGER NOT NULL,
+    score NUMERIC(12, 3) NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    recorded_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (competition_id, participant_id),
+    FOREIGN KEY (competition_id) REFERENCES competitions(id) ON DELETE CASCADE,
+    FOREIGN KEY (participant_id) REFERENCES participants(id) ON DELETE CASCADE
+);
+
+CREATE TABLE notifications (
+    id BIGSERIAL PRIMARY KEY,
+    participant_id INTEGER,
+    recipient VARCHAR(255) NOT NULL,
+    recipient_name VARCHAR(255) NOT NULL,
+    locale VARCHAR(10) NOT NULL,
+    template VARCHAR(50) NOT NULL,
+    data JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    -- Counts claims, like webhook_deliveries.claims
+    claims INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (participant_id) REFERENCES participants(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_notifications_due ON notifications (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE used_tokens (
+    nonce VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_used_tokens_expires_at ON used_tokens (expires_at);
+
 -- Insert sample data
-INSERT INTO competitions (name, description, date, location) VALUES
-('Summer Athletics Championship', 'Annual athletics event featuring track and field competitions.', '2025-07-15', 'Central Stadium'),
-('Winter Swimming Tournament', 'Indoor swimming competition for all age categories.', '2025-12-10', 'Aquatic Center'),
-('Chess Masters Championship', 'International chess tournament for professional players.', '2025-09-05', 'Grand Hotel Conference Hall');
+INSERT INTO competitions (name, description, date, location, discipline) VALUES
+('Summer Athletics Championship', 'Annual athletics event featuring track and field competitions.', '2025-07-15', 'Central Stadium', 'athletics'),
+('Winter Swimming Tournament', 'Indoor swimming competition for all age categories.', '2025-12-10', 'Aquatic Center', 'swimming'),
+('Chess Masters Championship', 'International chess tournament for professional players.', '2025-09-05', 'Grand Hotel Conference Hall', 'chess');
 
 -- Insert participants
 INSERT INTO participants (name, email) VALUES
@@ -60,3 +549,18 @@ INSERT INTO competition_participants (competition_id, participant_id, registrati
 (1, 3, '2025-05-12'),
 (2, 1, '2025-11-01'),
 (3, 2, '2025-08-10');
+
+-- Insert events
+INSERT INTO events (competition_id, name, starts_at, ends_at, capacity, scoring_type) VALUES
+(1, '100m', '2025-07-15 10:00', '2025-07-15 11:00', 8, 'time'),
+(1, 'Long Jump', '2025-07-15 11:30', '2025-07-15 13:00', 12, 'distance'),
+(1, '4x100m Relay', '2025-07-15 15:00', '2025-07-15 15:30', NULL, 'time');
+
+-- Insert event entries
+INSERT INTO event_participants (event_id, competition_id, participant_id, entry_time) VALUES
+(1, 1, 1, 11.42),
+(1, 1, 2, 10.98),
+(2, 1, 2, NULL),
+(2, 1, 3, NULL),
+(3, 1, 1, NULL),
+(3, 1, 3, NULL);
@@ -3,6 +3,7 @@ import CompetitionsList from "./pages/CompetitionsList";
 import CompetitionDetails from "./pages/CompetitionDetails";
 import CompetitionForm from "./pages/CompetitionForm";
 import ParticipantForm from "./pages/ParticipantForm";
+import RedeemLink from "./pages/RedeemLink";
 import "./App.css";
 
 function App() {
@@ -15,6 +16,8 @@ function App() {
       <Route path="/competitions/:id/edit" element={<CompetitionForm />} />
       <Route path="/participants/new" element={<ParticipantForm />} />
       <Route path="/participants/:id/edit" element={<ParticipantForm />} />
+      <Route path="/signup/confirm" element={<RedeemLink kind="signup" />} />
+      <Route path="/sign-in" element={<RedeemLink kind="sign-in" />} />
     </Routes>
   );
 }
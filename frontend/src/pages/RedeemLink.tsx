@@ -0,0 +1,60 @@
+import { useEffect, useState } from "react";
+import { Link, useSearchParams } from "react-router-dom";
+import { SelfServiceAPI } from "../services/api";
+
+interface RedeemLinkProps {
+  kind: "signup" | "sign-in";
+}
+
+// RedeemLink exchanges the token from an emailed link for a participant session
+export default function RedeemLink({ kind }: RedeemLinkProps) {
+  const [searchParams] = useSearchParams();
+  const [status, setStatus] = useState<"loading" | "done" | "failed">(
+    "loading"
+  );
+  const [name, setName] = useState("");
+
+  useEffect(() => {
+    const token = searchParams.get("token");
+    if (!token) {
+      setStatus("failed");
+      return;
+    }
+
+    const redeem =
+      kind === "signup"
+        ? SelfServiceAPI.confirmSignup(token)
+        : SelfServiceAPI.verifyMagicLink(token);
+
+    redeem
+      .then((response) => {
+        localStorage.setItem("session_token", response.data.session_token);
+        setName(response.data.participant.name);
+        setStatus("done");
+      })
+      .catch((err) => {
+        console.error("Error redeeming link:", err);
+        setStatus("failed");
+      });
+  }, [kind, searchParams]);
+
+  return (
+    <div className="redeem-link">
+      {status === "loading" && <div>Loading...</div>}
+      {status === "done" && (
+        <>
+          <h1>
+            {kind === "signup" ? "Registration confirmed" : "Signed in"}
+          </h1>
+          <p>Welcome, {name}!</p>
+        </>
+      )}
+      {status === "failed" && (
+        <div className="error-message">
+          This link is invalid, has expired or has already been used.
+        </div>
+      )}
+      <Link to="/competitions">Back to competitions</Link>
+    </div>
+  );
+}
@@ -13,6 +13,19 @@ const api = axios.create({
   },
 });
 
+// Tag mutating requests with an Idempotency-Key so retries are not applied twice
+api.interceptors.request.use((config) => {
+  const method = config.method?.toLowerCase();
+  if (
+    method &&
+    ["post", "put", "patch", "delete"].includes(method) &&
+    !config.headers["Idempotency-Key"]
+  ) {
+    config.headers["Idempotency-Key"] = crypto.randomUUID();
+  }
+  return config;
+});
+
 export const CompetitionAPI = {
   getAll: () => api.get<Competition[]>("/competitions"),
   getById: (id: number) => api.get<Competition>(`/competitions/${id}`),
@@ -42,3 +55,16 @@ export const ParticipantAPI = {
     api.delete(`/participants/${id}/competitions/${competitionId}`),
   delete: (id: number) => api.delete(`/participants/${id}`),
 };
+
+export interface SessionResponse {
+  participant: Participant;
+  session_token: string;
+  expires_at: string;
+}
+
+export const SelfServiceAPI = {
+  confirmSignup: (token: string) =>
+    api.post<SessionResponse>("/signup/confirm", { token }),
+  verifyMagicLink: (token: string) =>
+    api.post<SessionResponse>("/auth/magic-link/verify", { token }),
+};
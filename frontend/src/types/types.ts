@@ -4,14 +4,67 @@ export interface Competition {
   description: string;
   date: string;
   location: string;
+  min_team_size?: number | null;
+  max_team_size?: number | null;
+  discipline?: string;
+  series?: string;
+  version?: number;
   created_at?: string;
   updated_at?: string;
+  events?: CompetitionEvent[];
+}
+
+export interface CompetitionEvent {
+  id: number;
+  competition_id: number;
+  name: string;
+  starts_at?: string | null;
+  ends_at?: string | null;
+  capacity?: number | null;
+  scoring_type: "points" | "time" | "distance" | "match";
+  created_at?: string;
+  updated_at?: string;
+}
+
+export interface HeatLane {
+  heat: number;
+  lane: number;
+  participant_id: number;
+  name: string;
+  club: string;
+  nationality: string;
+  seed_time: number | null;
+  time: number | null;
+  personal_best?: boolean;
+  venue_record?: boolean;
+  series_record?: boolean;
+}
+
+export interface HeatRound {
+  event_id: number;
+  competition_id: number;
+  round: "prelim" | "final";
+  lanes: number;
+  heats: {
+    heat: number;
+    lanes: HeatLane[];
+  }[];
+  created_at: string;
+  updated_at: string;
 }
 
 export interface Participant {
   id: number;
   name: string;
   email: string;
+  locale?: string;
+  date_of_birth?: string | null;
+  gender?: "" | "female" | "male" | "other";
+  club?: string;
+  nationality?: string;
+  federation?: string;
+  license_number?: string;
+  version?: number;
   created_at?: string;
   updated_at?: string;
 }
@@ -25,8 +78,230 @@ export interface CompetitionParticipant {
   competition_id: number;
   participant_id: number;
   registration_date: string;
+  category_id?: number | null;
+  skill_level?: string;
+  seed?: number | null;
+  created_at?: string;
+  updated_at?: string;
+}
+
+export interface Category {
+  id: number;
+  competition_id: number;
+  name: string;
+  gender?: "" | "female" | "male" | "other";
+  min_age?: number | null;
+  max_age?: number | null;
+  age_reference_date?: string | null;
+  skill_levels?: string[];
+  position?: number;
+  participant_count?: number;
   created_at?: string;
   updated_at?: string;
 }
 
+export interface TeamMember {
+  participant_id: number;
+  name?: string;
+  role: "captain" | "member" | "reserve";
+}
+
+export interface Team {
+  id: number;
+  competition_id: number;
+  name: string;
+  members: TeamMember[];
+  created_at?: string;
+  updated_at?: string;
+}
+
+export interface TeamStanding {
+  rank: number;
+  team_id: number;
+  name: string;
+  score: number;
+}
+
+export interface Match {
+  id: number;
+  competition_id: number;
+  stage: string;
+  round: number;
+  position: number;
+  participant1_id: number | null;
+  participant1_name?: string;
+  participant2_id: number | null;
+  participant2_name?: string;
+  score1: number | null;
+  score2: number | null;
+  winner_id: number | null;
+  status: "pending" | "ready" | "completed" | "bye" | "skipped";
+  next_match_id: number | null;
+  next_slot?: 1 | 2;
+  loser_match_id: number | null;
+  loser_slot?: 1 | 2;
+}
+
+export interface Bracket {
+  competition_id: number;
+  format: "single_elimination" | "double_elimination";
+  size: number;
+  champion_id: number | null;
+  stages: {
+    name: "winners" | "losers" | "grand_final";
+    rounds: { round: number; matches: Match[] }[];
+  }[];
+  created_at: string;
+}
+
+export interface SwissRound {
+  round: number;
+  games: Match[];
+}
+
+export interface SwissStanding {
+  rank: number;
+  participant_id: number;
+  name: string;
+  points: number;
+  buchholz: number;
+  sonneborn_berger: number;
+  games: number;
+  wins: number;
+  draws: number;
+  losses: number;
+  color_balance: number;
+  had_bye: boolean;
+}
+
+export interface RoundRobinRound {
+  round: number;
+  matches: Match[];
+}
+
+export type TieBreaker = "head_to_head" | "score_difference" | "score_for";
+
+export interface RoundRobin {
+  competition_id: number;
+  legs: 1 | 2;
+  points_win: number;
+  points_draw: number;
+  points_loss: number;
+  tie_breakers: TieBreaker[];
+  created_at: string;
+  updated_at: string;
+  rounds: RoundRobinRound[];
+}
+
+export interface LeagueStanding {
+  rank: number;
+  participant_id: number;
+  name: string;
+  played: number;
+  wins: number;
+  draws: number;
+  losses: number;
+  score_for: number;
+  score_against: number;
+  score_difference: number;
+  points: number;
+}
+
+export interface RatingChange {
+  match_id: number;
+  competition_id: number;
+  competition_name: string;
+  played_on: string;
+  opponent_id: number;
+  opponent_name: string;
+  score: number;
+  rating_before: number;
+  rating_after: number;
+  deviation_before?: number;
+  deviation_after?: number;
+}
+
+export interface Rating {
+  participant_id: number;
+  discipline: string;
+  system: "elo" | "glicko2";
+  rating: number;
+  deviation?: number;
+  volatility?: number;
+  games: number;
+  wins: number;
+  draws: number;
+  losses: number;
+  updated_at: string;
+  history: RatingChange[];
+}
+
+export type SeedSource = "manual" | "rating" | "entry_time" | "previous_result";
+
+export interface SeededParticipant {
+  seed: number | null;
+  participant_id: number;
+  name: string;
+  club: string;
+  manual_position?: number;
+  rating?: number;
+  entry_time?: number;
+  previous_result?: number;
+}
+
+export interface Seeding {
+  competition_id: number;
+  sources: SeedSource[];
+  manual: number[];
+  event_id: number | null;
+  previous_competition_id: number | null;
+  previous_result_order: "highest_first" | "lowest_first";
+  locked: boolean;
+  computed_at: string;
+  updated_at: string;
+  participants: SeededParticipant[];
+}
+
+export interface Performance {
+  competition_id: number;
+  competition_name: string;
+  venue: string;
+  series: string;
+  discipline: string;
+  event: string;
+  event_id: number | null;
+  round?: string;
+  mark: number;
+  lower_is_better: boolean;
+  achieved_on: string;
+  personal_best: boolean;
+  venue_record: boolean;
+  series_record: boolean;
+}
+
+export interface PersonalBest {
+  participant_id: number;
+  discipline: string;
+  event: string;
+  mark: number;
+  lower_is_better: boolean;
+  competition_id: number;
+  competition_name: string;
+  achieved_on: string;
+}
+
+export interface CompetitionRecord {
+  scope: "venue" | "series";
+  name: string;
+  discipline: string;
+  event: string;
+  mark: number;
+  lower_is_better: boolean;
+  participant_id: number;
+  participant_name: string;
+  competition_id: number;
+  competition_name: string;
+  achieved_on: string;
+}
+
 export interface CompetitionFormData extends Omit<Competition, "id"> {}